@@ -0,0 +1,268 @@
+/*
+SPDX-License-Identifier: BSD-3-Clause-Open-MPI
+*/
+
+package ca
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/msp"
+	"github.com/open-dovetail/fabric-client/activity/request"
+	"github.com/pkg/errors"
+	"github.com/project-flogo/core/activity"
+	"github.com/project-flogo/core/support/log"
+)
+
+const (
+	opRegister   = "register"
+	opEnroll     = "enroll"
+	opReenroll   = "reenroll"
+	opRevoke     = "revoke"
+	opIdentities = "identities"
+)
+
+// Create a new logger
+var logger = log.ChildLogger(log.RootLogger(), "activity-fabclient-ca")
+
+var activityMd = activity.ToMetadata(&Settings{}, &Input{}, &Output{})
+
+func init() {
+	_ = activity.Register(&Activity{}, New)
+}
+
+// Activity fabric CA activity struct
+type Activity struct {
+	connectionName string
+	orgName        string
+	caID           string
+	operation      string
+	wallet         request.Wallet
+}
+
+// New creates a new Activity
+func New(ctx activity.InitContext) (activity.Activity, error) {
+	s := &Settings{}
+	logger.Infof("Create CA activity with InitContxt settings %v", ctx.Settings())
+	if err := s.FromMap(ctx.Settings()); err != nil {
+		logger.Errorf("failed to configure CA activity %v", err)
+		return nil, err
+	}
+	switch s.Operation {
+	case opRegister, opEnroll, opReenroll, opRevoke, opIdentities:
+	default:
+		return nil, errors.Errorf("unsupported CA operation %s", s.Operation)
+	}
+	// load network config files specified by environment variables, if any
+	if err := request.ConfigureNetwork(s.ConnectionName, "", ""); err != nil {
+		logger.Errorf("failed to load network config %v", err)
+		return nil, err
+	}
+
+	act := &Activity{
+		connectionName: s.ConnectionName,
+		orgName:        s.OrgName,
+		caID:           s.CAID,
+		operation:      s.Operation,
+	}
+	if len(s.Wallet) > 0 {
+		wallet, err := request.OpenWallet(s.Wallet, s.WalletPassphrase)
+		if err != nil {
+			logger.Errorf("failed to open wallet %s: %v", s.Wallet, err)
+			return nil, err
+		}
+		act.wallet = wallet
+	}
+	return act, nil
+}
+
+// Metadata implements activity.Activity.Metadata
+func (a *Activity) Metadata() *activity.Metadata {
+	return activityMd
+}
+
+// Eval implements activity.Activity.Eval
+func (a *Activity) Eval(ctx activity.Context) (done bool, err error) {
+	logger.Debugf("%v", a)
+
+	// check input args
+	input := &Input{}
+	if err = ctx.GetInputObject(input); err != nil {
+		return false, err
+	}
+	if len(input.EnrollmentID) == 0 && a.operation != opIdentities && a.operation != opRevoke {
+		logger.Error("enrollment ID is not specified")
+		return a.setError(ctx, 400, errors.New("enrollment ID is not specified"))
+	}
+
+	client, release, err := getCAClient(a.connectionName, a.orgName, a.caID)
+	if err != nil {
+		return a.setError(ctx, 500, err)
+	}
+	defer release()
+
+	var result interface{}
+	var msg string
+	switch a.operation {
+	case opRegister:
+		logger.Debugf("register %s of affiliation %s", input.EnrollmentID, input.Affiliation)
+		secret, err := client.Register(&msp.RegistrationRequest{
+			Name:           input.EnrollmentID,
+			Type:           input.IdentityType,
+			MaxEnrollments: input.MaxEnrollments,
+			Affiliation:    input.Affiliation,
+			Attributes:     input.Attributes,
+			Secret:         input.Secret,
+		})
+		if err != nil {
+			return a.setError(ctx, errorCode(err), errors.Wrapf(err, "Failed to register %s", input.EnrollmentID))
+		}
+		result = map[string]interface{}{
+			"enrollmentID": input.EnrollmentID,
+			"secret":       secret,
+		}
+		msg = fmt.Sprintf("Registered %s", input.EnrollmentID)
+	case opEnroll, opReenroll:
+		if a.operation == opEnroll {
+			if len(input.Secret) == 0 {
+				return a.setError(ctx, 400, errors.New("enrollment secret is not specified"))
+			}
+			logger.Debugf("enroll %s", input.EnrollmentID)
+			err = client.Enroll(input.EnrollmentID, enrollmentOptions(input, msp.WithSecret(input.Secret))...)
+		} else {
+			logger.Debugf("re-enroll %s", input.EnrollmentID)
+			err = client.Reenroll(input.EnrollmentID, enrollmentOptions(input)...)
+		}
+		if err != nil {
+			return a.setError(ctx, errorCode(err), errors.Wrapf(err, "Failed to %s %s", a.operation, input.EnrollmentID))
+		}
+		user, err := client.EnrolledUser(input.EnrollmentID)
+		if err != nil {
+			return a.setError(ctx, 500, err)
+		}
+		if a.wallet != nil {
+			// store enrolled identity in wallet, so request activities can select it by the enrollment ID
+			if err := a.wallet.Put(input.EnrollmentID, &request.WalletIdentity{
+				MspID:       user.MspID,
+				Certificate: user.Certificate,
+				PrivateKey:  user.PrivateKey,
+			}); err != nil {
+				return a.setError(ctx, 500, errors.Wrapf(err, "Failed to store %s in wallet", input.EnrollmentID))
+			}
+		}
+		result = user.ToMap()
+		msg = fmt.Sprintf("Enrolled %s of %s", input.EnrollmentID, user.MspID)
+	case opRevoke:
+		if len(input.EnrollmentID) == 0 && (len(input.Serial) == 0 || len(input.AKI) == 0) {
+			return a.setError(ctx, 400, errors.New("enrollment ID, or serial and AKI of a certificate, must be specified"))
+		}
+		logger.Debugf("revoke %s serial %s", input.EnrollmentID, input.Serial)
+		resp, err := client.Revoke(&msp.RevocationRequest{
+			Name:   input.EnrollmentID,
+			Serial: input.Serial,
+			AKI:    input.AKI,
+			Reason: input.Reason,
+			GenCRL: input.GenCRL,
+		})
+		if err != nil {
+			return a.setError(ctx, errorCode(err), errors.Wrapf(err, "Failed to revoke %s%s", input.EnrollmentID, input.Serial))
+		}
+		result = revocationToMap(resp)
+		msg = fmt.Sprintf("Revoked %d certificates", len(resp.RevokedCerts))
+	case opIdentities:
+		if len(input.EnrollmentID) > 0 {
+			logger.Debugf("get identity %s", input.EnrollmentID)
+			identity, err := client.GetIdentity(input.EnrollmentID)
+			if err != nil {
+				return a.setError(ctx, errorCode(err), errors.Wrapf(err, "Failed to get identity %s", input.EnrollmentID))
+			}
+			result = []interface{}{identityToMap(identity)}
+			msg = fmt.Sprintf("Identity %s", input.EnrollmentID)
+			break
+		}
+		logger.Debug("get all identities")
+		identities, err := client.GetAllIdentities()
+		if err != nil {
+			return a.setError(ctx, errorCode(err), errors.Wrapf(err, "Failed to get identities"))
+		}
+		var ids []interface{}
+		for _, id := range identities {
+			ids = append(ids, identityToMap(id))
+		}
+		result = ids
+		msg = fmt.Sprintf("Found %d identities", len(ids))
+	}
+
+	output := &Output{Code: 200, Message: msg, Result: result}
+	ctx.SetOutputObject(output)
+	return true, nil
+}
+
+// setError sets activity output for a failed CA request
+func (a *Activity) setError(ctx activity.Context, code int, err error) (bool, error) {
+	logger.Errorf("CA %s request returned error %+v", a.operation, err)
+	output := &Output{Code: code, Message: err.Error()}
+	ctx.SetOutputObject(output)
+	return false, err
+}
+
+// enrollmentOptions returns options of enroll or re-enroll requests
+func enrollmentOptions(input *Input, opts ...msp.EnrollmentOption) []msp.EnrollmentOption {
+	if len(input.IdentityType) > 0 {
+		opts = append(opts, msp.WithType(input.IdentityType))
+	}
+	if len(input.AttributeRequests) > 0 {
+		opts = append(opts, msp.WithAttributeRequests(input.AttributeRequests))
+	}
+	return opts
+}
+
+// errorCode returns 404 if the identity is not found, 401 if the CA rejects the credentials,
+// 409 if the identity is already registered, or 500 for other errors
+func errorCode(err error) int {
+	msg := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(msg, "not found"), strings.Contains(msg, "does not exist"):
+		return 404
+	case strings.Contains(msg, "authentication failure"), strings.Contains(msg, "authorization failure"),
+		strings.Contains(msg, "incorrect password"):
+		return 401
+	case strings.Contains(msg, "already registered"):
+		return 409
+	}
+	return 500
+}
+
+func identityToMap(identity *msp.IdentityResponse) map[string]interface{} {
+	var attrs []interface{}
+	for _, a := range identity.Attributes {
+		attrs = append(attrs, map[string]interface{}{
+			"name":  a.Name,
+			"value": a.Value,
+			"ecert": a.ECert,
+		})
+	}
+	return map[string]interface{}{
+		"enrollmentID":   identity.ID,
+		"identityType":   identity.Type,
+		"affiliation":    identity.Affiliation,
+		"maxEnrollments": identity.MaxEnrollments,
+		"attributes":     attrs,
+		"caName":         identity.CAName,
+	}
+}
+
+func revocationToMap(resp *msp.RevocationResponse) map[string]interface{} {
+	var certs []interface{}
+	for _, c := range resp.RevokedCerts {
+		certs = append(certs, map[string]interface{}{
+			"serial": c.Serial,
+			"aki":    c.AKI,
+		})
+	}
+	return map[string]interface{}{
+		"revokedCerts": certs,
+		"crl":          string(resp.CRL),
+	}
+}
@@ -0,0 +1,136 @@
+/*
+SPDX-License-Identifier: BSD-3-Clause-Open-MPI
+*/
+
+package ca
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/msp"
+	"github.com/hyperledger/fabric-sdk-go/pkg/core/cryptosuite"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fabsdk"
+	"github.com/open-dovetail/fabric-client/activity/request"
+	"github.com/pkg/errors"
+	"github.com/project-flogo/core/engine"
+)
+
+// caService is the part of msp.Client used by the activity to manage identities of a Fabric CA
+type caService interface {
+	Register(request *msp.RegistrationRequest) (string, error)
+	Enroll(enrollmentID string, opts ...msp.EnrollmentOption) error
+	Reenroll(enrollmentID string, opts ...msp.EnrollmentOption) error
+	Revoke(request *msp.RevocationRequest) (*msp.RevocationResponse, error)
+	GetAllIdentities(opts ...msp.RequestOption) ([]*msp.IdentityResponse, error)
+	GetIdentity(id string, opts ...msp.RequestOption) (*msp.IdentityResponse, error)
+	// EnrolledUser returns the certificate and private key of an enrolled user
+	EnrolledUser(enrollmentID string) (*EnrolledUser, error)
+}
+
+// EnrolledUser contains the PEM encoded certificate and private key issued by Fabric CA
+type EnrolledUser struct {
+	EnrollmentID string
+	MspID        string
+	Certificate  string
+	PrivateKey   string
+}
+
+// ToMap converts enrolled user to a map
+func (u *EnrolledUser) ToMap() map[string]interface{} {
+	return map[string]interface{}{
+		"enrollmentID": u.EnrollmentID,
+		"mspID":        u.MspID,
+		"certificate":  u.Certificate,
+		"privateKey":   u.PrivateKey,
+	}
+}
+
+// caClient adds access to the keys of enrolled users to msp.Client
+type caClient struct {
+	*msp.Client
+	keyStorePath string
+}
+
+// cached CA clients for each network, org and CA, which are closed when the Flogo engine stops
+var caClients = request.NewClientRegistry(request.DefaultMaxClients, request.DefaultClientIdleTimeout)
+
+func init() {
+	engine.LifeCycle(caClients)
+	request.OnNetworkReload(caClients.RemoveConnection)
+}
+
+func clientKey(connectionName, orgName, caID string) string {
+	return fmt.Sprintf("%s.%s.%s", connectionName, orgName, caID)
+}
+
+// getCAClient returns a new or cached client of a CA of an org; the first CA of the client org is used by default.
+// The client is held for the caller, who must call the returned release function when its request completes,
+// so the client and its SDK are not closed by cache eviction or a network reload during the request.
+func getCAClient(connectionName, orgName, caID string) (caService, func(), error) {
+	client, err := caClients.GetOrCreate(clientKey(connectionName, orgName, caID), func() (request.CachedClient, error) {
+		// use the SDK shared with request activities of the same connection, so they can use the enrolled users
+		sdk, err := request.SharedSDK(request.NetworkConnector(connectionName))
+		if err != nil {
+			return nil, err
+		}
+		service, err := newCAClient(sdk, orgName, caID)
+		if err != nil {
+			request.ReleaseSDK(sdk)
+			return nil, err
+		}
+		return request.NewServiceClient(connectionName, sdk, service), nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return client.(*request.ServiceClient).Service().(caService), client.Release, nil
+}
+
+// newCAClient creates a client of a CA of an org from an SDK
+func newCAClient(sdk *fabsdk.FabricSDK, orgName, caID string) (*caClient, error) {
+	var opts []msp.ClientOption
+	if len(orgName) > 0 {
+		opts = append(opts, msp.WithOrg(orgName))
+	}
+	if len(caID) > 0 {
+		opts = append(opts, msp.WithCAInstance(caID))
+	}
+	client, err := msp.New(sdk.Context(), opts...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to create CA client of org %s", orgName)
+	}
+	backend, err := sdk.Config()
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to read SDK config")
+	}
+	return &caClient{
+		Client:       client,
+		keyStorePath: cryptosuite.ConfigFromBackend(backend).KeyStorePath(),
+	}, nil
+}
+
+// EnrolledUser returns the certificate of an enrolled user, and its private key in the keystore of the SDK
+func (c *caClient) EnrolledUser(enrollmentID string) (*EnrolledUser, error) {
+	identity, err := c.GetSigningIdentity(enrollmentID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to get signing identity of %s", enrollmentID)
+	}
+	user := &EnrolledUser{
+		EnrollmentID: enrollmentID,
+		MspID:        identity.Identifier().MSPID,
+		Certificate:  string(identity.EnrollmentCertificate()),
+	}
+	if key := identity.PrivateKey(); key != nil {
+		// file keystore of the SDK stores private key in a PEM file named by its SKI
+		keyFile := filepath.Join(c.keyStorePath, hex.EncodeToString(key.SKI())+"_sk")
+		keyPem, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to read private key of %s", enrollmentID)
+		}
+		user.PrivateKey = string(keyPem)
+	}
+	return user, nil
+}
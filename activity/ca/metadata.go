@@ -0,0 +1,222 @@
+/*
+SPDX-License-Identifier: BSD-3-Clause-Open-MPI
+*/
+
+package ca
+
+import (
+	"strings"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/msp"
+	"github.com/pkg/errors"
+	"github.com/project-flogo/core/data/coerce"
+)
+
+// Settings of the activity
+type Settings struct {
+	ConnectionName string `md:"connectionName,required"`
+	OrgName        string `md:"orgName"`
+	CAID           string `md:"caID"`
+	Operation      string `md:"operation,required"`
+	// wallet to store enrolled identities
+	Wallet           string `md:"wallet"`
+	WalletPassphrase string `md:"walletPassphrase"`
+}
+
+// Input of the activity
+type Input struct {
+	EnrollmentID      string                  `md:"enrollmentID"`
+	Secret            string                  `md:"secret"`
+	IdentityType      string                  `md:"identityType"`
+	Affiliation       string                  `md:"affiliation"`
+	MaxEnrollments    int                     `md:"maxEnrollments"`
+	Attributes        []msp.Attribute         `md:"attributes"`
+	AttributeRequests []*msp.AttributeRequest `md:"attributeRequests"`
+	Serial            string                  `md:"serial"`
+	AKI               string                  `md:"aki"`
+	Reason            string                  `md:"reason"`
+	GenCRL            bool                    `md:"genCRL"`
+}
+
+// Output of the activity
+type Output struct {
+	Code    int         `md:"code"`
+	Message string      `md:"message"`
+	Result  interface{} `md:"result"`
+}
+
+// FromMap sets activity settings from a map
+func (h *Settings) FromMap(values map[string]interface{}) error {
+	var err error
+	if h.ConnectionName, err = coerce.ToString(values["connectionName"]); err != nil {
+		return err
+	}
+	if h.OrgName, err = coerce.ToString(values["orgName"]); err != nil {
+		return err
+	}
+	if h.CAID, err = coerce.ToString(values["caID"]); err != nil {
+		return err
+	}
+	if h.Operation, err = coerce.ToString(values["operation"]); err != nil {
+		return err
+	}
+	if h.Wallet, err = coerce.ToString(values["wallet"]); err != nil {
+		return err
+	}
+	if h.WalletPassphrase, err = coerce.ToString(values["walletPassphrase"]); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ToMap converts activity input to a map
+func (i *Input) ToMap() map[string]interface{} {
+	var attrs []interface{}
+	for _, a := range i.Attributes {
+		attrs = append(attrs, map[string]interface{}{
+			"name":  a.Name,
+			"value": a.Value,
+			"ecert": a.ECert,
+		})
+	}
+	var attrReqs []interface{}
+	for _, a := range i.AttributeRequests {
+		attrReqs = append(attrReqs, map[string]interface{}{
+			"name":     a.Name,
+			"optional": a.Optional,
+		})
+	}
+
+	return map[string]interface{}{
+		"enrollmentID":      i.EnrollmentID,
+		"secret":            i.Secret,
+		"identityType":      i.IdentityType,
+		"affiliation":       i.Affiliation,
+		"maxEnrollments":    i.MaxEnrollments,
+		"attributes":        attrs,
+		"attributeRequests": attrReqs,
+		"serial":            i.Serial,
+		"aki":               i.AKI,
+		"reason":            i.Reason,
+		"genCRL":            i.GenCRL,
+	}
+}
+
+// FromMap sets activity input values from a map
+func (i *Input) FromMap(values map[string]interface{}) error {
+	var err error
+	if i.EnrollmentID, err = coerce.ToString(values["enrollmentID"]); err != nil {
+		return err
+	}
+	i.EnrollmentID = strings.TrimSpace(i.EnrollmentID)
+	if i.Secret, err = coerce.ToString(values["secret"]); err != nil {
+		return err
+	}
+	if i.IdentityType, err = coerce.ToString(values["identityType"]); err != nil {
+		return err
+	}
+	if i.Affiliation, err = coerce.ToString(values["affiliation"]); err != nil {
+		return err
+	}
+	if i.MaxEnrollments, err = coerce.ToInt(values["maxEnrollments"]); err != nil {
+		return err
+	}
+	if i.Serial, err = coerce.ToString(values["serial"]); err != nil {
+		return err
+	}
+	if i.AKI, err = coerce.ToString(values["aki"]); err != nil {
+		return err
+	}
+	if i.Reason, err = coerce.ToString(values["reason"]); err != nil {
+		return err
+	}
+	if i.GenCRL, err = coerce.ToBool(values["genCRL"]); err != nil {
+		return err
+	}
+
+	attrs, err := toObjectArray(values["attributes"])
+	if err != nil {
+		return errors.Wrapf(err, "Invalid attributes")
+	}
+	for _, a := range attrs {
+		attr := msp.Attribute{}
+		if attr.Name, err = coerce.ToString(a["name"]); err != nil {
+			return err
+		}
+		if attr.Value, err = coerce.ToString(a["value"]); err != nil {
+			return err
+		}
+		if attr.ECert, err = coerce.ToBool(a["ecert"]); err != nil {
+			return err
+		}
+		if len(attr.Name) > 0 {
+			i.Attributes = append(i.Attributes, attr)
+		}
+	}
+
+	attrReqs, err := toObjectArray(values["attributeRequests"])
+	if err != nil {
+		return errors.Wrapf(err, "Invalid attributeRequests")
+	}
+	for _, a := range attrReqs {
+		req := &msp.AttributeRequest{}
+		if req.Name, err = coerce.ToString(a["name"]); err != nil {
+			return err
+		}
+		if req.Optional, err = coerce.ToBool(a["optional"]); err != nil {
+			return err
+		}
+		if len(req.Name) > 0 {
+			i.AttributeRequests = append(i.AttributeRequests, req)
+		}
+	}
+	return nil
+}
+
+// toObjectArray converts a JSON object or an array of JSON objects to an array of maps
+func toObjectArray(value interface{}) ([]map[string]interface{}, error) {
+	if s, ok := value.(string); value == nil || (ok && len(strings.TrimSpace(s)) == 0) {
+		return nil, nil
+	}
+	if obj, ok := value.(map[string]interface{}); ok {
+		return []map[string]interface{}{obj}, nil
+	}
+	items, err := coerce.ToArray(value)
+	if err != nil {
+		return nil, err
+	}
+	var result []map[string]interface{}
+	for _, item := range items {
+		obj, err := coerce.ToObject(item)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, obj)
+	}
+	return result, nil
+}
+
+// ToMap converts activity output to a map
+func (o *Output) ToMap() map[string]interface{} {
+	return map[string]interface{}{
+		"code":    o.Code,
+		"message": o.Message,
+		"result":  o.Result,
+	}
+}
+
+// FromMap sets activity output values from a map
+func (o *Output) FromMap(values map[string]interface{}) error {
+
+	var err error
+	if o.Code, err = coerce.ToInt(values["code"]); err != nil {
+		return err
+	}
+	if o.Message, err = coerce.ToString(values["message"]); err != nil {
+		return err
+	}
+	if o.Result, err = coerce.ToAny(values["result"]); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+/*
+SPDX-License-Identifier: BSD-3-Clause-Open-MPI
+*/
+
+package ledger
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	"github.com/open-dovetail/fabric-client/activity/request"
+	"github.com/pkg/errors"
+	"github.com/project-flogo/core/activity"
+	"github.com/project-flogo/core/support/log"
+)
+
+const (
+	opChainInfo   = "chainInfo"
+	opBlock       = "block"
+	opBlockByHash = "blockByHash"
+	opTransaction = "transaction"
+	opBlockByTxID = "blockByTxID"
+)
+
+// Create a new logger
+var logger = log.ChildLogger(log.RootLogger(), "activity-fabclient-ledger")
+
+var activityMd = activity.ToMetadata(&Settings{}, &Input{}, &Output{})
+
+func init() {
+	_ = activity.Register(&Activity{}, New)
+}
+
+// Activity fabric ledger activity struct
+type Activity struct {
+	connectionName string
+	channelID      string
+	operation      string
+}
+
+// New creates a new Activity
+func New(ctx activity.InitContext) (activity.Activity, error) {
+	s := &Settings{}
+	logger.Infof("Create ledger activity with InitContxt settings %v", ctx.Settings())
+	if err := s.FromMap(ctx.Settings()); err != nil {
+		logger.Errorf("failed to configure ledger activity %v", err)
+		return nil, err
+	}
+	switch s.Operation {
+	case opChainInfo, opBlock, opBlockByHash, opTransaction, opBlockByTxID:
+	default:
+		return nil, errors.Errorf("unsupported ledger operation %s", s.Operation)
+	}
+	// load network config files specified by environment variables, if any
+	if err := request.ConfigureNetwork(s.ConnectionName, "", ""); err != nil {
+		logger.Errorf("failed to load network config %v", err)
+		return nil, err
+	}
+
+	return &Activity{
+		connectionName: s.ConnectionName,
+		channelID:      s.ChannelID,
+		operation:      s.Operation,
+	}, nil
+}
+
+// Metadata implements activity.Activity.Metadata
+func (a *Activity) Metadata() *activity.Metadata {
+	return activityMd
+}
+
+// Eval implements activity.Activity.Eval
+func (a *Activity) Eval(ctx activity.Context) (done bool, err error) {
+	logger.Debugf("%v", a)
+
+	// check input args
+	input := &Input{}
+	if err = ctx.GetInputObject(input); err != nil {
+		return false, err
+	}
+	if len(input.UserName) == 0 {
+		logger.Error("user name is not specified")
+		return a.setError(ctx, 400, errors.New("user name is not specified"))
+	}
+
+	client, release, err := getLedgerClient(a.connectionName, a.channelID, input.UserName, input.OrgName)
+	if err != nil {
+		return a.setError(ctx, 500, err)
+	}
+	defer release()
+	opts := requestOptions(input.TimeoutMillis, input.Endpoints)
+
+	var result map[string]interface{}
+	var msg string
+	switch a.operation {
+	case opChainInfo:
+		logger.Debugf("query chain info of channel %s", a.channelID)
+		info, err := client.QueryInfo(opts...)
+		if err != nil {
+			return a.setError(ctx, 500, errors.Wrapf(err, "Failed to query chain info"))
+		}
+		result = chainInfoToMap(info)
+		msg = fmt.Sprintf("Channel %s height %v", a.channelID, result["height"])
+	case opBlock:
+		logger.Debugf("query block %d of channel %s", input.BlockNumber, a.channelID)
+		block, err := client.QueryBlock(input.BlockNumber, opts...)
+		if err != nil {
+			return a.setError(ctx, notFoundCode(err), errors.Wrapf(err, "Failed to query block %d", input.BlockNumber))
+		}
+		if result, err = blockToMap(request.DecodeBlock(block)); err != nil {
+			return a.setError(ctx, 500, err)
+		}
+		msg = fmt.Sprintf("Block %d", input.BlockNumber)
+	case opBlockByHash:
+		if len(input.BlockHash) == 0 {
+			return a.setError(ctx, 400, errors.New("block hash is not specified"))
+		}
+		hash, err := hex.DecodeString(input.BlockHash)
+		if err != nil {
+			return a.setError(ctx, 400, errors.Wrapf(err, "Block hash %s is not a hex string", input.BlockHash))
+		}
+		logger.Debugf("query block %s of channel %s", input.BlockHash, a.channelID)
+		block, err := client.QueryBlockByHash(hash, opts...)
+		if err != nil {
+			return a.setError(ctx, notFoundCode(err), errors.Wrapf(err, "Failed to query block by hash %s", input.BlockHash))
+		}
+		if result, err = blockToMap(request.DecodeBlock(block)); err != nil {
+			return a.setError(ctx, 500, err)
+		}
+		msg = fmt.Sprintf("Block %d", block.Header.Number)
+	case opTransaction:
+		if len(input.TransactionID) == 0 {
+			return a.setError(ctx, 400, errors.New("transaction ID is not specified"))
+		}
+		logger.Debugf("query transaction %s of channel %s", input.TransactionID, a.channelID)
+		ptx, err := client.QueryTransaction(fab.TransactionID(input.TransactionID), opts...)
+		if err != nil {
+			return a.setError(ctx, notFoundCode(err), errors.Wrapf(err, "Failed to query transaction %s", input.TransactionID))
+		}
+		tx, err := request.DecodeProcessedTransaction(ptx)
+		if err != nil {
+			return a.setError(ctx, 500, err)
+		}
+		if result, err = tx.ToMap(); err != nil {
+			return a.setError(ctx, 500, err)
+		}
+		msg = fmt.Sprintf("Transaction %s validation code %s", tx.TxID, tx.ValidationCode)
+	case opBlockByTxID:
+		if len(input.TransactionID) == 0 {
+			return a.setError(ctx, 400, errors.New("transaction ID is not specified"))
+		}
+		logger.Debugf("query block of transaction %s on channel %s", input.TransactionID, a.channelID)
+		block, err := client.QueryBlockByTxID(fab.TransactionID(input.TransactionID), opts...)
+		if err != nil {
+			return a.setError(ctx, notFoundCode(err), errors.Wrapf(err, "Failed to query block of transaction %s", input.TransactionID))
+		}
+		if result, err = blockToMap(request.DecodeBlock(block)); err != nil {
+			return a.setError(ctx, 500, err)
+		}
+		msg = fmt.Sprintf("Block %d", block.Header.Number)
+	}
+
+	output := &Output{Code: 200, Message: msg, Result: result}
+	ctx.SetOutputObject(output)
+	return true, nil
+}
+
+// setError sets activity output for a failed ledger query
+func (a *Activity) setError(ctx activity.Context, code int, err error) (bool, error) {
+	logger.Errorf("ledger %s request returned error %+v", a.operation, err)
+	output := &Output{Code: code, Message: err.Error()}
+	ctx.SetOutputObject(output)
+	return false, err
+}
+
+// notFoundCode returns 404 if qscc cannot find the requested block or transaction
+func notFoundCode(err error) int {
+	msg := err.Error()
+	if strings.Contains(msg, "no such transaction ID") || strings.Contains(msg, "not found") {
+		return 404
+	}
+	return 500
+}
+
+// chainInfoToMap returns block height and hex encoded hashes of current and previous block
+func chainInfoToMap(info *fab.BlockchainInfoResponse) map[string]interface{} {
+	result := map[string]interface{}{
+		"endorser": info.Endorser,
+	}
+	if info.BCI != nil {
+		result["height"] = info.BCI.Height
+		result["currentBlockHash"] = hex.EncodeToString(info.BCI.CurrentBlockHash)
+		result["previousBlockHash"] = hex.EncodeToString(info.BCI.PreviousBlockHash)
+	}
+	return result
+}
+
+func blockToMap(block *request.Block, err error) (map[string]interface{}, error) {
+	if err != nil {
+		return nil, err
+	}
+	return block.ToMap()
+}
@@ -0,0 +1,118 @@
+/*
+SPDX-License-Identifier: BSD-3-Clause-Open-MPI
+*/
+
+package ledger
+
+import (
+	"testing"
+
+	cb "github.com/hyperledger/fabric-protos-go/common"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/ledger"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	"github.com/open-dovetail/fabric-client/activity/request"
+	"github.com/pkg/errors"
+	"github.com/project-flogo/core/data/mapper"
+	"github.com/project-flogo/core/data/resolve"
+	"github.com/project-flogo/core/support/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testLedger returns canned results of a channel with 2 empty blocks
+type testLedger struct{}
+
+func (l *testLedger) QueryInfo(options ...ledger.RequestOption) (*fab.BlockchainInfoResponse, error) {
+	return &fab.BlockchainInfoResponse{
+		BCI:      &cb.BlockchainInfo{Height: 2, CurrentBlockHash: []byte{0x01}, PreviousBlockHash: []byte{0x00}},
+		Endorser: "peer0.org1.example.com",
+		Status:   200,
+	}, nil
+}
+
+func (l *testLedger) QueryBlock(blockNumber uint64, options ...ledger.RequestOption) (*cb.Block, error) {
+	if blockNumber > 1 {
+		return nil, errors.Errorf("Failed to get block number %d, error Entry not found in index", blockNumber)
+	}
+	return &cb.Block{Header: &cb.BlockHeader{Number: blockNumber}}, nil
+}
+
+func (l *testLedger) QueryBlockByHash(blockHash []byte, options ...ledger.RequestOption) (*cb.Block, error) {
+	return &cb.Block{Header: &cb.BlockHeader{Number: uint64(blockHash[0])}}, nil
+}
+
+func (l *testLedger) QueryBlockByTxID(txID fab.TransactionID, options ...ledger.RequestOption) (*cb.Block, error) {
+	return &cb.Block{Header: &cb.BlockHeader{Number: 1}}, nil
+}
+
+func (l *testLedger) QueryTransaction(transactionID fab.TransactionID, options ...ledger.RequestOption) (*pb.ProcessedTransaction, error) {
+	return nil, errors.Errorf("Failed to get transaction with id %s, error no such transaction ID [%s] in index", transactionID, transactionID)
+}
+
+func evalLedger(t *testing.T, operation string, input *Input) (*Output, bool, error) {
+	settings := map[string]interface{}{
+		"connectionName": "test-ledger",
+		"channelID":      "mychannel",
+		"operation":      operation,
+	}
+	mf := mapper.NewFactory(resolve.GetBasicResolver())
+	act, err := New(test.NewActivityInitContext(settings, mf))
+	require.NoError(t, err, "create activity instance should not throw error")
+
+	tc := test.NewActivityContext(act.Metadata())
+	require.NoError(t, tc.SetInputObject(input), "setting action input should not throw error")
+	done, err := act.Eval(tc)
+	output := &Output{}
+	require.NoError(t, tc.GetOutputObject(output), "action output should not be error")
+	return output, done, err
+}
+
+func TestLedgerQuery(t *testing.T) {
+	ledgerClients.Put(clientKey("test-ledger", "mychannel", "User1", "org1"), request.NewServiceClient("test-ledger", nil, &testLedger{}))
+	defer ledgerClients.Remove(clientKey("test-ledger", "mychannel", "User1", "org1"))
+
+	output, done, err := evalLedger(t, opChainInfo, &Input{UserName: "User1", OrgName: "org1"})
+	assert.True(t, done, "chain info should be successful")
+	assert.NoError(t, err, "chain info should not throw error")
+	info := output.Result.(map[string]interface{})
+	assert.Equal(t, uint64(2), info["height"], "chain height should be 2")
+	assert.Equal(t, "01", info["currentBlockHash"], "current block hash should be hex encoded")
+
+	output, done, err = evalLedger(t, opBlock, &Input{UserName: "User1", OrgName: "org1", BlockNumber: 1})
+	assert.True(t, done, "query block should be successful")
+	assert.NoError(t, err, "query block should not throw error")
+	assert.Equal(t, float64(1), output.Result.(map[string]interface{})["number"], "block number should be 1")
+
+	output, done, _ = evalLedger(t, opBlock, &Input{UserName: "User1", OrgName: "org1", BlockNumber: 5})
+	assert.False(t, done, "query block beyond chain height should fail")
+	assert.Equal(t, 404, output.Code, "query block beyond chain height should return 404")
+
+	output, _, err = evalLedger(t, opBlockByHash, &Input{UserName: "User1", OrgName: "org1", BlockHash: "01"})
+	assert.NoError(t, err, "query block by hash should not throw error")
+	assert.Equal(t, float64(1), output.Result.(map[string]interface{})["number"], "block number should be 1")
+
+	output, _, _ = evalLedger(t, opBlockByHash, &Input{UserName: "User1", OrgName: "org1", BlockHash: "xyz"})
+	assert.Equal(t, 400, output.Code, "invalid block hash should return 400")
+
+	output, _, err = evalLedger(t, opBlockByTxID, &Input{UserName: "User1", OrgName: "org1", TransactionID: "tx1"})
+	assert.NoError(t, err, "query block by transaction ID should not throw error")
+	assert.Equal(t, 200, output.Code, "query block by transaction ID should return 200")
+
+	output, _, _ = evalLedger(t, opTransaction, &Input{UserName: "User1", OrgName: "org1", TransactionID: "tx-unknown"})
+	assert.Equal(t, 404, output.Code, "query unknown transaction should return 404")
+
+	output, _, _ = evalLedger(t, opTransaction, &Input{UserName: "User1", OrgName: "org1"})
+	assert.Equal(t, 400, output.Code, "query transaction without ID should return 400")
+}
+
+func TestInvalidOperation(t *testing.T) {
+	settings := map[string]interface{}{
+		"connectionName": "test-ledger",
+		"channelID":      "mychannel",
+		"operation":      "history",
+	}
+	mf := mapper.NewFactory(resolve.GetBasicResolver())
+	_, err := New(test.NewActivityInitContext(settings, mf))
+	assert.Error(t, err, "unsupported operation should throw error")
+}
@@ -0,0 +1,79 @@
+/*
+SPDX-License-Identifier: BSD-3-Clause-Open-MPI
+*/
+
+package ledger
+
+import (
+	"fmt"
+	"time"
+
+	cb "github.com/hyperledger/fabric-protos-go/common"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/ledger"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fabsdk"
+	"github.com/open-dovetail/fabric-client/activity/request"
+	"github.com/pkg/errors"
+	"github.com/project-flogo/core/engine"
+)
+
+// ledgerService is the part of ledger.Client used by the activity to query qscc
+type ledgerService interface {
+	QueryInfo(options ...ledger.RequestOption) (*fab.BlockchainInfoResponse, error)
+	QueryBlock(blockNumber uint64, options ...ledger.RequestOption) (*cb.Block, error)
+	QueryBlockByHash(blockHash []byte, options ...ledger.RequestOption) (*cb.Block, error)
+	QueryBlockByTxID(txID fab.TransactionID, options ...ledger.RequestOption) (*cb.Block, error)
+	QueryTransaction(transactionID fab.TransactionID, options ...ledger.RequestOption) (*pb.ProcessedTransaction, error)
+}
+
+// cached ledger clients for each network, channel and user, which are closed when the Flogo engine stops
+var ledgerClients = request.NewClientRegistry(request.DefaultMaxClients, request.DefaultClientIdleTimeout)
+
+func init() {
+	engine.LifeCycle(ledgerClients)
+	request.OnNetworkReload(ledgerClients.RemoveConnection)
+}
+
+func clientKey(connectionName, channelID, userName, orgName string) string {
+	return fmt.Sprintf("%s.%s.%s.%s", connectionName, channelID, userName, orgName)
+}
+
+// getLedgerClient returns a new or cached ledger client of a channel for a user.
+// The client is held for the caller, who must call the returned release function when its request completes,
+// so the client and its SDK are not closed by cache eviction or a network reload during the request.
+func getLedgerClient(connectionName, channelID, userName, orgName string) (ledgerService, func(), error) {
+	client, err := ledgerClients.GetOrCreate(clientKey(connectionName, channelID, userName, orgName), func() (request.CachedClient, error) {
+		// use the SDK shared with request activities of the same connection, which is held by the cached client
+		sdk, err := request.SharedSDK(request.NetworkConnector(connectionName))
+		if err != nil {
+			return nil, err
+		}
+		opts := []fabsdk.ContextOption{fabsdk.WithUser(userName)}
+		if len(orgName) > 0 {
+			opts = append(opts, fabsdk.WithOrg(orgName))
+		}
+		service, err := ledger.New(sdk.ChannelContext(channelID, opts...))
+		if err != nil {
+			request.ReleaseSDK(sdk)
+			return nil, errors.Wrapf(err, "Failed to create ledger client of channel %s", channelID)
+		}
+		return request.NewServiceClient(connectionName, sdk, service), nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return client.(*request.ServiceClient).Service().(ledgerService), client.Release, nil
+}
+
+// requestOptions returns ledger request options for timeout and target peers
+func requestOptions(timeoutMillis int, endpoints []string) []ledger.RequestOption {
+	var opts []ledger.RequestOption
+	if timeoutMillis > 0 {
+		opts = append(opts, ledger.WithTimeout(fab.PeerResponse, time.Duration(timeoutMillis)*time.Millisecond))
+	}
+	if len(endpoints) > 0 {
+		opts = append(opts, ledger.WithTargetEndpoints(endpoints...))
+	}
+	return opts
+}
@@ -0,0 +1,154 @@
+/*
+SPDX-License-Identifier: BSD-3-Clause-Open-MPI
+*/
+
+package ledger
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/project-flogo/core/data/coerce"
+)
+
+// Settings of the activity
+type Settings struct {
+	ConnectionName string `md:"connectionName,required"`
+	ChannelID      string `md:"channelID,required"`
+	Operation      string `md:"operation,required"`
+}
+
+// Input of the activity
+type Input struct {
+	OrgName       string   `md:"orgName"`
+	UserName      string   `md:"userName,required"`
+	BlockNumber   uint64   `md:"blockNumber"`
+	BlockHash     string   `md:"blockHash"`
+	TransactionID string   `md:"transactionID"`
+	TimeoutMillis int      `md:"timeoutMillis"`
+	Endpoints     []string `md:"endpoints"`
+}
+
+// Output of the activity
+type Output struct {
+	Code    int         `md:"code"`
+	Message string      `md:"message"`
+	Result  interface{} `md:"result"`
+}
+
+// FromMap sets activity settings from a map
+func (h *Settings) FromMap(values map[string]interface{}) error {
+	var err error
+	if h.ConnectionName, err = coerce.ToString(values["connectionName"]); err != nil {
+		return err
+	}
+	if h.ChannelID, err = coerce.ToString(values["channelID"]); err != nil {
+		return err
+	}
+	if h.Operation, err = coerce.ToString(values["operation"]); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ToMap converts activity input to a map
+func (i *Input) ToMap() map[string]interface{} {
+	var eps []interface{}
+	for _, p := range i.Endpoints {
+		eps = append(eps, p)
+	}
+
+	user := i.UserName
+	if len(i.OrgName) > 0 {
+		user += "@" + i.OrgName
+	}
+
+	return map[string]interface{}{
+		"userName":      user,
+		"blockNumber":   i.BlockNumber,
+		"blockHash":     i.BlockHash,
+		"transactionID": i.TransactionID,
+		"timeoutMillis": i.TimeoutMillis,
+		"endpoints":     eps,
+	}
+}
+
+// FromMap sets activity input values from a map
+func (i *Input) FromMap(values map[string]interface{}) error {
+
+	user, err := coerce.ToString(values["userName"])
+	if err != nil {
+		return err
+	}
+	tokens := strings.Split(strings.TrimSpace(user), "@")
+	if len(tokens) == 0 {
+		return errors.New("username is not specified")
+	}
+	i.UserName = strings.TrimSpace(tokens[0])
+	if len(tokens) > 1 {
+		i.OrgName = strings.TrimSpace(tokens[1])
+	}
+
+	blockNumber, err := coerce.ToInt64(values["blockNumber"])
+	if err != nil {
+		return err
+	}
+	if blockNumber < 0 {
+		return errors.New("block number must not be negative")
+	}
+	i.BlockNumber = uint64(blockNumber)
+	if i.BlockHash, err = coerce.ToString(values["blockHash"]); err != nil {
+		return err
+	}
+	if i.TransactionID, err = coerce.ToString(values["transactionID"]); err != nil {
+		return err
+	}
+	if i.TimeoutMillis, err = coerce.ToInt(values["timeoutMillis"]); err != nil {
+		return err
+	}
+
+	var eps interface{}
+	if eps, err = coerce.ToAny(values["endpoints"]); err != nil {
+		return err
+	}
+	switch v := eps.(type) {
+	case []interface{}:
+		for _, d := range v {
+			p := strings.TrimSpace(d.(string))
+			if len(p) > 0 {
+				i.Endpoints = append(i.Endpoints, p)
+			}
+		}
+	case string:
+		p := strings.TrimSpace(v)
+		if len(p) > 0 {
+			i.Endpoints = []string{p}
+		}
+	}
+	return nil
+}
+
+// ToMap converts activity output to a map
+func (o *Output) ToMap() map[string]interface{} {
+	return map[string]interface{}{
+		"code":    o.Code,
+		"message": o.Message,
+		"result":  o.Result,
+	}
+}
+
+// FromMap sets activity output values from a map
+func (o *Output) FromMap(values map[string]interface{}) error {
+
+	var err error
+	if o.Code, err = coerce.ToInt(values["code"]); err != nil {
+		return err
+	}
+	if o.Message, err = coerce.ToString(values["message"]); err != nil {
+		return err
+	}
+	if o.Result, err = coerce.ToAny(values["result"]); err != nil {
+		return err
+	}
+	return nil
+}
@@ -5,26 +5,39 @@ SPDX-License-Identifier: BSD-3-Clause-Open-MPI
 package request
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"strings"
 
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/status"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
 	"github.com/pkg/errors"
 	"github.com/project-flogo/core/activity"
 	"github.com/project-flogo/core/support/log"
 )
 
 const (
-	opInvoke = "invoke"
-	opQuery  = "query"
+	opInvoke   = "invoke"
+	opQuery    = "query"
+	opSubmit   = "submit"
+	opStatus   = "status"
+	opSimulate = "simulate"
+	// phases of requests signed by external signers
+	opPropose   = "propose"
+	opEndorse   = "endorse"
+	opBroadcast = "broadcast"
 )
 
-// NetworkConfig is the content of fabric network config file
+// NetworkConfig is the content of the default fabric network config file,
+// which is used by connections that are not registered by RegisterNetwork
 var NetworkConfig []byte
 
-// EntityMatcher is the content of fabric local entity matcher file
+// EntityMatcher is the content of the default fabric local entity matcher file
 var EntityMatcher []byte
 
-// InitializeNetwork can be called to initialize Fabric network config
+// InitializeNetwork can be called to initialize the default Fabric network config
 func InitializeNetwork(config, matcher []byte) {
 	NetworkConfig = config
 	if len(matcher) > 0 {
@@ -43,13 +56,18 @@ func init() {
 
 // Activity fabric request activity struct
 type Activity struct {
-	connectionName  string
-	channelID       string
-	chaincodeID     string
-	transactionName string
-	arguments       []*Attribute
-	requestType     string
-	userOrgOnly     bool
+	connectionName    string
+	channelID         string
+	chaincodeID       string
+	transactionName   string
+	arguments         []*Attribute
+	requestType       string
+	userOrgOnly       bool
+	endorsementPolicy string
+	retry             *RetryPolicy
+	wallet            Wallet
+	cipher            *FieldCipher
+	encryptedFields   []string
 }
 
 // New creates a new Activity
@@ -61,14 +79,44 @@ func New(ctx activity.InitContext) (activity.Activity, error) {
 		return nil, err
 	}
 
+	if err := ConfigureNetwork(s.ConnectionName, s.NetworkConfigFile, s.EntityMatchersFile); err != nil {
+		logger.Errorf("failed to load network config %v", err)
+		return nil, err
+	}
+
+	retry, err := s.retryPolicy()
+	if err != nil {
+		logger.Errorf("failed to configure retry policy %v", err)
+		return nil, err
+	}
+
+	cipher, encryptedFields, err := s.fieldCipher()
+	if err != nil {
+		logger.Errorf("failed to configure transient encryption %v", err)
+		return nil, err
+	}
+
+	var wallet Wallet
+	if len(s.Wallet) > 0 {
+		if wallet, err = OpenWallet(s.Wallet, s.WalletPassphrase); err != nil {
+			logger.Errorf("failed to open wallet %s: %v", s.Wallet, err)
+			return nil, err
+		}
+	}
+
 	return &Activity{
-		connectionName:  s.ConnectionName,
-		channelID:       s.ChannelID,
-		chaincodeID:     s.ChaincodeID,
-		transactionName: s.TransactionName,
-		arguments:       s.Arguments,
-		requestType:     s.RequestType,
-		userOrgOnly:     s.UserOrgOnly,
+		connectionName:    s.ConnectionName,
+		channelID:         s.ChannelID,
+		chaincodeID:       s.ChaincodeID,
+		transactionName:   s.TransactionName,
+		arguments:         s.Arguments,
+		requestType:       s.RequestType,
+		userOrgOnly:       s.UserOrgOnly,
+		endorsementPolicy: s.EndorsementPolicy,
+		retry:             retry,
+		wallet:            wallet,
+		cipher:            cipher,
+		encryptedFields:   encryptedFields,
 	}, nil
 }
 
@@ -88,7 +136,18 @@ func (a *Activity) Eval(ctx activity.Context) (done bool, err error) {
 	}
 
 	params := a.prepareParameters(input.Parameters)
-	transientMap := prepareTransient(input.Transient)
+	transient := input.Transient
+	if len(a.encryptedFields) > 0 {
+		// encrypt sensitive fields, so they are not in plaintext in peer logs or the transient store
+		if transient, err = a.cipher.EncryptFields(transient, a.encryptedFields); err != nil {
+			return a.setInputError(ctx, err)
+		}
+	}
+	transientMap := prepareTransient(transient)
+	switch a.requestType {
+	case opPropose, opEndorse, opBroadcast:
+		return a.evalOffline(ctx, input, params, transientMap)
+	}
 
 	client, err := a.getFabricClient(input)
 	if err != nil {
@@ -96,39 +155,64 @@ func (a *Activity) Eval(ctx activity.Context) (done bool, err error) {
 		ctx.SetOutputObject(output)
 		return false, err
 	}
+	if input.callerIdentity() {
+		// release the single-use client, so the caller's key is not kept after the request
+		defer client.Close()
+	} else {
+		defer client.Release()
+	}
 
 	// invoke fabric transaction
-	var response []byte
-	var status int
-	if a.requestType == opInvoke {
+	opts := a.requestOptions(input)
+	var response Response
+	switch a.requestType {
+	case opInvoke:
 		logger.Debugf("execute chaincode %s transaction %s timeout %d endpoints %v", a.chaincodeID, a.transactionName, input.TimeoutMillis, input.Endpoints)
-		response, status, err = client.ExecuteChaincode(a.chaincodeID, a.transactionName, params, transientMap)
-	} else {
+		response, err = client.ExecuteChaincode(a.chaincodeID, a.transactionName, params, transientMap, opts...)
+	case opSubmit:
+		logger.Debugf("submit chaincode %s transaction %s timeout %d endpoints %v", a.chaincodeID, a.transactionName, input.TimeoutMillis, input.Endpoints)
+		response, err = client.SubmitChaincode(a.chaincodeID, a.transactionName, params, transientMap, opts...)
+	case opSimulate:
+		logger.Debugf("simulate chaincode %s transaction %s timeout %d endpoints %v", a.chaincodeID, a.transactionName, input.TimeoutMillis, input.Endpoints)
+		response, err = client.SimulateChaincode(a.chaincodeID, a.transactionName, params, transientMap, opts...)
+	case opStatus:
+		return checkStatus(ctx, client, input.TransactionID)
+	default:
 		logger.Debugf("query chaincode %s transaction %s timeout %d endpoints %v", a.chaincodeID, a.transactionName, input.TimeoutMillis, input.Endpoints)
-		response, status, err = client.QueryChaincode(a.chaincodeID, a.transactionName, params, transientMap)
+		response, err = client.QueryChaincode(a.chaincodeID, a.transactionName, params, transientMap, opts...)
 	}
 
 	if err != nil {
-		msg := "Fabric request returned error"
-		logger.Errorf("msg %+v", msg, err)
-		output := &Output{Code: 500, Message: msg}
-		ctx.SetOutputObject(output)
-		return false, errors.Wrapf(err, msg)
+		return a.setRequestError(ctx, response, err)
 	}
 
-	logger.Debugf("Fabric response - status %d, response %s", status, string(response))
+	status := int(response.ChaincodeStatus)
+	logger.Debugf("Fabric response - status %d, response %s", status, string(response.Payload))
 
 	var result interface{}
-	if status < 300 && len(response) > 0 {
-		if err := json.Unmarshal(response, &result); err != nil {
-			logger.Warnf("failed to unmarshal fabric response %+v, error: %+v", response, err)
-			result = response
+	if a.requestType == opSimulate {
+		// result of simulate contains chaincode response and read/write sets returned by each endorser
+		if result, err = simulationResult(response); err != nil {
+			msg := "Failed to decode simulation result"
+			logger.Errorf("%s %+v", msg, err)
+			output := &Output{Code: 500, Message: msg}
+			a.setTransactionOutput(output, response, err)
+			ctx.SetOutputObject(output)
+			return false, errors.Wrapf(err, msg)
+		}
+	} else if status < 300 && len(response.Payload) > 0 {
+		if err := json.Unmarshal(response.Payload, &result); err != nil {
+			logger.Warnf("failed to unmarshal fabric response %+v, error: %+v", response.Payload, err)
+			result = response.Payload
+		} else if a.cipher != nil {
+			// decrypt encrypted fields of query results
+			result = a.cipher.DecryptValues(result)
 		}
 	}
 
 	var msg string
-	if len(response) > 0 {
-		msg = string(response)
+	if len(response.Payload) > 0 {
+		msg = string(response.Payload)
 	} else {
 		msg = "No data returned"
 	}
@@ -136,27 +220,325 @@ func (a *Activity) Eval(ctx activity.Context) (done bool, err error) {
 		Message: msg,
 		Result:  result,
 	}
+	a.setTransactionOutput(output, response, nil)
+	ctx.SetOutputObject(output)
+	return true, nil
+}
+
+// setRequestError sets activity output for a failed Fabric request
+func (a *Activity) setRequestError(ctx activity.Context, response Response, err error) (bool, error) {
+	// classify error, so the caller can return a meaningful status code, e.g., 409 for MVCC_READ_CONFLICT
+	reqErr := ClassifyError(err)
+	msg := "Fabric request returned error"
+	logger.Errorf("%s %s %+v", msg, reqErr.Type, err)
+	output := &Output{Code: reqErr.Code, Message: reqErr.Message, ErrorType: reqErr.Type}
+	a.setTransactionOutput(output, response, err)
+	ctx.SetOutputObject(output)
+	return false, activity.NewError(fmt.Sprintf("%s: %s", msg, err.Error()), reqErr.Type, output.ToMap())
+}
+
+// setTransactionOutput sets transaction ID, endorsers, and commit status of invoke transaction in activity output
+func (a *Activity) setTransactionOutput(output *Output, response Response, err error) {
+	output.TransactionID = string(response.TransactionID)
+	for _, e := range response.Endorsers() {
+		output.Endorsers = append(output.Endorsers, map[string]interface{}{
+			"url":   e.URL,
+			"mspid": e.MSPID,
+		})
+	}
+	if a.requestType != opInvoke {
+		return
+	}
+	if err == nil {
+		output.ValidationCode = response.TxValidationCode.String()
+	} else if s, ok := status.FromError(err); ok && s.Group == status.EventServerStatus {
+		// transaction is committed, but invalidated
+		output.ValidationCode = pb.TxValidationCode(s.Code).String()
+	}
+	output.BlockNumber = response.BlockNumber
+}
+
+// simulationResult returns simulation result of the response as a JSON object
+func simulationResult(response Response) (map[string]interface{}, error) {
+	simulation, err := response.Simulation()
+	if err != nil {
+		return nil, err
+	}
+	return simulation.ToMap()
+}
+
+// checkStatus sets activity output for commit status of a submitted transaction
+func checkStatus(ctx activity.Context, client *FabricClient, txID string) (bool, error) {
+	if len(txID) == 0 {
+		logger.Error("transaction ID is not specified")
+		output := &Output{Code: 400, Message: "transaction ID is not specified"}
+		ctx.SetOutputObject(output)
+		return false, errors.New("transaction ID is not specified")
+	}
+
+	logger.Debugf("check status of transaction %s", txID)
+	txStatus, err := client.TransactionStatus(txID)
+	if err != nil {
+		reqErr := ClassifyError(err)
+		msg := "Fabric transaction status returned error"
+		logger.Errorf("%s %s %+v", msg, reqErr.Type, err)
+		output := &Output{Code: reqErr.Code, Message: reqErr.Message, ErrorType: reqErr.Type, TransactionID: txID}
+		ctx.SetOutputObject(output)
+		return false, activity.NewError(fmt.Sprintf("%s: %s", msg, err.Error()), reqErr.Type, output.ToMap())
+	}
+
+	// 202 if transaction is pending, 409 if it is invalidated by the committer
+	code := 200
+	msg := fmt.Sprintf("Transaction committed in block %d", txStatus.BlockNumber)
+	if !txStatus.Committed {
+		code = 202
+		msg = "Transaction is not committed"
+	} else if txStatus.ValidationCode != pb.TxValidationCode_VALID.String() {
+		code = 409
+		msg = fmt.Sprintf("Transaction invalidated with code %s", txStatus.ValidationCode)
+	}
+	output := &Output{Code: code,
+		Message:        msg,
+		Result:         txStatus.ToMap(),
+		TransactionID:  txID,
+		ValidationCode: txStatus.ValidationCode,
+		BlockNumber:    txStatus.BlockNumber,
+	}
 	ctx.SetOutputObject(output)
 	return true, nil
 }
 
+// evalOffline sets activity output for a phase of a transaction signed by an external signer, i.e.,
+// propose returns an unsigned proposal for a creator certificate, endorse sends the signed proposal to endorsing peers and returns the unsigned transaction,
+// and broadcast sends the signed transaction to orderer.
+func (a *Activity) evalOffline(ctx activity.Context, input *Input, params [][]byte, transientMap map[string][]byte) (bool, error) {
+	config := NetworkConnector(a.connectionName)
+	config.ChannelID = a.channelID
+	client, err := NewOfflineClient(config)
+	if err != nil {
+		output := &Output{Code: 500, Message: err.Error()}
+		ctx.SetOutputObject(output)
+		return false, err
+	}
+	defer client.Close()
+
+	var unsigned *UnsignedRequest
+	var response Response
+	var msg string
+	switch a.requestType {
+	case opPropose:
+		logger.Debugf("create proposal of chaincode %s transaction %s", a.chaincodeID, a.transactionName)
+		identity, err := a.creatorIdentity(input, config.NetworkConfig)
+		if err != nil {
+			return a.setInputError(ctx, err)
+		}
+		if unsigned, err = client.CreateProposal(identity.MspID, identity.Certificate, a.chaincodeID, a.transactionName, params, transientMap); err != nil {
+			return a.setInputError(ctx, err)
+		}
+		msg = "Proposal created"
+	case opEndorse:
+		proposal, signature, err := signedInput("proposal", input.Proposal, input.Signature)
+		if err != nil {
+			return a.setInputError(ctx, err)
+		}
+		logger.Debugf("endorse signed proposal timeout %d endpoints %v", input.TimeoutMillis, input.Endpoints)
+		if unsigned, response, err = client.EndorseProposal(proposal, signature, a.requestOptions(input)...); err != nil {
+			return a.setRequestError(ctx, response, err)
+		}
+		msg = string(response.Payload)
+	case opBroadcast:
+		payload, signature, err := signedInput("transaction", input.Transaction, input.Signature)
+		if err != nil {
+			return a.setInputError(ctx, err)
+		}
+		txID, err := client.BroadcastTransaction(payload, signature, a.requestOptions(input)...)
+		response.TransactionID = fab.TransactionID(txID)
+		if err != nil {
+			return a.setRequestError(ctx, response, err)
+		}
+		msg = fmt.Sprintf("Transaction %s sent to orderer", txID)
+	}
+
+	output := &Output{Code: 200, Message: msg}
+	if unsigned != nil {
+		result := unsigned.ToMap()
+		if a.requestType == opEndorse {
+			output.Code = int(response.ChaincodeStatus)
+			// chaincode response of the endorsements
+			var payload interface{}
+			if err := json.Unmarshal(response.Payload, &payload); err != nil {
+				payload = string(response.Payload)
+			}
+			result["response"] = payload
+		}
+		output.Result = result
+	}
+	a.setTransactionOutput(output, response, nil)
+	if unsigned != nil {
+		output.TransactionID = unsigned.TransactionID
+	}
+	ctx.SetOutputObject(output)
+	return true, nil
+}
+
+// setInputError sets activity output for invalid input of a request
+func (a *Activity) setInputError(ctx activity.Context, err error) (bool, error) {
+	logger.Errorf("invalid input of %s request: %v", a.requestType, err)
+	output := &Output{Code: 400, Message: err.Error()}
+	ctx.SetOutputObject(output)
+	return false, err
+}
+
+// signedInput decodes base64 encoded bytes and signature of a signed proposal or transaction
+func signedInput(name, data, signature string) ([]byte, []byte, error) {
+	if len(data) == 0 || len(signature) == 0 {
+		return nil, nil, errors.Errorf("%s and signature are not specified", name)
+	}
+	dataBytes, err := base64.StdEncoding.DecodeString(strings.TrimSpace(data))
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "Invalid base64 encoded %s", name)
+	}
+	sigBytes, err := base64.StdEncoding.DecodeString(strings.TrimSpace(signature))
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "Invalid base64 encoded signature")
+	}
+	return dataBytes, sigBytes, nil
+}
+
 func (a *Activity) getFabricClient(input *Input) (*FabricClient, error) {
-	if len(input.UserName) == 0 {
+	if len(input.UserName) == 0 && len(input.Certificate) == 0 {
 		logger.Error("user name is not specified")
 		return nil, errors.New("user name is not specified")
 	}
 
-	return NewFabricClient(ConnectorSpec{
-		Name:           a.connectionName,
-		NetworkConfig:  NetworkConfig,
-		EntityMatchers: EntityMatcher,
-		OrgName:        input.OrgName,
-		UserName:       input.UserName,
-		ChannelID:      a.channelID,
-		TimeoutMillis:  input.TimeoutMillis,
-		Endpoints:      input.Endpoints,
-		UserOrgOnly:    a.userOrgOnly,
-	})
+	config := NetworkConnector(a.connectionName)
+	config.OrgName = input.OrgName
+	config.UserName = input.UserName
+	config.ChannelID = a.channelID
+	config.UserOrgOnly = a.userOrgOnly
+	if input.callerIdentity() {
+		identity, err := a.callerIdentity(input, config.NetworkConfig)
+		if err != nil {
+			logger.Errorf("invalid caller identity: %v", err)
+			return nil, err
+		}
+		if len(input.Certificate) > 0 {
+			config.UserName = "caller"
+		}
+		config.OrgName = ""
+		config.Identity = identity
+		return NewSingleUseClient(config)
+	}
+	if a.wallet != nil {
+		// user name is the label of an identity in the wallet
+		label := input.UserName
+		if len(input.OrgName) > 0 {
+			label += "@" + input.OrgName
+		}
+		identity, err := a.wallet.Get(label)
+		if err != nil {
+			logger.Errorf("failed to get identity %s from wallet: %v", label, err)
+			return nil, errors.Wrapf(err, "Failed to get identity %s from wallet", label)
+		}
+		config.UserName = label
+		config.OrgName = ""
+		config.Identity = identity
+	}
+	return NewFabricClient(config)
+}
+
+// callerIdentity returns the identity supplied by the input to sign a single request,
+// i.e., the PEM certificate and private key, or the identity of the user name in the input wallet.
+// The input wallet must be registered by RegisterWallet or opened by the wallet setting of an activity.
+func (a *Activity) callerIdentity(input *Input, networkConfig []byte) (*WalletIdentity, error) {
+	if len(input.Certificate) == 0 {
+		wallet, ok := LookupWallet(input.Wallet)
+		if !ok {
+			return nil, errors.Errorf("Wallet %s is not registered or configured by activity settings", input.Wallet)
+		}
+		label := input.UserName
+		if len(input.OrgName) > 0 {
+			label += "@" + input.OrgName
+		}
+		identity, err := wallet.Get(label)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to get identity %s from wallet %s", label, input.Wallet)
+		}
+		if err := identity.verify(); err != nil {
+			return nil, err
+		}
+		return identity, nil
+	}
+
+	identity, err := a.creatorIdentity(input, networkConfig)
+	if err != nil {
+		return nil, err
+	}
+	identity.PrivateKey = DecodePEM(input.PrivateKey)
+	if err := identity.verify(); err != nil {
+		return nil, err
+	}
+	return identity, nil
+}
+
+// creatorIdentity returns the certificate and MSP ID of the input
+func (a *Activity) creatorIdentity(input *Input, networkConfig []byte) (*WalletIdentity, error) {
+	if len(input.Certificate) == 0 {
+		return nil, errors.New("certificate is not specified")
+	}
+	identity := &WalletIdentity{
+		MspID:       input.MspID,
+		Certificate: DecodePEM(input.Certificate),
+	}
+	if len(identity.MspID) == 0 && len(networkConfig) > 0 {
+		// use MSP of the org in the input, or the client org of the network config
+		network, err := parseNetworkOrgs(networkConfig)
+		if err != nil {
+			return nil, err
+		}
+		org := input.OrgName
+		if len(org) == 0 {
+			org = network.defaultOrg
+		}
+		identity.MspID = network.mspIDs[org]
+	}
+	return identity, nil
+}
+
+// DecodePEM returns a PEM string that may be base64 encoded, e.g., in an HTTP header
+func DecodePEM(value string) string {
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "-----BEGIN") {
+		return value
+	}
+	if data, err := base64.StdEncoding.DecodeString(value); err == nil {
+		return string(data)
+	}
+	return value
+}
+
+// requestOptions returns options of a chaincode request specified by the activity settings and input
+func (a *Activity) requestOptions(input *Input) []RequestOption {
+	opts := []RequestOption{WithTimeout(input.TimeoutMillis), WithRetry(a.retry)}
+	if len(input.Endpoints) > 0 {
+		opts = append(opts, WithEndpoints(input.Endpoints...))
+	}
+	if len(input.TargetOrgs) > 0 {
+		opts = append(opts, WithTargetOrgs(input.TargetOrgs...))
+	}
+	if policy := a.transactionPolicy(); len(policy) > 0 {
+		opts = append(opts, WithEndorsementPolicy(policy))
+	}
+	return opts
+}
+
+// transactionPolicy returns endorsement policy for request types that require endorsements,
+// so queries are still sent to a single peer.
+func (a *Activity) transactionPolicy() string {
+	if a.requestType == opInvoke || a.requestType == opSubmit || a.requestType == opSimulate || a.requestType == opEndorse {
+		return a.endorsementPolicy
+	}
+	return ""
 }
 
 func prepareTransient(transData map[string]interface{}) map[string][]byte {
@@ -0,0 +1,222 @@
+/*
+SPDX-License-Identifier: BSD-3-Clause-Open-MPI
+*/
+
+package request
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	mspproto "github.com/hyperledger/fabric-protos-go/msp"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel/invoke"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/common/filter"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/ledger"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/context"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	"github.com/pkg/errors"
+)
+
+// Backend sends chaincode requests of a FabricClient to a Fabric network.
+// It is implemented by fabric-sdk-go clients, and by MockBackend for offline tests.
+type Backend interface {
+	// Query sends a query request to endorsing peers
+	Query(request channel.Request, options ...channel.RequestOption) (Response, error)
+	// Execute endorses and commits a transaction, and waits for the commit event
+	Execute(request channel.Request, options ...channel.RequestOption) (Response, error)
+	// Submit endorses a transaction and sends it to orderer without waiting for the commit event
+	Submit(request channel.Request, options ...channel.RequestOption) (Response, error)
+	// Simulate collects endorsements of a transaction without sending it to orderer
+	Simulate(request channel.Request, options ...channel.RequestOption) (Response, error)
+	// TransactionStatus returns commit status of a transaction
+	TransactionStatus(txID string) (*TransactionStatus, error)
+}
+
+// Response contains the chaincode response of a Fabric request,
+// and the number of the block that commits the transaction if it is known.
+type Response struct {
+	channel.Response
+	BlockNumber uint64
+}
+
+// Endorser describes a peer that endorsed a transaction proposal
+type Endorser struct {
+	URL   string `json:"url"`
+	MSPID string `json:"mspid"`
+}
+
+// Endorsers returns URL and MSP ID of the peers that endorsed the transaction proposal
+func (r *Response) Endorsers() []*Endorser {
+	var result []*Endorser
+	for _, p := range r.Responses {
+		e := &Endorser{URL: p.Endorser}
+		if p.ProposalResponse != nil && p.ProposalResponse.Endorsement != nil {
+			id := &mspproto.SerializedIdentity{}
+			if err := proto.Unmarshal(p.ProposalResponse.Endorsement.Endorser, id); err == nil {
+				e.MSPID = id.Mspid
+			} else {
+				logger.Debugf("failed to unmarshal endorser identity of %s: %+v", p.Endorser, err)
+			}
+		}
+		result = append(result, e)
+	}
+	return result
+}
+
+// backends registered by connection name, which override the Fabric network of the same name
+var (
+	backendLock sync.RWMutex
+	backendMap  = map[string]Backend{}
+)
+
+// RegisterBackend registers a backend for a connection name, so all requests
+// of the connection will be dispatched to the backend instead of a Fabric network.
+func RegisterBackend(connectionName string, backend Backend) {
+	backendLock.Lock()
+	defer backendLock.Unlock()
+	backendMap[connectionName] = backend
+}
+
+// UnregisterBackend removes the backend registered for a connection name
+func UnregisterBackend(connectionName string) {
+	backendLock.Lock()
+	defer backendLock.Unlock()
+	delete(backendMap, connectionName)
+}
+
+func registeredBackend(connectionName string) (Backend, bool) {
+	backendLock.RLock()
+	defer backendLock.RUnlock()
+	b, ok := backendMap[connectionName]
+	return b, ok
+}
+
+// TransactionStatus describes the commit status of a submitted transaction
+type TransactionStatus struct {
+	TransactionID  string `json:"transactionID"`
+	Committed      bool   `json:"committed"`
+	ValidationCode string `json:"validationCode,omitempty"`
+	BlockNumber    uint64 `json:"blockNumber,omitempty"`
+}
+
+// ToMap converts transaction status to a map
+func (s *TransactionStatus) ToMap() map[string]interface{} {
+	result := map[string]interface{}{
+		"transactionID": s.TransactionID,
+		"committed":     s.Committed,
+	}
+	if s.Committed {
+		result["validationCode"] = s.ValidationCode
+		result["blockNumber"] = s.BlockNumber
+	}
+	return result
+}
+
+// sdkBackend sends chaincode requests to a Fabric network by using fabric-sdk-go clients
+type sdkBackend struct {
+	client          *channel.Client
+	channelProvider context.ChannelProvider
+	endorsingPeers  fab.TargetFilter
+	ledgerOnce      sync.Once
+	ledger          *ledger.Client
+	ledgerErr       error
+}
+
+func newSDKBackend(channelProvider context.ChannelProvider) (*sdkBackend, error) {
+	client, err := channel.New(channelProvider)
+	if err != nil {
+		return nil, err
+	}
+	chContext, err := channelProvider()
+	if err != nil {
+		return nil, err
+	}
+	return &sdkBackend{
+		client:          client,
+		channelProvider: channelProvider,
+		endorsingPeers:  filter.NewEndpointFilter(chContext, filter.EndorsingPeer),
+	}, nil
+}
+
+// ledger client is created on first use because it queries channel config from peers
+func (b *sdkBackend) ledgerClient() (*ledger.Client, error) {
+	b.ledgerOnce.Do(func() {
+		b.ledger, b.ledgerErr = ledger.New(b.channelProvider)
+	})
+	return b.ledger, b.ledgerErr
+}
+
+// Query implements Backend.Query
+func (b *sdkBackend) Query(request channel.Request, options ...channel.RequestOption) (Response, error) {
+	response, err := b.client.Query(request, options...)
+	return Response{Response: response}, err
+}
+
+// Execute implements Backend.Execute
+func (b *sdkBackend) Execute(request channel.Request, options ...channel.RequestOption) (Response, error) {
+	return b.invoke(&commitHandler{}, request, options...)
+}
+
+// Submit implements Backend.Submit
+func (b *sdkBackend) Submit(request channel.Request, options ...channel.RequestOption) (Response, error) {
+	return b.invoke(&submitHandler{}, request, options...)
+}
+
+// invoke collects and validates endorsements, and then calls the next handler to send the transaction
+func (b *sdkBackend) invoke(next invoke.Handler, request channel.Request, options ...channel.RequestOption) (Response, error) {
+	handler := invoke.NewSelectAndEndorseHandler(
+		invoke.NewEndorsementValidationHandler(
+			invoke.NewSignatureValidationHandler(next),
+		),
+	)
+	response, err := b.client.InvokeHandler(handler, request, b.targetOptions(options)...)
+	result := Response{Response: response}
+	if commit, ok := next.(*commitHandler); ok {
+		result.BlockNumber = commit.blockNumber
+	}
+	return result, err
+}
+
+// Simulate implements Backend.Simulate.
+// Endorsements are not validated against each other, so inconsistent endorsements can be reported in the simulation result.
+func (b *sdkBackend) Simulate(request channel.Request, options ...channel.RequestOption) (Response, error) {
+	handler := invoke.NewSelectAndEndorseHandler(
+		invoke.NewSignatureValidationHandler(&simulateHandler{}),
+	)
+	response, err := b.client.InvokeHandler(handler, request, b.targetOptions(options)...)
+	return Response{Response: response}, err
+}
+
+// targetOptions uses endorsing peers by default as channel.Client.Execute does; it is overridden by target options of the request
+func (b *sdkBackend) targetOptions(options []channel.RequestOption) []channel.RequestOption {
+	return append([]channel.RequestOption{channel.WithTargetFilter(b.endorsingPeers)}, options...)
+}
+
+// TransactionStatus implements Backend.TransactionStatus
+func (b *sdkBackend) TransactionStatus(txID string) (*TransactionStatus, error) {
+	client, err := b.ledgerClient()
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to create ledger client")
+	}
+	status := &TransactionStatus{TransactionID: txID}
+	tx, err := client.QueryTransaction(fab.TransactionID(txID))
+	if err != nil {
+		if strings.Contains(err.Error(), "no such transaction ID") {
+			// transaction is not committed yet
+			return status, nil
+		}
+		return nil, err
+	}
+	status.Committed = true
+	status.ValidationCode = pb.TxValidationCode(tx.ValidationCode).String()
+	block, err := client.QueryBlockByTxID(fab.TransactionID(txID))
+	if err != nil {
+		logger.Warnf("failed to query block of transaction %s: %+v", txID, err)
+	} else if block.Header != nil {
+		status.BlockNumber = block.Header.Number
+	}
+	return status, nil
+}
@@ -0,0 +1,320 @@
+/*
+SPDX-License-Identifier: BSD-3-Clause-Open-MPI
+*/
+
+package request
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	cb "github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset/kvrwset"
+	mspproto "github.com/hyperledger/fabric-protos-go/msp"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/pkg/errors"
+)
+
+// Block is a JSON friendly decoding of a Fabric block
+type Block struct {
+	Number       uint64         `json:"number"`
+	PreviousHash string         `json:"previousHash,omitempty"`
+	DataHash     string         `json:"dataHash,omitempty"`
+	ChannelID    string         `json:"channelID,omitempty"`
+	Filtered     bool           `json:"filtered"`
+	Transactions []*Transaction `json:"transactions"`
+}
+
+// Transaction is a JSON friendly decoding of a transaction in a Fabric block.
+// A filtered block contains only the transaction ID, type, validation code, chaincode ID and event names.
+type Transaction struct {
+	TxID           string            `json:"txID"`
+	Type           string            `json:"type"`
+	Timestamp      string            `json:"timestamp,omitempty"`
+	CreatorMSPID   string            `json:"creatorMSPID,omitempty"`
+	CreatorCert    string            `json:"creatorCert,omitempty"`
+	ChaincodeID    string            `json:"chaincodeID,omitempty"`
+	Function       string            `json:"function,omitempty"`
+	Args           []string          `json:"args,omitempty"`
+	ReadWriteSets  []*NsReadWriteSet `json:"rwsets,omitempty"`
+	EventNames     []string          `json:"eventNames,omitempty"`
+	ValidationCode string            `json:"validationCode"`
+}
+
+// NsReadWriteSet contains keys read and written by a transaction in a chaincode namespace
+type NsReadWriteSet struct {
+	Namespace string     `json:"namespace"`
+	Reads     []*KVRead  `json:"reads,omitempty"`
+	Writes    []*KVWrite `json:"writes,omitempty"`
+}
+
+// KVRead is a key read by a transaction, and the version of the key, i.e., block and transaction number of its last update
+type KVRead struct {
+	Key      string  `json:"key"`
+	BlockNum *uint64 `json:"blockNum,omitempty"`
+	TxNum    *uint64 `json:"txNum,omitempty"`
+}
+
+// KVWrite is a key written or deleted by a transaction
+type KVWrite struct {
+	Key      string `json:"key"`
+	Value    string `json:"value,omitempty"`
+	IsDelete bool   `json:"isDelete"`
+}
+
+// ToMap converts block to a JSON object for Flogo mapping
+func (b *Block) ToMap() (map[string]interface{}, error) {
+	result, err := toJSONObject(b)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to convert block %d to map", b.Number)
+	}
+	return result, nil
+}
+
+// ToMap converts transaction to a JSON object for Flogo mapping
+func (t *Transaction) ToMap() (map[string]interface{}, error) {
+	result, err := toJSONObject(t)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to convert transaction %s to map", t.TxID)
+	}
+	return result, nil
+}
+
+// toJSONObject converts a struct with JSON tags to a map
+func toJSONObject(v interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var result map[string]interface{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// DecodeBlock decodes header and transactions of a full block
+func DecodeBlock(block *cb.Block) (*Block, error) {
+	if block == nil || block.Header == nil {
+		return nil, errors.New("Failed to decode block: missing block header")
+	}
+	result := &Block{
+		Number:       block.Header.Number,
+		PreviousHash: hex.EncodeToString(block.Header.PreviousHash),
+		DataHash:     hex.EncodeToString(block.Header.DataHash),
+	}
+
+	// validation codes of transactions are set by committing peer in block metadata
+	var txFilter []byte
+	if block.Metadata != nil && len(block.Metadata.Metadata) > int(cb.BlockMetadataIndex_TRANSACTIONS_FILTER) {
+		txFilter = block.Metadata.Metadata[cb.BlockMetadataIndex_TRANSACTIONS_FILTER]
+	}
+
+	if block.Data == nil {
+		return result, nil
+	}
+	for i, data := range block.Data.Data {
+		envelope := &cb.Envelope{}
+		if err := proto.Unmarshal(data, envelope); err != nil {
+			return nil, errors.Wrapf(err, "Failed to unmarshal envelope %d of block %d", i, block.Header.Number)
+		}
+		tx, channelID, err := decodeEnvelope(envelope)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to decode transaction %d of block %d", i, block.Header.Number)
+		}
+		if i < len(txFilter) {
+			tx.ValidationCode = pb.TxValidationCode(txFilter[i]).String()
+		}
+		result.ChannelID = channelID
+		result.Transactions = append(result.Transactions, tx)
+	}
+	return result, nil
+}
+
+// DecodeFilteredBlock decodes transactions of a filtered block
+func DecodeFilteredBlock(block *pb.FilteredBlock) *Block {
+	result := &Block{
+		Number:    block.Number,
+		ChannelID: block.ChannelId,
+		Filtered:  true,
+	}
+	for _, ftx := range block.FilteredTransactions {
+		tx := &Transaction{
+			TxID:           ftx.Txid,
+			Type:           ftx.Type.String(),
+			ValidationCode: ftx.TxValidationCode.String(),
+		}
+		if actions := ftx.GetTransactionActions(); actions != nil {
+			for _, action := range actions.ChaincodeActions {
+				if ev := action.ChaincodeEvent; ev != nil {
+					tx.ChaincodeID = ev.ChaincodeId
+					tx.EventNames = append(tx.EventNames, ev.EventName)
+				}
+			}
+		}
+		result.Transactions = append(result.Transactions, tx)
+	}
+	return result
+}
+
+// DecodeProcessedTransaction decodes a transaction returned by ledger query, and its validation code
+func DecodeProcessedTransaction(ptx *pb.ProcessedTransaction) (*Transaction, error) {
+	if ptx == nil || ptx.TransactionEnvelope == nil {
+		return nil, errors.New("Failed to decode transaction: missing transaction envelope")
+	}
+	tx, _, err := decodeEnvelope(ptx.TransactionEnvelope)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to decode transaction")
+	}
+	tx.ValidationCode = pb.TxValidationCode(ptx.ValidationCode).String()
+	return tx, nil
+}
+
+// decodeEnvelope returns transaction and channel ID of an envelope in block data
+func decodeEnvelope(envelope *cb.Envelope) (*Transaction, string, error) {
+	payload := &cb.Payload{}
+	if err := proto.Unmarshal(envelope.Payload, payload); err != nil {
+		return nil, "", errors.Wrapf(err, "Failed to unmarshal payload")
+	}
+	if payload.Header == nil {
+		return nil, "", errors.New("missing payload header")
+	}
+	chHeader := &cb.ChannelHeader{}
+	if err := proto.Unmarshal(payload.Header.ChannelHeader, chHeader); err != nil {
+		return nil, "", errors.Wrapf(err, "Failed to unmarshal channel header")
+	}
+	tx := &Transaction{
+		TxID: chHeader.TxId,
+		Type: cb.HeaderType(chHeader.Type).String(),
+	}
+	if chHeader.Timestamp != nil {
+		if ts, err := ptypes.Timestamp(chHeader.Timestamp); err == nil {
+			tx.Timestamp = ts.UTC().Format(time.RFC3339Nano)
+		}
+	}
+
+	sigHeader := &cb.SignatureHeader{}
+	if err := proto.Unmarshal(payload.Header.SignatureHeader, sigHeader); err != nil {
+		return nil, "", errors.Wrapf(err, "Failed to unmarshal signature header")
+	}
+	creator := &mspproto.SerializedIdentity{}
+	if err := proto.Unmarshal(sigHeader.Creator, creator); err != nil {
+		return nil, "", errors.Wrapf(err, "Failed to unmarshal creator identity")
+	}
+	tx.CreatorMSPID = creator.Mspid
+	tx.CreatorCert = string(creator.IdBytes)
+
+	if cb.HeaderType(chHeader.Type) == cb.HeaderType_ENDORSER_TRANSACTION {
+		if err := decodeEndorserTransaction(payload.Data, tx); err != nil {
+			return nil, "", err
+		}
+	}
+	return tx, chHeader.ChannelId, nil
+}
+
+// decodeEndorserTransaction sets chaincode invocation, read/write sets and events of an endorser transaction
+func decodeEndorserTransaction(data []byte, tx *Transaction) error {
+	transaction := &pb.Transaction{}
+	if err := proto.Unmarshal(data, transaction); err != nil {
+		return errors.Wrapf(err, "Failed to unmarshal transaction")
+	}
+	for _, action := range transaction.Actions {
+		ccPayload := &pb.ChaincodeActionPayload{}
+		if err := proto.Unmarshal(action.Payload, ccPayload); err != nil {
+			return errors.Wrapf(err, "Failed to unmarshal chaincode action payload")
+		}
+
+		// chaincode name and args
+		proposalPayload := &pb.ChaincodeProposalPayload{}
+		if err := proto.Unmarshal(ccPayload.ChaincodeProposalPayload, proposalPayload); err != nil {
+			return errors.Wrapf(err, "Failed to unmarshal chaincode proposal payload")
+		}
+		spec := &pb.ChaincodeInvocationSpec{}
+		if err := proto.Unmarshal(proposalPayload.Input, spec); err != nil {
+			return errors.Wrapf(err, "Failed to unmarshal chaincode invocation spec")
+		}
+		if ccSpec := spec.ChaincodeSpec; ccSpec != nil {
+			if ccSpec.ChaincodeId != nil {
+				tx.ChaincodeID = ccSpec.ChaincodeId.Name
+			}
+			if ccSpec.Input != nil {
+				for i, arg := range ccSpec.Input.Args {
+					if i == 0 {
+						tx.Function = string(arg)
+					} else {
+						tx.Args = append(tx.Args, string(arg))
+					}
+				}
+			}
+		}
+
+		// read/write sets and events
+		if ccPayload.Action == nil {
+			continue
+		}
+		responsePayload := &pb.ProposalResponsePayload{}
+		if err := proto.Unmarshal(ccPayload.Action.ProposalResponsePayload, responsePayload); err != nil {
+			return errors.Wrapf(err, "Failed to unmarshal proposal response payload")
+		}
+		ccAction := &pb.ChaincodeAction{}
+		if err := proto.Unmarshal(responsePayload.Extension, ccAction); err != nil {
+			return errors.Wrapf(err, "Failed to unmarshal chaincode action")
+		}
+		rwsets, err := DecodeReadWriteSets(ccAction.Results)
+		if err != nil {
+			return err
+		}
+		tx.ReadWriteSets = append(tx.ReadWriteSets, rwsets...)
+		if len(ccAction.Events) > 0 {
+			event := &pb.ChaincodeEvent{}
+			if err := proto.Unmarshal(ccAction.Events, event); err != nil {
+				return errors.Wrapf(err, "Failed to unmarshal chaincode event")
+			}
+			if len(event.EventName) > 0 {
+				tx.EventNames = append(tx.EventNames, event.EventName)
+			}
+		}
+	}
+	return nil
+}
+
+// DecodeReadWriteSets decodes the public key/value read/write sets of a serialized TxReadWriteSet for each namespace
+func DecodeReadWriteSets(results []byte) ([]*NsReadWriteSet, error) {
+	if len(results) == 0 {
+		return nil, nil
+	}
+	txRWSet := &rwset.TxReadWriteSet{}
+	if err := proto.Unmarshal(results, txRWSet); err != nil {
+		return nil, errors.Wrapf(err, "Failed to unmarshal transaction read/write set")
+	}
+	var nsRWSets []*NsReadWriteSet
+	for _, ns := range txRWSet.NsRwset {
+		kvRWSet := &kvrwset.KVRWSet{}
+		if err := proto.Unmarshal(ns.Rwset, kvRWSet); err != nil {
+			return nil, errors.Wrapf(err, "Failed to unmarshal read/write set of namespace %s", ns.Namespace)
+		}
+		nsRWSet := &NsReadWriteSet{Namespace: ns.Namespace}
+		for _, r := range kvRWSet.Reads {
+			read := &KVRead{Key: r.Key}
+			if r.Version != nil {
+				blockNum, txNum := r.Version.BlockNum, r.Version.TxNum
+				read.BlockNum = &blockNum
+				read.TxNum = &txNum
+			}
+			nsRWSet.Reads = append(nsRWSet.Reads, read)
+		}
+		for _, w := range kvRWSet.Writes {
+			nsRWSet.Writes = append(nsRWSet.Writes, &KVWrite{
+				Key:      w.Key,
+				Value:    string(w.Value),
+				IsDelete: w.IsDelete,
+			})
+		}
+		nsRWSets = append(nsRWSets, nsRWSet)
+	}
+	return nsRWSets, nil
+}
@@ -0,0 +1,130 @@
+/*
+SPDX-License-Identifier: BSD-3-Clause-Open-MPI
+*/
+
+package request
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	cb "github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset/kvrwset"
+	mspproto "github.com/hyperledger/fabric-protos-go/msp"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// returns serialized envelope of an endorser transaction that invokes basic.TransferAsset(asset1, Jose)
+func testEnvelope(t *testing.T) []byte {
+	marshal := func(m proto.Message) []byte {
+		data, err := proto.Marshal(m)
+		require.NoError(t, err, "marshal proto message should not throw error")
+		return data
+	}
+
+	kvRWSet := &kvrwset.KVRWSet{
+		Reads:  []*kvrwset.KVRead{{Key: "asset1", Version: &kvrwset.Version{BlockNum: 3, TxNum: 1}}},
+		Writes: []*kvrwset.KVWrite{{Key: "asset1", Value: []byte(`{"ID":"asset1","owner":"Jose"}`)}},
+	}
+	txRWSet := &rwset.TxReadWriteSet{
+		DataModel: rwset.TxReadWriteSet_KV,
+		NsRwset:   []*rwset.NsReadWriteSet{{Namespace: "basic", Rwset: marshal(kvRWSet)}},
+	}
+	ccAction := &pb.ChaincodeAction{
+		Results: marshal(txRWSet),
+		Events:  marshal(&pb.ChaincodeEvent{ChaincodeId: "basic", TxId: "tx1", EventName: "TransferAsset"}),
+	}
+	spec := &pb.ChaincodeInvocationSpec{ChaincodeSpec: &pb.ChaincodeSpec{
+		ChaincodeId: &pb.ChaincodeID{Name: "basic"},
+		Input:       &pb.ChaincodeInput{Args: [][]byte{[]byte("TransferAsset"), []byte("asset1"), []byte("Jose")}},
+	}}
+	ccPayload := &pb.ChaincodeActionPayload{
+		ChaincodeProposalPayload: marshal(&pb.ChaincodeProposalPayload{Input: marshal(spec)}),
+		Action: &pb.ChaincodeEndorsedAction{
+			ProposalResponsePayload: marshal(&pb.ProposalResponsePayload{Extension: marshal(ccAction)}),
+		},
+	}
+	tx := &pb.Transaction{Actions: []*pb.TransactionAction{{Payload: marshal(ccPayload)}}}
+
+	chHeader := &cb.ChannelHeader{
+		Type:      int32(cb.HeaderType_ENDORSER_TRANSACTION),
+		ChannelId: "mychannel",
+		TxId:      "tx1",
+		Timestamp: ptypes.TimestampNow(),
+	}
+	creator := &mspproto.SerializedIdentity{Mspid: "Org1MSP", IdBytes: []byte("-----BEGIN CERTIFICATE-----")}
+	payload := &cb.Payload{
+		Header: &cb.Header{
+			ChannelHeader:   marshal(chHeader),
+			SignatureHeader: marshal(&cb.SignatureHeader{Creator: marshal(creator)}),
+		},
+		Data: marshal(tx),
+	}
+	return marshal(&cb.Envelope{Payload: marshal(payload)})
+}
+
+func TestDecodeBlock(t *testing.T) {
+	metadata := make([][]byte, len(cb.BlockMetadataIndex_name))
+	metadata[cb.BlockMetadataIndex_TRANSACTIONS_FILTER] = []byte{byte(pb.TxValidationCode_MVCC_READ_CONFLICT)}
+	block := &cb.Block{
+		Header:   &cb.BlockHeader{Number: 5, PreviousHash: []byte{0xab, 0xcd}},
+		Data:     &cb.BlockData{Data: [][]byte{testEnvelope(t)}},
+		Metadata: &cb.BlockMetadata{Metadata: metadata},
+	}
+
+	result, err := DecodeBlock(block)
+	require.NoError(t, err, "decode block should not throw error")
+	assert.Equal(t, uint64(5), result.Number, "block number should be 5")
+	assert.Equal(t, "abcd", result.PreviousHash, "previous hash should be hex encoded")
+	assert.Equal(t, "mychannel", result.ChannelID, "channel ID should be decoded")
+	require.Equal(t, 1, len(result.Transactions), "block should contain 1 transaction")
+
+	tx := result.Transactions[0]
+	assert.Equal(t, "tx1", tx.TxID, "transaction ID should be decoded")
+	assert.Equal(t, "ENDORSER_TRANSACTION", tx.Type, "transaction type should be decoded")
+	assert.Equal(t, "Org1MSP", tx.CreatorMSPID, "creator MSP should be decoded")
+	assert.Equal(t, "basic", tx.ChaincodeID, "chaincode ID should be decoded")
+	assert.Equal(t, "TransferAsset", tx.Function, "function should be the first chaincode arg")
+	assert.Equal(t, []string{"asset1", "Jose"}, tx.Args, "args should be decoded")
+	assert.Equal(t, []string{"TransferAsset"}, tx.EventNames, "chaincode event should be decoded")
+	assert.Equal(t, "MVCC_READ_CONFLICT", tx.ValidationCode, "validation code should be decoded from block metadata")
+
+	require.Equal(t, 1, len(tx.ReadWriteSets), "transaction should have read/write set of 1 namespace")
+	rwset := tx.ReadWriteSets[0]
+	assert.Equal(t, "basic", rwset.Namespace, "namespace should be basic")
+	assert.Equal(t, uint64(3), *rwset.Reads[0].BlockNum, "version of read key should be decoded")
+	assert.Equal(t, `{"ID":"asset1","owner":"Jose"}`, rwset.Writes[0].Value, "written value should be decoded")
+
+	data, err := result.ToMap()
+	require.NoError(t, err, "convert block to map should not throw error")
+	txs, ok := data["transactions"].([]interface{})
+	require.True(t, ok, "transactions should be an array")
+	assert.Equal(t, "tx1", txs[0].(map[string]interface{})["txID"], "transaction map should contain txID")
+}
+
+func TestDecodeFilteredBlock(t *testing.T) {
+	block := &pb.FilteredBlock{
+		ChannelId: "mychannel",
+		Number:    7,
+		FilteredTransactions: []*pb.FilteredTransaction{{
+			Txid:             "tx2",
+			Type:             cb.HeaderType_ENDORSER_TRANSACTION,
+			TxValidationCode: pb.TxValidationCode_VALID,
+			Data: &pb.FilteredTransaction_TransactionActions{TransactionActions: &pb.FilteredTransactionActions{
+				ChaincodeActions: []*pb.FilteredChaincodeAction{{ChaincodeEvent: &pb.ChaincodeEvent{ChaincodeId: "basic", EventName: "CreateAsset"}}},
+			}},
+		}},
+	}
+
+	result := DecodeFilteredBlock(block)
+	assert.True(t, result.Filtered, "block should be filtered")
+	assert.Equal(t, uint64(7), result.Number, "block number should be 7")
+	require.Equal(t, 1, len(result.Transactions), "block should contain 1 transaction")
+	assert.Equal(t, "VALID", result.Transactions[0].ValidationCode, "validation code should be decoded")
+	assert.Equal(t, "basic", result.Transactions[0].ChaincodeID, "chaincode ID should be decoded from event")
+	assert.Equal(t, []string{"CreateAsset"}, result.Transactions[0].EventNames, "event name should be decoded")
+}
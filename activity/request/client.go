@@ -9,34 +9,54 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
 
 	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
-	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/retry"
 	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/core"
 	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/msp"
 	"github.com/hyperledger/fabric-sdk-go/pkg/core/config"
 	"github.com/hyperledger/fabric-sdk-go/pkg/fabsdk"
-	yaml "gopkg.in/yaml.v2"
 )
 
 const (
 	configType = "yaml"
 )
 
-// cached Fabric client connections
-var clientMap = map[string]*FabricClient{}
+// fabric-sdk-go instance of each connection, and holders of each SDK
+var (
+	sdkLock sync.Mutex
+	sdkMap  = map[string]*fabsdk.FabricSDK{}
+	sdkRefs = map[*fabsdk.FabricSDK]*sdkRef{}
+)
+
+// sdkRef counts the holders of an SDK, so an SDK replaced by a reloaded network config is closed after all holders release it
+type sdkRef struct {
+	name    string
+	refs    int
+	retired bool
+}
 
 // FabricClient holds fabric client pointers for chaincode invocations.
+// It is shared by concurrent requests, and so it holds only connection state that does not change after it is created.
+// Options of a single request are passed to each chaincode call as RequestOption.
 type FabricClient struct {
-	name          string
-	sdk           *fabsdk.FabricSDK
-	client        *channel.Client
-	timeoutMillis int
-	endpoints     []string
-	filter        fab.TargetFilter
+	name      string
+	client    Backend
+	channelID string
+	// MSP ID of the user's org
+	mspID   string
+	filter  fab.TargetFilter
+	network *networkOrgs
+	// endorsement policy => peers that satisfy the policy
+	policyPeers sync.Map
+	// shared SDK held by the client, which is released when the client is closed
+	sdk *fabsdk.FabricSDK
+	// references of in-flight requests and callers holding the client, so Close waits for them to release the client
+	clientRefs
 }
 
 // ConnectorSpec contains configuration parameters of a Fabric connector
@@ -47,9 +67,65 @@ type ConnectorSpec struct {
 	OrgName        string
 	UserName       string
 	ChannelID      string
-	TimeoutMillis  int
-	Endpoints      []string
 	UserOrgOnly    bool
+	// Identity is used instead of the user in the cryptoPath of the network config if it is selected from a wallet or supplied by the caller
+	Identity *WalletIdentity
+}
+
+// RequestOption sets an option of a single chaincode request
+type RequestOption func(*requestOptions)
+
+type requestOptions struct {
+	timeoutMillis     int
+	endpoints         []string
+	targetOrgs        []string
+	endorsementPolicy string
+	retry             *RetryPolicy
+}
+
+// WithTimeout sets the wait time for responses from the Fabric network
+func WithTimeout(millis int) RequestOption {
+	return func(o *requestOptions) {
+		o.timeoutMillis = millis
+	}
+}
+
+// WithEndpoints sends the request to the specified peers only.
+// It takes precedence over the target orgs and the endorsement policy.
+func WithEndpoints(endpoints ...string) RequestOption {
+	return func(o *requestOptions) {
+		o.endpoints = endpoints
+	}
+}
+
+// WithTargetOrgs sends the request to peers of org names or MSP IDs only
+func WithTargetOrgs(orgs ...string) RequestOption {
+	return func(o *requestOptions) {
+		o.targetOrgs = orgs
+	}
+}
+
+// WithEndorsementPolicy, e.g., AND('Org1MSP.peer','Org2MSP.peer'), sends the request to the smallest set of peers that satisfies the policy.
+// It takes precedence over the target orgs.
+func WithEndorsementPolicy(policy string) RequestOption {
+	return func(o *requestOptions) {
+		o.endorsementPolicy = policy
+	}
+}
+
+// WithRetry configures retries of the request; default retry options of fabric-sdk-go are used if it is not specified
+func WithRetry(policy *RetryPolicy) RequestOption {
+	return func(o *requestOptions) {
+		o.retry = policy
+	}
+}
+
+func newRequestOptions(options []RequestOption) *requestOptions {
+	opts := &requestOptions{}
+	for _, o := range options {
+		o(opts)
+	}
+	return opts
 }
 
 // OrgFilter implements TargetFilter interface for target peers
@@ -62,24 +138,64 @@ func (f *OrgFilter) Accept(peer fab.Peer) bool {
 	return peer.MSPID() == f.MSPID
 }
 
-func (c *FabricClient) setOrgFilter(config ConnectorSpec) {
-	var data map[interface{}]interface{}
-	yaml.Unmarshal(config.NetworkConfig, &data)
-	msps := make(map[string]string)
-	defaultOrg := execYamlPath(data, "client.organization")
-	for k, v := range data["organizations"].(map[interface{}]interface{}) {
-		mspid := v.(map[interface{}]interface{})["mspid"]
-		msps[k.(string)] = mspid.(string)
+// setNetwork parses orgs and peers of the network config, and sets the peer filter of the user's org if userOrgOnly is true
+func (c *FabricClient) setNetwork(config ConnectorSpec) error {
+	if len(config.NetworkConfig) == 0 {
+		return nil
 	}
-
-	orgName := defaultOrg.(string)
+	network, err := parseNetworkOrgs(config.NetworkConfig)
+	if err != nil {
+		return err
+	}
+	c.network = network
+	orgName := network.defaultOrg
 	if len(config.OrgName) > 0 {
 		orgName = config.OrgName
 	}
+	mspid, ok := network.mspIDs[orgName]
+	if config.Identity != nil {
+		mspid, ok = config.Identity.MspID, true
+	}
+	if ok {
+		c.mspID = mspid
+		if config.UserOrgOnly {
+			c.filter = &OrgFilter{MSPID: mspid}
+		}
+	}
+	return nil
+}
+
+// targets returns target peers of a request selected by endpoints, endorsement policy, or org names or MSP IDs, in the order of precedence.
+// It returns the peer filter of the user's org if userOrgOnly is set and none of the target options is specified.
+func (c *FabricClient) targets(opts *requestOptions) ([]string, fab.TargetFilter, error) {
+	if len(opts.endpoints) > 0 {
+		return opts.endpoints, nil, nil
+	}
+	if len(opts.endorsementPolicy) > 0 || len(opts.targetOrgs) > 0 {
+		if c.network == nil {
+			return nil, nil, errors.New("Network config is required to select target peers")
+		}
+		if len(opts.endorsementPolicy) > 0 {
+			peers, err := c.endorsementPeers(opts.endorsementPolicy)
+			return peers, nil, err
+		}
+		return nil, c.network.mspFilter(opts.targetOrgs), nil
+	}
+	return nil, c.filter, nil
+}
 
-	if mspid, ok := msps[orgName]; ok && len(mspid) > 0 {
-		c.filter = &OrgFilter{MSPID: mspid}
+// endorsementPeers returns peers that satisfy an endorsement policy, which are cached for the policy
+func (c *FabricClient) endorsementPeers(policy string) ([]string, error) {
+	if peers, ok := c.policyPeers.Load(policy); ok {
+		return peers.([]string), nil
+	}
+	peers, err := c.network.policyPeers(policy, c.channelID, c.mspID)
+	if err != nil {
+		return nil, err
 	}
+	logger.Debugf("selected peers %v for endorsement policy %s", peers, policy)
+	c.policyPeers.Store(policy, peers)
+	return peers, nil
 }
 
 // return value at path c1.c2.c3 from yaml file, does not handle arrays
@@ -105,42 +221,227 @@ func yamlChildNode(parent interface{}, name string) (interface{}, bool) {
 	return c, ok
 }
 
-// NewFabricClient returns a new or cached fabric client
+// NewFabricClient returns a new or cached fabric client.
+// The client is held for the caller, so it is not closed by cache eviction before the caller sends its requests.
+// The caller must call Release when its requests complete.
 func NewFabricClient(config ConnectorSpec) (*FabricClient, error) {
-	clientKey := fmt.Sprintf("%s.%s.%s.%t", config.Name, config.UserName, config.OrgName, config.UserOrgOnly)
-	if fbClient, ok := clientMap[clientKey]; ok && fbClient != nil {
-		fbClient.timeoutMillis = config.TimeoutMillis
-		fbClient.endpoints = config.Endpoints
-		return fbClient, nil
+	if backend, ok := registeredBackend(config.Name); ok {
+		// use backend registered for the connection, e.g., MockBackend for offline tests
+		client, err := newBackendClient(config, backend)
+		if err != nil {
+			return nil, err
+		}
+		client.retain()
+		return client, nil
 	}
-	sdk, err := fabsdk.New(networkConfigProvider(config.NetworkConfig, config.EntityMatchers))
+
+	client, err := clientRegistry.GetOrCreate(clientKey(config), func() (CachedClient, error) {
+		return newSDKClient(config)
+	})
 	if err != nil {
-		return nil, errors.Wrapf(err, "Failed to create new SDK")
+		return nil, err
+	}
+	return client.(*FabricClient), nil
+}
+
+// NewSingleUseClient returns a Fabric client that signs requests by the identity of the connector spec.
+// The client is not cached, so the private key of the identity is not kept after the caller closes the client.
+func NewSingleUseClient(config ConnectorSpec) (*FabricClient, error) {
+	if config.Identity == nil {
+		return nil, errors.New("Identity of single-use client is not specified")
+	}
+	if backend, ok := registeredBackend(config.Name); ok {
+		return newBackendClient(config, backend)
+	}
+	return newSDKClient(config)
+}
+
+// newBackendClient creates a Fabric client that sends requests to a registered backend
+func newBackendClient(config ConnectorSpec, backend Backend) (*FabricClient, error) {
+	fbClient := &FabricClient{
+		name:      config.Name,
+		client:    backend,
+		channelID: config.ChannelID,
 	}
+	if err := fbClient.setNetwork(config); err != nil {
+		return nil, err
+	}
+	return fbClient, nil
+}
 
+// clientKey identifies the cached client of a channel context for a user
+func clientKey(config ConnectorSpec) string {
+	key := fmt.Sprintf("%s.%s.%s.%s.%t", config.Name, config.ChannelID, config.UserName, config.OrgName, config.UserOrgOnly)
+	if config.Identity != nil {
+		key += "." + config.Identity.fingerprint()
+	}
+	return key
+}
+
+// newSDKClient creates a Fabric client for a channel context of the SDK shared by the connection.
+// The client holds the SDK until it is closed.
+func newSDKClient(config ConnectorSpec) (*FabricClient, error) {
+	sdk, err := SharedSDK(config)
+	if err != nil {
+		return nil, err
+	}
+	fbClient, err := newChannelClient(sdk, config)
+	if err != nil {
+		ReleaseSDK(sdk)
+		return nil, err
+	}
+	return fbClient, nil
+}
+
+// newChannelClient creates a Fabric client for a channel context of an SDK
+func newChannelClient(sdk *fabsdk.FabricSDK, config ConnectorSpec) (*FabricClient, error) {
 	opts := []fabsdk.ContextOption{fabsdk.WithUser(config.UserName)}
 	if config.OrgName != "" {
 		opts = append(opts, fabsdk.WithOrg(config.OrgName))
 	}
-	client, err := channel.New(sdk.ChannelContext(config.ChannelID, opts...))
+	if config.Identity != nil {
+		identity, err := signingIdentity(sdk, config)
+		if err != nil {
+			return nil, err
+		}
+		opts = []fabsdk.ContextOption{fabsdk.WithIdentity(identity)}
+	}
+	client, err := newSDKBackend(sdk.ChannelContext(config.ChannelID, opts...))
 	if err != nil {
 		return nil, errors.Wrapf(err, "Failed to create new client of channel %s", config.ChannelID)
 	}
 	fbClient := &FabricClient{
-		name:          config.Name,
-		sdk:           sdk,
-		client:        client,
-		timeoutMillis: config.TimeoutMillis,
-		endpoints:     config.Endpoints,
+		name:      config.Name,
+		client:    client,
+		channelID: config.ChannelID,
+		sdk:       sdk,
 	}
-	if config.UserOrgOnly {
-		fbClient.setOrgFilter(config)
+	if err := fbClient.setNetwork(config); err != nil {
+		return nil, err
 	}
-	clientMap[clientKey] = fbClient
-
 	return fbClient, nil
 }
 
+// signingIdentity creates a signing identity from the certificate and private key of a wallet or caller-supplied identity.
+// The private key is imported as an ephemeral key, so it is not stored in the keystore of the SDK.
+func signingIdentity(sdk *fabsdk.FabricSDK, config ConnectorSpec) (msp.SigningIdentity, error) {
+	network, err := parseNetworkOrgs(config.NetworkConfig)
+	if err != nil {
+		return nil, err
+	}
+	orgName, ok := network.orgName(config.Identity.MspID)
+	if !ok {
+		return nil, errors.Errorf("MSP %s of identity %s is not in the network config", config.Identity.MspID, config.UserName)
+	}
+	ctx, err := sdk.Context()()
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to create SDK context")
+	}
+	mgr, ok := ctx.IdentityManager(orgName)
+	if !ok {
+		return nil, errors.Errorf("Identity manager of org %s is not found", orgName)
+	}
+	identity, err := mgr.CreateSigningIdentity(msp.WithCert([]byte(config.Identity.Certificate)), msp.WithPrivateKey([]byte(config.Identity.PrivateKey)))
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to create signing identity of %s", config.UserName)
+	}
+	return identity, nil
+}
+
+// SharedSDK returns the fabric-sdk-go instance of a connection, which is shared by all users and channels of the connection.
+// The SDK is held for the caller, who must call ReleaseSDK when it no longer uses the SDK or the clients created from it.
+// An SDK replaced by a reloaded network config is closed after all holders release it.
+// Shared SDKs are closed when the Flogo engine stops.
+func SharedSDK(config ConnectorSpec) (*fabsdk.FabricSDK, error) {
+	sdkLock.Lock()
+	defer sdkLock.Unlock()
+	sdk, ok := sdkMap[config.Name]
+	if !ok {
+		var err error
+		if sdk, err = NewSDK(config); err != nil {
+			return nil, err
+		}
+		logger.Infof("created SDK for connection %s", config.Name)
+		sdkMap[config.Name] = sdk
+		sdkRefs[sdk] = &sdkRef{name: config.Name}
+	}
+	sdkRefs[sdk].refs++
+	return sdk, nil
+}
+
+// RetainSDK holds an SDK returned by SharedSDK for another holder, e.g., a request using a cached client of the SDK.
+// The caller must already hold the SDK, so it is not closed.
+func RetainSDK(sdk *fabsdk.FabricSDK) {
+	sdkLock.Lock()
+	defer sdkLock.Unlock()
+	if ref, ok := sdkRefs[sdk]; ok {
+		ref.refs++
+	}
+}
+
+// ReleaseSDK releases an SDK held by SharedSDK or RetainSDK, and closes it if it is replaced by a reloaded network config and no longer held
+func ReleaseSDK(sdk *fabsdk.FabricSDK) {
+	sdkLock.Lock()
+	ref, ok := sdkRefs[sdk]
+	if !ok {
+		sdkLock.Unlock()
+		return
+	}
+	ref.refs--
+	closing := ref.retired && ref.refs <= 0
+	if closing {
+		delete(sdkRefs, sdk)
+	}
+	sdkLock.Unlock()
+	if closing {
+		logger.Infof("close SDK of previous network config of connection %s", ref.name)
+		sdk.Close()
+	}
+}
+
+// retireSDK removes the shared SDK of a connection, so new clients use a new SDK of the current network config.
+// The retired SDK is closed in background if it is not held, or else by ReleaseSDK when the last holder releases it.
+func retireSDK(connectionName string) {
+	sdkLock.Lock()
+	defer sdkLock.Unlock()
+	sdk, ok := sdkMap[connectionName]
+	if !ok {
+		return
+	}
+	delete(sdkMap, connectionName)
+	ref := sdkRefs[sdk]
+	ref.retired = true
+	if ref.refs <= 0 {
+		delete(sdkRefs, sdk)
+		go func() {
+			logger.Infof("close SDK of previous network config of connection %s", connectionName)
+			sdk.Close()
+		}()
+	}
+}
+
+// closeSharedSDKs closes SDKs of all connections, including retired SDKs that are still held
+func closeSharedSDKs() {
+	sdkLock.Lock()
+	defer sdkLock.Unlock()
+	for sdk, ref := range sdkRefs {
+		logger.Infof("close SDK for connection %s", ref.name)
+		sdk.Close()
+	}
+	sdkMap = make(map[string]*fabsdk.FabricSDK)
+	sdkRefs = make(map[*fabsdk.FabricSDK]*sdkRef)
+}
+
+// NewSDK returns a new fabric-sdk-go instance for the network config of a connector spec.
+// Triggers use it to create SDK clients, e.g., event client, for the same Fabric network as the request activity.
+func NewSDK(config ConnectorSpec) (*fabsdk.FabricSDK, error) {
+	sdk, err := fabsdk.New(networkConfigProvider(config.NetworkConfig, config.EntityMatchers))
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to create new SDK")
+	}
+	return sdk, nil
+}
+
 func networkConfigProvider(networkConfig []byte, entityMatcherOverride []byte) core.ConfigProvider {
 	configProvider := config.FromRaw(networkConfig, configType)
 
@@ -167,49 +468,125 @@ func networkConfigProvider(networkConfig []byte, entityMatcherOverride []byte) c
 	return configProvider
 }
 
-// Close closes Fabric client connection
+// Close releases the Fabric client after in-flight requests complete, and callers holding the client release it.
+// The SDK of the connection is released, but it is closed only if it is replaced by a reloaded network config and no other client holds it.
 func (c *FabricClient) Close() {
-	c.sdk.Close()
+	if c.closeWait() && c.sdk != nil {
+		ReleaseSDK(c.sdk)
+	}
+}
+
+// Release releases the client held for the caller of NewFabricClient
+func (c *FabricClient) Release() {
+	c.release()
+}
+
+// ConnectionName returns the name of the connection of the client
+func (c *FabricClient) ConnectionName() string {
+	return c.name
+}
+
+// acquire marks the start of a request, and returns error if the client is closed.
+// The caller must call c.release() when the request completes.
+func (c *FabricClient) acquire() error {
+	if !c.clientRefs.acquire() {
+		return errors.Errorf("Fabric client %s is closed", c.name)
+	}
+	return nil
 }
 
 // QueryChaincode sends query request to Fabric network
-func (c *FabricClient) QueryChaincode(ccID, fcn string, args [][]byte, transient map[string][]byte) ([]byte, int, error) {
-	opts := []channel.RequestOption{channel.WithRetry(retry.DefaultChannelOpts)}
-	if c.timeoutMillis > 0 {
-		//		fmt.Printf("set request timeout: %d ms\n", c.timeoutMillis)
-		opts = append(opts, channel.WithTimeout(fab.Query, time.Duration(c.timeoutMillis)*time.Millisecond))
-	}
-	if c.endpoints != nil && len(c.endpoints) > 0 {
-		//		fmt.Printf("set target endpoints: %s\n", strings.Join(c.endpoints, ", "))
-		opts = append(opts, channel.WithTargetEndpoints(c.endpoints...))
-	} else if c.filter != nil {
-		opts = append(opts, channel.WithTargetFilter(c.filter))
-	}
-	response, err := c.client.Query(channel.Request{ChaincodeID: ccID, Fcn: fcn, Args: args, TransientMap: transient}, opts...)
+func (c *FabricClient) QueryChaincode(ccID, fcn string, args [][]byte, transient map[string][]byte, options ...RequestOption) (Response, error) {
+	if err := c.acquire(); err != nil {
+		return Response{}, err
+	}
+	defer c.release()
+	opts, err := c.channelOptions(fab.Query, newRequestOptions(options))
+	if err != nil {
+		return Response{}, err
+	}
+	return c.client.Query(channel.Request{ChaincodeID: ccID, Fcn: fcn, Args: args, TransientMap: transient}, opts...)
+}
+
+// ExecuteChaincode sends invocation request to Fabric network, and waits for the transaction to commit.
+// If ResubmitOnConflict of the retry policy is set, a transaction invalidated by read conflict is endorsed and submitted again.
+func (c *FabricClient) ExecuteChaincode(ccID, fcn string, args [][]byte, transient map[string][]byte, options ...RequestOption) (Response, error) {
+	if err := c.acquire(); err != nil {
+		return Response{}, err
+	}
+	defer c.release()
+	reqOpts := newRequestOptions(options)
+	opts, err := c.channelOptions(fab.Execute, reqOpts)
 	if err != nil {
-		return nil, 500, err
+		return Response{}, err
+	}
+	request := channel.Request{ChaincodeID: ccID, Fcn: fcn, Args: args, TransientMap: transient}
+	response, err := c.client.Execute(request, opts...)
+	if reqOpts.retry == nil || !reqOpts.retry.ResubmitOnConflict {
+		return response, err
 	}
-	return response.Payload, int(response.ChaincodeStatus), nil
+	attempts := reqOpts.retry.channelOpts().Attempts
+	for i := 0; i < attempts && isConflictError(err); i++ {
+		backoff := reqOpts.retry.backoff(i)
+		logger.Infof("resubmit %s.%s in %s after transaction %s is invalidated by %s", ccID, fcn, backoff, response.TransactionID, response.TxValidationCode)
+		time.Sleep(backoff)
+		response, err = c.client.Execute(request, opts...)
+	}
+	return response, err
 }
 
-// ExecuteChaincode sends invocation request to Fabric network
-func (c *FabricClient) ExecuteChaincode(ccID, fcn string, args [][]byte, transient map[string][]byte) ([]byte, int, error) {
-	opts := []channel.RequestOption{channel.WithRetry(retry.DefaultChannelOpts)}
-	if c.timeoutMillis > 0 {
-		//		fmt.Printf("set request timeout: %d ms\n", c.timeoutMillis)
-		opts = append(opts, channel.WithTimeout(fab.Execute, time.Duration(c.timeoutMillis)*time.Millisecond))
+// SubmitChaincode sends invocation request to Fabric network, and returns the transaction ID
+// after the endorsed transaction is sent to orderer, without waiting for the transaction to commit.
+func (c *FabricClient) SubmitChaincode(ccID, fcn string, args [][]byte, transient map[string][]byte, options ...RequestOption) (Response, error) {
+	if err := c.acquire(); err != nil {
+		return Response{}, err
 	}
-	if c.endpoints != nil && len(c.endpoints) > 0 {
-		//		fmt.Printf("set target endpoints: %s\n", strings.Join(c.endpoints, ", "))
-		opts = append(opts, channel.WithTargetEndpoints(c.endpoints...))
-	} else if c.filter != nil {
-		opts = append(opts, channel.WithTargetFilter(c.filter))
+	defer c.release()
+	opts, err := c.channelOptions(fab.Execute, newRequestOptions(options))
+	if err != nil {
+		return Response{}, err
 	}
-	response, err := c.client.Execute(channel.Request{ChaincodeID: ccID, Fcn: fcn, Args: args, TransientMap: transient}, opts...)
+	return c.client.Submit(channel.Request{ChaincodeID: ccID, Fcn: fcn, Args: args, TransientMap: transient}, opts...)
+}
+
+// SimulateChaincode sends invocation request to endorsing peers, and returns the endorsements without sending the transaction to orderer
+func (c *FabricClient) SimulateChaincode(ccID, fcn string, args [][]byte, transient map[string][]byte, options ...RequestOption) (Response, error) {
+	if err := c.acquire(); err != nil {
+		return Response{}, err
+	}
+	defer c.release()
+	opts, err := c.channelOptions(fab.Execute, newRequestOptions(options))
 	if err != nil {
-		return nil, 500, err
+		return Response{}, err
+	}
+	return c.client.Simulate(channel.Request{ChaincodeID: ccID, Fcn: fcn, Args: args, TransientMap: transient}, opts...)
+}
+
+// TransactionStatus returns commit status and validation code of a submitted transaction
+func (c *FabricClient) TransactionStatus(txID string) (*TransactionStatus, error) {
+	if err := c.acquire(); err != nil {
+		return nil, err
+	}
+	defer c.release()
+	return c.client.TransactionStatus(txID)
+}
+
+// channelOptions converts options of a request to options of fabric-sdk-go channel client
+func (c *FabricClient) channelOptions(timeoutType fab.TimeoutType, options *requestOptions) ([]channel.RequestOption, error) {
+	opts := []channel.RequestOption{channel.WithRetry(options.retry.channelOpts())}
+	if options.timeoutMillis > 0 {
+		opts = append(opts, channel.WithTimeout(timeoutType, time.Duration(options.timeoutMillis)*time.Millisecond))
+	}
+	endpoints, filter, err := c.targets(options)
+	if err != nil {
+		return nil, err
+	}
+	if len(endpoints) > 0 {
+		opts = append(opts, channel.WithTargetEndpoints(endpoints...))
+	} else if filter != nil {
+		opts = append(opts, channel.WithTargetFilter(filter))
 	}
-	return response.Payload, int(response.ChaincodeStatus), nil
+	return opts, nil
 }
 
 // ReadFile returns content of a specified file
@@ -8,6 +8,12 @@ import (
 	"os"
 	"testing"
 
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset/kvrwset"
+	mspproto "github.com/hyperledger/fabric-protos-go/msp"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -41,29 +47,32 @@ func TestClient(t *testing.T) {
 		ChannelID:      channelID,
 	})
 	require.NoError(t, err, "failed to create fabric client %s", connectorName)
+	defer fbClient.Release()
 	logger.Infof("created fabric client %+v", fbClient)
 
 	// initialize ledger
-	result, _, err := fbClient.ExecuteChaincode(ccID, "InitLedger", [][]byte{}, nil)
+	result, err := fbClient.ExecuteChaincode(ccID, "InitLedger", [][]byte{}, nil)
 	require.NoError(t, err, "failed to invoke %s", ccID)
-	logger.Infof("InitLedger result: %s", string(result))
+	logger.Infof("InitLedger result: %s", string(result.Payload))
 
 	// query original
-	result, _, err = fbClient.QueryChaincode(ccID, "ReadAsset", [][]byte{[]byte("asset6")}, nil)
+	result, err = fbClient.QueryChaincode(ccID, "ReadAsset", [][]byte{[]byte("asset6")}, nil)
 	require.NoError(t, err, "failed to query %s", ccID)
-	logger.Infof("Query asset6 result: %s", string(result))
-	origValue := result
+	logger.Infof("Query asset6 result: %s", string(result.Payload))
+	origValue := result.Payload
 
 	// update
-	result, _, err = fbClient.ExecuteChaincode(ccID, "TransferAsset", [][]byte{[]byte("asset6"), []byte("Jose")}, nil)
+	result, err = fbClient.ExecuteChaincode(ccID, "TransferAsset", [][]byte{[]byte("asset6"), []byte("Jose")}, nil)
 	require.NoError(t, err, "failed to invoke %s", ccID)
-	logger.Infof("Transfer asset6 result: %s", string(result))
+	logger.Infof("Transfer asset6 result: %s", string(result.Payload))
+	assert.Greater(t, result.BlockNumber, uint64(0), "transaction should be committed in a block")
+	assert.Equal(t, 2, len(result.Endorsers()), "transaction should be endorsed by 2 peers")
 
 	// query after update
-	result, _, err = fbClient.QueryChaincode(ccID, "ReadAsset", [][]byte{[]byte("asset6")}, nil)
+	result, err = fbClient.QueryChaincode(ccID, "ReadAsset", [][]byte{[]byte("asset6")}, nil)
 	require.NoError(t, err, "failed to query %s", ccID)
-	logger.Infof("Query asset6 result: %s", string(result))
-	assert.NotEqual(t, origValue, result, "original %s should different from %s", string(origValue), string(result))
+	logger.Infof("Query asset6 result: %s", string(result.Payload))
+	assert.NotEqual(t, origValue, result.Payload, "original %s should different from %s", string(origValue), string(result.Payload))
 }
 
 func TestNetworkConfigYaml(t *testing.T) {
@@ -72,15 +81,91 @@ func TestNetworkConfigYaml(t *testing.T) {
 	require.NoError(t, err, "failed to read config file %s", testConfig)
 	cs := ConnectorSpec{
 		NetworkConfig: networkConfig,
+		UserOrgOnly:   true,
 	}
 	fbc := &FabricClient{}
-	fbc.setOrgFilter(cs)
+	require.NoError(t, fbc.setNetwork(cs), "parse network config should not throw error")
 	assert.Equal(t, "Org1MSP", fbc.filter.(*OrgFilter).MSPID, "default MSPID should be 'Org1MSP'")
 
 	cs = ConnectorSpec{
 		NetworkConfig: networkConfig,
 		OrgName:       "org2",
+		UserOrgOnly:   true,
 	}
-	fbc.setOrgFilter(cs)
+	require.NoError(t, fbc.setNetwork(cs), "parse network config should not throw error")
 	assert.Equal(t, "Org2MSP", fbc.filter.(*OrgFilter).MSPID, "org2's MSPID should be 'Org2MSP'")
 }
+
+func TestResponseEndorsers(t *testing.T) {
+	id, err := proto.Marshal(&mspproto.SerializedIdentity{Mspid: "Org2MSP", IdBytes: []byte("cert")})
+	require.NoError(t, err, "failed to marshal serialized identity")
+	response := &Response{}
+	response.Responses = []*fab.TransactionProposalResponse{
+		{
+			Endorser: "peer0.org2.example.com:9051",
+			ProposalResponse: &pb.ProposalResponse{
+				Endorsement: &pb.Endorsement{Endorser: id},
+			},
+		},
+		{
+			Endorser: "peer0.org1.example.com:7051",
+		},
+	}
+	endorsers := response.Endorsers()
+	require.Equal(t, 2, len(endorsers), "response should have 2 endorsers")
+	assert.Equal(t, "peer0.org2.example.com:9051", endorsers[0].URL, "endorser URL should be peer0.org2")
+	assert.Equal(t, "Org2MSP", endorsers[0].MSPID, "endorser MSP ID should be 'Org2MSP'")
+	assert.Equal(t, "", endorsers[1].MSPID, "endorser without endorsement should not have MSP ID")
+}
+
+// returns serialized proposal response payload that writes a value of a key
+func testProposalResponsePayload(t *testing.T, key, value string) []byte {
+	kvRWSet, err := proto.Marshal(&kvrwset.KVRWSet{Writes: []*kvrwset.KVWrite{{Key: key, Value: []byte(value)}}})
+	require.NoError(t, err, "failed to marshal kv read/write set")
+	txRWSet, err := proto.Marshal(&rwset.TxReadWriteSet{NsRwset: []*rwset.NsReadWriteSet{{Namespace: ccID, Rwset: kvRWSet}}})
+	require.NoError(t, err, "failed to marshal tx read/write set")
+	ccAction, err := proto.Marshal(&pb.ChaincodeAction{Results: txRWSet})
+	require.NoError(t, err, "failed to marshal chaincode action")
+	payload, err := proto.Marshal(&pb.ProposalResponsePayload{Extension: ccAction})
+	require.NoError(t, err, "failed to marshal proposal response payload")
+	return payload
+}
+
+func TestResponseSimulation(t *testing.T) {
+	response := &Response{}
+	response.Payload = []byte("Tomoko")
+	response.Responses = []*fab.TransactionProposalResponse{
+		{
+			Endorser: "peer0.org1.example.com:7051",
+			ProposalResponse: &pb.ProposalResponse{
+				Response: &pb.Response{Status: 200, Payload: []byte("Tomoko")},
+				Payload:  testProposalResponsePayload(t, "asset1", `{"owner":"Jose"}`),
+			},
+		},
+		{
+			Endorser: "peer0.org2.example.com:9051",
+			ProposalResponse: &pb.ProposalResponse{
+				Response: &pb.Response{Status: 200, Payload: []byte("Tomoko")},
+				Payload:  testProposalResponsePayload(t, "asset1", `{"owner":"Jose"}`),
+			},
+		},
+	}
+	simulation, err := response.Simulation()
+	require.NoError(t, err, "decode simulation should not throw error")
+	assert.True(t, simulation.Consistent, "same endorsements should be consistent")
+	assert.Equal(t, "Tomoko", simulation.Payload, "simulation should return chaincode payload")
+	require.Equal(t, 1, len(simulation.ReadWriteSets), "simulation should write 1 namespace")
+	assert.Equal(t, "asset1", simulation.ReadWriteSets[0].Writes[0].Key, "simulation should write asset1")
+	require.Equal(t, 2, len(simulation.Endorsements), "simulation should have 2 endorsements")
+	assert.Equal(t, "peer0.org2.example.com:9051", simulation.Endorsements[1].URL, "second endorser should be peer0.org2")
+
+	response.Responses[1].ProposalResponse.Payload = testProposalResponsePayload(t, "asset1", `{"owner":"Max"}`)
+	simulation, err = response.Simulation()
+	require.NoError(t, err, "decode simulation should not throw error")
+	assert.False(t, simulation.Consistent, "different read/write sets should not be consistent")
+	assert.Equal(t, `{"owner":"Max"}`, simulation.Endorsements[1].ReadWriteSets[0].Writes[0].Value, "second endorsement should write a different value")
+
+	result, err := simulation.ToMap()
+	require.NoError(t, err, "convert simulation to map should not throw error")
+	assert.Equal(t, false, result["consistent"], "simulation map should not be consistent")
+}
@@ -0,0 +1,322 @@
+/*
+SPDX-License-Identifier: BSD-3-Clause-Open-MPI
+*/
+
+package request
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// prefixes of encrypted field values, so encrypted values can be recognized in query results
+const (
+	aesPrefix   = "enc:v1:aes:"
+	eciesPrefix = "enc:v1:ecies:"
+)
+
+// FieldCipher encrypts and decrypts JSON field values by a shared AES-256 key,
+// or by ECIES with the public key of a recipient certificate and the recipient's private key.
+// Encrypted values are strings of format enc:v1:aes:<base64> or enc:v1:ecies:<base64>.
+type FieldCipher struct {
+	sharedKey  []byte
+	recipient  *ecdsa.PublicKey
+	privateKey *ecdsa.PrivateKey
+}
+
+// NewFieldCipher returns a cipher of a base64 encoded 256-bit shared key, or a recipient certificate for ECIES encryption
+// and a private key for ECIES decryption. The recipient certificate and private key can be PEM, base64 encoded PEM,
+// or the path of a PEM file or a folder in an MSP, e.g., signcerts or keystore. It returns nil if no key is specified.
+func NewFieldCipher(sharedKey, recipient, privateKey string) (*FieldCipher, error) {
+	if len(sharedKey) == 0 && len(recipient) == 0 && len(privateKey) == 0 {
+		return nil, nil
+	}
+	c := &FieldCipher{}
+	if len(sharedKey) > 0 {
+		key, err := base64.StdEncoding.DecodeString(sharedKey)
+		if err != nil || len(key) != 32 {
+			return nil, errors.New("Encryption key must be a base64 encoded 256-bit key")
+		}
+		c.sharedKey = key
+	}
+	if len(recipient) > 0 {
+		data, err := readPEM(recipient)
+		if err != nil {
+			return nil, err
+		}
+		cert, err := parseCertificate(data)
+		if err != nil {
+			return nil, err
+		}
+		pubKey, ok := cert.PublicKey.(*ecdsa.PublicKey)
+		if !ok {
+			return nil, errors.New("Recipient certificate does not contain an ECDSA public key")
+		}
+		c.recipient = pubKey
+	}
+	if len(privateKey) > 0 {
+		data, err := readPEM(privateKey)
+		if err != nil {
+			return nil, err
+		}
+		if c.privateKey, err = ParseECPrivateKey(data); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// Encrypt encrypts a value by the shared key, or by ECIES if no shared key is specified
+func (c *FieldCipher) Encrypt(plaintext []byte) (string, error) {
+	if c.sharedKey != nil {
+		sealed, err := sealAESGCM(c.sharedKey, plaintext)
+		if err != nil {
+			return "", err
+		}
+		return aesPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+	}
+	if c.recipient == nil {
+		return "", errors.New("No encryption key or recipient is specified")
+	}
+
+	// generate ephemeral key, and derive AES key from the ECDH shared secret
+	ephemeral, err := ecdsa.GenerateKey(c.recipient.Curve, rand.Reader)
+	if err != nil {
+		return "", errors.Wrapf(err, "Failed to generate ephemeral key")
+	}
+	ephemeralPub := elliptic.Marshal(c.recipient.Curve, ephemeral.X, ephemeral.Y)
+	x, _ := c.recipient.Curve.ScalarMult(c.recipient.X, c.recipient.Y, ephemeral.D.Bytes())
+	sealed, err := sealAESGCM(eciesKey(c.recipient.Curve, x, ephemeralPub), plaintext)
+	if err != nil {
+		return "", err
+	}
+	return eciesPrefix + base64.StdEncoding.EncodeToString(append(ephemeralPub, sealed...)), nil
+}
+
+// Decrypt decrypts a value returned by Encrypt
+func (c *FieldCipher) Decrypt(ciphertext string) ([]byte, error) {
+	switch {
+	case strings.HasPrefix(ciphertext, aesPrefix):
+		if c.sharedKey == nil {
+			return nil, errors.New("No encryption key is specified to decrypt value")
+		}
+		data, err := base64.StdEncoding.DecodeString(ciphertext[len(aesPrefix):])
+		if err != nil {
+			return nil, errors.Wrapf(err, "Encrypted value is not base64 encoded")
+		}
+		return openAESGCM(c.sharedKey, data)
+	case strings.HasPrefix(ciphertext, eciesPrefix):
+		if c.privateKey == nil {
+			return nil, errors.New("No private key is specified to decrypt value")
+		}
+		data, err := base64.StdEncoding.DecodeString(ciphertext[len(eciesPrefix):])
+		if err != nil {
+			return nil, errors.Wrapf(err, "Encrypted value is not base64 encoded")
+		}
+		curve := c.privateKey.Curve
+		keyLen := 1 + 2*((curve.Params().BitSize+7)/8)
+		if len(data) <= keyLen {
+			return nil, errors.New("Encrypted value is too short")
+		}
+		ex, ey := elliptic.Unmarshal(curve, data[:keyLen])
+		if ex == nil {
+			return nil, errors.New("Encrypted value contains invalid ephemeral key")
+		}
+		x, _ := curve.ScalarMult(ex, ey, c.privateKey.D.Bytes())
+		return openAESGCM(eciesKey(curve, x, data[:keyLen]), data[keyLen:])
+	}
+	return nil, errors.New("Value is not encrypted")
+}
+
+// EncryptFields returns a copy of transient data with values of specified fields encrypted.
+// A field is a top-level transient key, e.g., marble, or a dot-separated path in its JSON value, e.g., marble.price.
+func (c *FieldCipher) EncryptFields(data map[string]interface{}, fields []string) (map[string]interface{}, error) {
+	if len(data) == 0 || len(fields) == 0 {
+		return data, nil
+	}
+	result := copyValue(data).(map[string]interface{})
+	for _, f := range fields {
+		tokens := strings.Split(f, ".")
+		parent := result
+		for i, name := range tokens {
+			v, ok := parent[name]
+			if !ok || v == nil {
+				break
+			}
+			if i < len(tokens)-1 {
+				if parent, ok = v.(map[string]interface{}); !ok {
+					return nil, errors.Errorf("Transient field %s is not a JSON object", strings.Join(tokens[:i+1], "."))
+				}
+				continue
+			}
+			plaintext, err := json.Marshal(v)
+			if err != nil {
+				return nil, errors.Wrapf(err, "Failed to serialize transient field %s", f)
+			}
+			if parent[name], err = c.Encrypt(plaintext); err != nil {
+				return nil, errors.Wrapf(err, "Failed to encrypt transient field %s", f)
+			}
+		}
+	}
+	return result, nil
+}
+
+// DecryptValues returns a copy of a JSON value with all encrypted strings decrypted.
+// Values that cannot be decrypted by this cipher, e.g., encrypted for another recipient, are returned as is.
+func (c *FieldCipher) DecryptValues(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		if !strings.HasPrefix(v, aesPrefix) && !strings.HasPrefix(v, eciesPrefix) {
+			return v
+		}
+		plaintext, err := c.Decrypt(v)
+		if err != nil {
+			logger.Debugf("cannot decrypt value: %v", err)
+			return v
+		}
+		var result interface{}
+		if err := json.Unmarshal(plaintext, &result); err != nil {
+			return string(plaintext)
+		}
+		return result
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			result[k] = c.DecryptValues(e)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, e := range v {
+			result[i] = c.DecryptValues(e)
+		}
+		return result
+	}
+	return value
+}
+
+// copyValue returns a deep copy of JSON objects and arrays, so input data of a flow is not modified
+func copyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			result[k] = copyValue(e)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, e := range v {
+			result[i] = copyValue(e)
+		}
+		return result
+	}
+	return value
+}
+
+// eciesKey derives a 256-bit AES key from an ECDH shared secret by the ANSI X9.63 KDF with SHA-256,
+// using the ephemeral public key as shared info
+func eciesKey(curve elliptic.Curve, x *big.Int, ephemeralPub []byte) []byte {
+	secret := make([]byte, (curve.Params().BitSize+7)/8)
+	xb := x.Bytes()
+	copy(secret[len(secret)-len(xb):], xb)
+	counter := make([]byte, 4)
+	binary.BigEndian.PutUint32(counter, 1)
+	h := sha256.New()
+	h.Write(secret)
+	h.Write(counter)
+	h.Write(ephemeralPub)
+	return h.Sum(nil)
+}
+
+// sealAESGCM returns nonce and ciphertext of AES-GCM encryption
+func sealAESGCM(key, plaintext []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, errors.Wrapf(err, "Failed to generate nonce")
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// openAESGCM decrypts nonce and ciphertext of AES-GCM encryption
+func openAESGCM(key, data []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("Encrypted value is too short")
+	}
+	plaintext, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to decrypt value")
+	}
+	return plaintext, nil
+}
+
+// readPEM returns PEM data of PEM text, base64 encoded PEM, a PEM file, or the first file in a folder, e.g., signcerts of an MSP
+func readPEM(value string) ([]byte, error) {
+	value = strings.TrimSpace(value)
+	if data := []byte(DecodePEM(value)); len(data) > 0 {
+		if block, _ := pem.Decode(data); block != nil {
+			return data, nil
+		}
+	}
+	path := Subst(value)
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Key is not PEM or a PEM file: %s", value)
+	}
+	if fi.IsDir() {
+		files, err := ioutil.ReadDir(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to read folder %s", value)
+		}
+		for _, f := range files {
+			if !f.IsDir() {
+				return ioutil.ReadFile(filepath.Join(path, f.Name()))
+			}
+		}
+		return nil, errors.Errorf("No file found in folder %s", value)
+	}
+	return ioutil.ReadFile(path)
+}
+
+// ParseECPrivateKey returns the ECDSA private key of a PEM encoded PKCS8 or SEC1 private key
+func ParseECPrivateKey(keyPEM []byte) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, errors.New("Private key is not PEM encoded")
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to parse private key")
+	}
+	ecKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("Private key is not an ECDSA key")
+	}
+	return ecKey, nil
+}
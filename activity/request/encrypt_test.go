@@ -0,0 +1,114 @@
+/*
+SPDX-License-Identifier: BSD-3-Clause-Open-MPI
+*/
+
+package request
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/project-flogo/core/data/mapper"
+	"github.com/project-flogo/core/data/resolve"
+	"github.com/project-flogo/core/support/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptFields(t *testing.T) {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	require.NoError(t, err, "generate key should not throw error")
+	shared, err := NewFieldCipher(base64.StdEncoding.EncodeToString(key), "", "")
+	require.NoError(t, err, "create shared key cipher should not throw error")
+
+	data := map[string]interface{}{
+		"marble": map[string]interface{}{"name": "marble1", "price": 99.5},
+		"owner":  "tom",
+	}
+	encrypted, err := shared.EncryptFields(data, []string{"marble.price", "owner", "unknown.field"})
+	require.NoError(t, err, "encrypt fields should not throw error")
+	marble := encrypted["marble"].(map[string]interface{})
+	assert.Equal(t, "marble1", marble["name"], "unselected field should not be encrypted")
+	assert.True(t, strings.HasPrefix(marble["price"].(string), aesPrefix), "price should be encrypted by shared key")
+	assert.True(t, strings.HasPrefix(encrypted["owner"].(string), aesPrefix), "owner should be encrypted by shared key")
+	assert.Equal(t, 99.5, data["marble"].(map[string]interface{})["price"], "input data should not be modified")
+	assert.Equal(t, data, shared.DecryptValues(encrypted), "decrypted values should match input data")
+
+	_, err = NewFieldCipher("c2hvcnQ=", "", "")
+	assert.Error(t, err, "short shared key should throw error")
+
+	// ECIES by recipient certificate folder and private key file
+	dir, err := ioutil.TempDir("", "msp")
+	require.NoError(t, err, "create temp folder should not throw error")
+	defer os.RemoveAll(dir)
+	cert, privKey := newTestKeyPair(t, "org2-admin")
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "signcerts"), 0755), "create signcerts folder should not throw error")
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "signcerts", "cert.pem"), []byte(cert), 0644), "write cert should not throw error")
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "priv_sk"), []byte(privKey), 0600), "write key should not throw error")
+
+	sender, err := NewFieldCipher("", filepath.Join(dir, "signcerts"), "")
+	require.NoError(t, err, "create ECIES cipher should not throw error")
+	encrypted, err = sender.EncryptFields(data, []string{"marble"})
+	require.NoError(t, err, "ECIES encrypt should not throw error")
+	assert.True(t, strings.HasPrefix(encrypted["marble"].(string), eciesPrefix), "marble should be encrypted by ECIES")
+	assert.Equal(t, encrypted, sender.DecryptValues(encrypted), "sender without private key should not decrypt values")
+
+	recipient, err := NewFieldCipher("", "", filepath.Join(dir, "priv_sk"))
+	require.NoError(t, err, "create ECIES decryption cipher should not throw error")
+	assert.Equal(t, data, recipient.DecryptValues(encrypted), "recipient should decrypt values")
+
+	_, otherKey := newTestKeyPair(t, "org3-admin")
+	other, err := NewFieldCipher("", "", otherKey)
+	require.NoError(t, err, "create ECIES decryption cipher should not throw error")
+	_, err = other.Decrypt(encrypted["marble"].(string))
+	assert.Error(t, err, "another private key should not decrypt value")
+}
+
+func TestEncryptTransient(t *testing.T) {
+	mock := NewMockBackend()
+	invoke := mock.On(opInvoke, "marbles", "initMarble")
+	RegisterBackend("mock-private", mock)
+	defer UnregisterBackend("mock-private")
+
+	key := base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+	settings := map[string]interface{}{
+		"connectionName":     "mock-private",
+		"channelID":          "mychannel",
+		"chaincodeID":        "marbles",
+		"transactionName":    "initMarble",
+		"requestType":        "invoke",
+		"encryptedTransient": "marble.price",
+		"encryptionKey":      key,
+	}
+	_, done, err := evalActivity(t, settings, `{"userName": "Admin", "transient": {"marble": {"name": "marble1", "price": 99}}}`)
+	require.True(t, done, "invoke should be successful")
+	require.NoError(t, err, "invoke should not throw error")
+	var marble map[string]interface{}
+	require.NoError(t, json.Unmarshal(invoke.Transient()["marble"], &marble), "transient marble should be JSON")
+	assert.Equal(t, "marble1", marble["name"], "marble name should not be encrypted")
+	assert.True(t, strings.HasPrefix(marble["price"].(string), aesPrefix), "marble price should be encrypted")
+
+	// query result containing the encrypted price is decrypted
+	stored, _ := json.Marshal(map[string]interface{}{"docType": "marblePrivate", "name": "marble1", "price": marble["price"]})
+	mock.On(opQuery, "marbles", "readMarblePrivateDetails").Return(stored, 200)
+	settings["transactionName"] = "readMarblePrivateDetails"
+	settings["requestType"] = "query"
+	delete(settings, "encryptedTransient")
+	output, done, err := evalActivity(t, settings, `{"userName": "Admin"}`)
+	require.True(t, done, "query should be successful")
+	require.NoError(t, err, "query should not throw error")
+	assert.Equal(t, 99.0, output.Result.(map[string]interface{})["price"], "query result should be decrypted")
+
+	settings["encryptionKey"] = ""
+	settings["encryptedTransient"] = "marble"
+	mf := mapper.NewFactory(resolve.GetBasicResolver())
+	_, err = New(test.NewActivityInitContext(settings, mf))
+	assert.Error(t, err, "encrypted transient without key should throw error")
+}
@@ -0,0 +1,203 @@
+/*
+SPDX-License-Identifier: BSD-3-Clause-Open-MPI
+*/
+
+package request
+
+import (
+	"regexp"
+	"strings"
+
+	cb "github.com/hyperledger/fabric-protos-go/common"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/status"
+	"google.golang.org/grpc/codes"
+)
+
+// error types of failed Fabric requests
+const (
+	ErrTimeout             = "TIMEOUT"
+	ErrEndorsementFailure  = "ENDORSEMENT_FAILURE"
+	ErrEndorsementMismatch = "ENDORSEMENT_MISMATCH"
+	ErrMVCCReadConflict    = "MVCC_READ_CONFLICT"
+	ErrPhantomReadConflict = "PHANTOM_READ_CONFLICT"
+	ErrPolicyFailure       = "POLICY_FAILURE"
+	ErrChaincodeNotFound   = "CHAINCODE_NOT_FOUND"
+	ErrAccessDenied        = "ACCESS_DENIED"
+	ErrConnectionFailure   = "CONNECTION_FAILURE"
+	ErrUnknown             = "UNKNOWN"
+)
+
+// errorCodes maps error types to HTTP status codes
+var errorCodes = map[string]int{
+	ErrTimeout:             504,
+	ErrEndorsementFailure:  422,
+	ErrEndorsementMismatch: 502,
+	ErrMVCCReadConflict:    409,
+	ErrPhantomReadConflict: 409,
+	ErrPolicyFailure:       412,
+	ErrChaincodeNotFound:   404,
+	ErrAccessDenied:        403,
+	ErrConnectionFailure:   503,
+	ErrUnknown:             500,
+}
+
+// RequestError is a classified error of a Fabric request
+type RequestError struct {
+	// Type is one of the error types, e.g., MVCC_READ_CONFLICT
+	Type string
+	// Code is the HTTP status code of the error type, or the status returned by the chaincode if it is in the range of 400-499
+	Code int
+	// Message is the error message returned by the chaincode, or the message of the cause if chaincode is not invoked
+	Message string
+	cause   error
+}
+
+// Error implements error interface
+func (e *RequestError) Error() string {
+	return e.Type + ": " + e.Message
+}
+
+// Cause returns the original error returned by Fabric SDK
+func (e *RequestError) Cause() error {
+	return e.cause
+}
+
+// prefix of chaincode error messages returned by endorsing peers
+const chaincodeFailurePrefix = "transaction returned with failure: "
+
+// peerMessagePattern matches error messages generated by the endorsing peer, e.g., proposal validation or chaincode lookup failures.
+// fabric-sdk-go returns other chaincode errors verbatim, so a message that does not match it may be defined by the chaincode.
+var peerMessagePattern = regexp.MustCompile(`^(access denied: channel \[|error validating proposal: |failed evaluating policy |make sure the chaincode |could not find chaincode |cannot get package for chaincode |chaincode definition for |failed to execute transaction [0-9a-f]*: (timeout expired|error sending|could not launch))`)
+
+var chaincodeNotFoundPattern = regexp.MustCompile(`(?i)(could not find chaincode|cannot get package for chaincode|chaincode definition for .* not found|chaincode .* not found|make sure the chaincode .* has been successfully defined)`)
+
+// ClassifyError returns the error type, status code, and chaincode message of an error returned by Fabric SDK
+func ClassifyError(err error) *RequestError {
+	if err == nil {
+		return nil
+	}
+	if e, ok := err.(*RequestError); ok {
+		return e
+	}
+	result := &RequestError{Type: ErrUnknown, Message: err.Error(), cause: err}
+	if s, ok := status.FromError(err); ok {
+		classifyStatus(s, result)
+	}
+	if result.Type == ErrUnknown {
+		result.Type = classifyMessage(result.Message)
+	}
+	if result.Code == 0 {
+		result.Code = errorCodes[result.Type]
+	}
+	return result
+}
+
+// classifyStatus sets error type and message of a status error returned by Fabric SDK
+func classifyStatus(s *status.Status, result *RequestError) {
+	result.Message = s.Message
+	switch s.Group {
+	case status.EventServerStatus:
+		// transaction is committed, but invalidated
+		switch pb.TxValidationCode(s.Code) {
+		case pb.TxValidationCode_MVCC_READ_CONFLICT:
+			result.Type = ErrMVCCReadConflict
+		case pb.TxValidationCode_PHANTOM_READ_CONFLICT:
+			result.Type = ErrPhantomReadConflict
+		case pb.TxValidationCode_ENDORSEMENT_POLICY_FAILURE:
+			result.Type = ErrPolicyFailure
+		case pb.TxValidationCode_BAD_CREATOR_SIGNATURE, pb.TxValidationCode_INVALID_ENDORSER_TRANSACTION:
+			result.Type = ErrAccessDenied
+		}
+		result.Message = s.Message + " with validation code " + pb.TxValidationCode(s.Code).String()
+	case status.GRPCTransportStatus:
+		switch codes.Code(s.Code) {
+		case codes.DeadlineExceeded:
+			result.Type = ErrTimeout
+		case codes.Unavailable:
+			result.Type = ErrConnectionFailure
+		case codes.PermissionDenied, codes.Unauthenticated:
+			result.Type = ErrAccessDenied
+		}
+	case status.EndorserClientStatus, status.OrdererClientStatus, status.ClientStatus:
+		switch status.Code(s.Code) {
+		case status.Timeout:
+			result.Type = ErrTimeout
+		case status.ConnectionFailed, status.NoPeersFound:
+			result.Type = ErrConnectionFailure
+		case status.EndorsementMismatch:
+			result.Type = ErrEndorsementMismatch
+		case status.ChaincodeNameNotFound:
+			result.Type = ErrChaincodeNotFound
+		case status.SignatureVerificationFailed:
+			result.Type = ErrAccessDenied
+		case status.MultipleErrors:
+			classifyDetails(s.Details, result)
+		}
+	case status.OrdererServerStatus:
+		switch cb.Status(s.Code) {
+		case cb.Status_FORBIDDEN:
+			result.Type = ErrAccessDenied
+		case cb.Status_SERVICE_UNAVAILABLE:
+			result.Type = ErrConnectionFailure
+		}
+	case status.EndorserServerStatus, status.ChaincodeStatus:
+		// endorser or chaincode returned an error response
+		if i := strings.Index(s.Message, chaincodeFailurePrefix); i >= 0 {
+			result.Message = s.Message[i+len(chaincodeFailurePrefix):]
+		} else if s.Group == status.EndorserServerStatus && peerMessagePattern.MatchString(s.Message) {
+			// error of the endorsing peer, e.g., access denied, does not contain a message of the chaincode
+			if t := classifyMessage(s.Message); t != ErrUnknown {
+				result.Type = t
+				return
+			}
+		}
+		// chaincode errors are not classified by message, because the message is defined by the chaincode
+		result.Type = ErrEndorsementFailure
+		if s.Code >= 400 && s.Code < 500 {
+			// use client error status returned by chaincode
+			result.Code = int(s.Code)
+		}
+	}
+}
+
+// classifyDetails uses the first classified error in the details of a status of multiple errors
+func classifyDetails(details []interface{}, result *RequestError) {
+	for _, d := range details {
+		if err, ok := d.(error); ok {
+			if e := ClassifyError(err); e.Type != ErrUnknown {
+				result.Type = e.Type
+				result.Code = e.Code
+				result.Message = e.Message
+				return
+			}
+		}
+	}
+}
+
+// classifyMessage returns error type by matching well-known error messages of Fabric.
+// It must not be used for messages returned by chaincode.
+func classifyMessage(msg string) string {
+	lower := strings.ToLower(msg)
+	switch {
+	case strings.Contains(msg, pb.TxValidationCode_MVCC_READ_CONFLICT.String()):
+		return ErrMVCCReadConflict
+	case strings.Contains(msg, pb.TxValidationCode_PHANTOM_READ_CONFLICT.String()):
+		return ErrPhantomReadConflict
+	case strings.Contains(msg, pb.TxValidationCode_ENDORSEMENT_POLICY_FAILURE.String()),
+		strings.Contains(lower, "signature set did not satisfy policy"):
+		return ErrPolicyFailure
+	case chaincodeNotFoundPattern.MatchString(msg):
+		return ErrChaincodeNotFound
+	case strings.Contains(lower, "access denied"), strings.Contains(lower, "permission denied"):
+		return ErrAccessDenied
+	case strings.Contains(lower, "timeout"), strings.Contains(lower, "timed out"), strings.Contains(lower, "deadline exceeded"):
+		return ErrTimeout
+	case strings.Contains(lower, "do not match"), strings.Contains(lower, "endorsement mismatch"):
+		return ErrEndorsementMismatch
+	case strings.Contains(lower, "connection failed"), strings.Contains(lower, "connection refused"),
+		strings.Contains(lower, "transport is closing"):
+		return ErrConnectionFailure
+	}
+	return ErrUnknown
+}
@@ -0,0 +1,60 @@
+/*
+SPDX-License-Identifier: BSD-3-Clause-Open-MPI
+*/
+
+package request
+
+import (
+	"testing"
+
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/multi"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/status"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		err       error
+		errorType string
+		code      int
+		message   string
+	}{
+		{status.New(status.ClientStatus, status.Timeout.ToInt32(), "request timed out or been cancelled", nil), ErrTimeout, 504, ""},
+		{status.New(status.GRPCTransportStatus, int32(codes.DeadlineExceeded), "context deadline exceeded", nil), ErrTimeout, 504, ""},
+		{status.New(status.EndorserClientStatus, status.ConnectionFailed.ToInt32(), "connection refused", nil), ErrConnectionFailure, 503, ""},
+		{status.New(status.EndorserClientStatus, status.EndorsementMismatch.ToInt32(), "ProposalResponsePayloads do not match", nil), ErrEndorsementMismatch, 502, ""},
+		{status.New(status.EventServerStatus, int32(pb.TxValidationCode_MVCC_READ_CONFLICT), "received invalid transaction", nil), ErrMVCCReadConflict, 409, ""},
+		{status.New(status.EventServerStatus, int32(pb.TxValidationCode_PHANTOM_READ_CONFLICT), "received invalid transaction", nil), ErrPhantomReadConflict, 409, ""},
+		{status.New(status.EventServerStatus, int32(pb.TxValidationCode_ENDORSEMENT_POLICY_FAILURE), "received invalid transaction", nil), ErrPolicyFailure, 412, ""},
+		{status.New(status.EndorserClientStatus, int32(status.ChaincodeNameNotFound), "make sure the chaincode basic has been successfully defined on channel mychannel", nil), ErrChaincodeNotFound, 404, ""},
+		{status.New(status.EndorserServerStatus, 500, "access denied: channel [mychannel] creator org [Org3MSP]", nil), ErrAccessDenied, 403, ""},
+		{status.New(status.EndorserServerStatus, 500, "error in simulation: transaction returned with failure: the asset asset9 does not exist", nil), ErrEndorsementFailure, 422, "the asset asset9 does not exist"},
+		{status.New(status.ChaincodeStatus, 404, "asset not found", nil), ErrEndorsementFailure, 404, "asset not found"},
+		{errors.Wrapf(status.New(status.ChaincodeStatus, 500, "invalid owner", nil), "Query failed"), ErrEndorsementFailure, 422, "invalid owner"},
+		{status.New(status.EndorserServerStatus, 500, "error in simulation: transaction returned with failure: access denied for owner after timeout", nil), ErrEndorsementFailure, 422, "access denied for owner after timeout"},
+		{status.New(status.ChaincodeStatus, 500, "MVCC_READ_CONFLICT is not expected", nil), ErrEndorsementFailure, 422, "MVCC_READ_CONFLICT is not expected"},
+		{status.New(status.EndorserServerStatus, 500, "access denied for asset1", nil), ErrEndorsementFailure, 422, "access denied for asset1"},
+		{status.New(status.EndorserServerStatus, 500, "update of asset1 failed after timeout", nil), ErrEndorsementFailure, 422, "update of asset1 failed after timeout"},
+		{status.New(status.EndorserServerStatus, 500, "failed to execute transaction 6a3f: timeout expired while executing transaction", nil), ErrTimeout, 504, ""},
+		{status.New(status.EndorserServerStatus, 500, "make sure the chaincode marbles has been successfully defined on channel mychannel and try again: chaincode marbles not found", nil), ErrChaincodeNotFound, 404, ""},
+		{errors.New("unexpected failure"), ErrUnknown, 500, "unexpected failure"},
+	}
+	for _, tc := range tests {
+		e := ClassifyError(tc.err)
+		assert.Equal(t, tc.errorType, e.Type, "error type of %s", tc.err.Error())
+		assert.Equal(t, tc.code, e.Code, "status code of %s", tc.err.Error())
+		if len(tc.message) > 0 {
+			assert.Equal(t, tc.message, e.Message, "message of %s", tc.err.Error())
+		}
+		assert.Equal(t, tc.err, e.Cause(), "cause should be the original error")
+	}
+
+	e := ClassifyError(multi.New(errors.New("unexpected failure"), status.New(status.ChaincodeStatus, 500, "invalid owner", nil)))
+	assert.Equal(t, ErrEndorsementFailure, e.Type, "multiple errors should be classified by the first known error")
+	assert.Equal(t, "invalid owner", e.Message, "multiple errors should return chaincode message")
+
+	assert.Nil(t, ClassifyError(nil), "nil error should not be classified")
+}
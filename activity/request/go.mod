@@ -9,11 +9,16 @@ replace github.com/project-flogo/core => github.com/yxuco/core v1.2.2
 replace go.uber.org/multierr => go.uber.org/multierr v1.6.0
 
 require (
+	github.com/fsnotify/fsnotify v1.4.7
+	github.com/golang/protobuf v1.3.3
+	github.com/hyperledger/fabric-protos-go v0.0.0-20200707132912-fee30f3ccd23
 	github.com/hyperledger/fabric-sdk-go v1.0.0-rc1
 	github.com/pkg/errors v0.9.1
 	github.com/project-flogo/core v1.2.0
 	github.com/stretchr/testify v1.6.1
 	github.com/xeipuuv/gojsonschema v1.1.0
 	go.uber.org/multierr v1.6.0 // indirect
+	golang.org/x/crypto v0.0.0-20200221231518-2aa609cf4a9d
+	google.golang.org/grpc v1.29.1
 	gopkg.in/yaml.v2 v2.3.0
 )
@@ -0,0 +1,85 @@
+/*
+SPDX-License-Identifier: BSD-3-Clause-Open-MPI
+*/
+
+package request
+
+import (
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel/invoke"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/status"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	"github.com/pkg/errors"
+)
+
+// commitHandler sends endorsed transaction to orderer and waits for the commit event.
+// It works the same as invoke.CommitTxHandler, but it also records the number of the block that commits the transaction.
+type commitHandler struct {
+	blockNumber uint64
+}
+
+// Handle implements invoke.Handler.Handle
+func (h *commitHandler) Handle(requestContext *invoke.RequestContext, clientContext *invoke.ClientContext) {
+	txnID := requestContext.Response.TransactionID
+
+	// register tx event before sending the transaction, so the event will not be missed
+	reg, statusNotifier, err := clientContext.EventService.RegisterTxStatusEvent(string(txnID))
+	if err != nil {
+		requestContext.Error = errors.Wrap(err, "error registering for TxStatus event")
+		return
+	}
+	defer clientContext.EventService.Unregister(reg)
+
+	if err := sendTransaction(requestContext, clientContext); err != nil {
+		requestContext.Error = errors.Wrap(err, "CreateAndSendTransaction failed")
+		return
+	}
+
+	select {
+	case txStatus := <-statusNotifier:
+		requestContext.Response.TxValidationCode = txStatus.TxValidationCode
+		h.blockNumber = txStatus.BlockNumber
+		if txStatus.TxValidationCode != pb.TxValidationCode_VALID {
+			requestContext.Error = status.New(status.EventServerStatus, int32(txStatus.TxValidationCode),
+				"received invalid transaction", nil)
+		}
+	case <-requestContext.Ctx.Done():
+		requestContext.Error = status.New(status.ClientStatus, status.Timeout.ToInt32(),
+			"Execute didn't receive block event", nil)
+	}
+}
+
+// submitHandler sends endorsed transaction to orderer without waiting for the commit event
+type submitHandler struct {
+}
+
+// Handle implements invoke.Handler.Handle
+func (h *submitHandler) Handle(requestContext *invoke.RequestContext, clientContext *invoke.ClientContext) {
+	if err := sendTransaction(requestContext, clientContext); err != nil {
+		requestContext.Error = errors.Wrap(err, "CreateAndSendTransaction failed")
+	}
+}
+
+// simulateHandler completes the invocation chain without sending the endorsed transaction to orderer
+type simulateHandler struct {
+}
+
+// Handle implements invoke.Handler.Handle
+func (h *simulateHandler) Handle(requestContext *invoke.RequestContext, clientContext *invoke.ClientContext) {
+	logger.Debugf("simulated transaction %s is not sent to orderer", requestContext.Response.TransactionID)
+}
+
+// sendTransaction creates transaction from endorsed proposal responses, and sends it to orderer
+func sendTransaction(requestContext *invoke.RequestContext, clientContext *invoke.ClientContext) error {
+	tx, err := clientContext.Transactor.CreateTransaction(fab.TransactionRequest{
+		Proposal:          requestContext.Response.Proposal,
+		ProposalResponses: requestContext.Response.Responses,
+	})
+	if err != nil {
+		return errors.WithMessage(err, "CreateTransaction failed")
+	}
+	if _, err = clientContext.Transactor.SendTransaction(tx); err != nil {
+		return errors.WithMessage(err, "SendTransaction failed")
+	}
+	return nil
+}
@@ -0,0 +1,286 @@
+/*
+SPDX-License-Identifier: BSD-3-Clause-Open-MPI
+*/
+
+package request
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+	"github.com/project-flogo/core/engine"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// EnvNetworkConfig is the environment variable for the path of the network config file of all connections.
+	// The path of a specific connection can be set by the variable with suffix of the connection name, e.g., FABRIC_NETWORK_CONFIG_TEST_NETWORK.
+	EnvNetworkConfig = "FABRIC_NETWORK_CONFIG"
+	// EnvEntityMatchers is the environment variable for the path of the entity matchers file of all connections.
+	// The path of a specific connection can be set by the variable with suffix of the connection name, e.g., FABRIC_ENTITY_MATCHERS_TEST_NETWORK.
+	EnvEntityMatchers = "FABRIC_ENTITY_MATCHERS"
+)
+
+// reloadDelay is the period to wait for more file events before a network is reloaded,
+// so a config and its entity matchers updated together, e.g., by a Kubernetes ConfigMap, are reloaded once.
+var reloadDelay = time.Second
+
+// networkFiles are the files of a network config loaded at runtime
+type networkFiles struct {
+	configPath  string
+	matcherPath string
+}
+
+// networks loaded from the filesystem, and the watcher of their files
+var (
+	loaderLock     sync.Mutex
+	loadedNetworks = map[string]*networkFiles{}
+	reloadHandlers []func(connectionName string)
+	watcher        *fsnotify.Watcher
+	watchedDirs    = map[string]bool{}
+)
+
+func init() {
+	engine.LifeCycle(loaderService{})
+}
+
+// loaderService stops watching network config files when the Flogo engine stops
+type loaderService struct{}
+
+// Start implements managed.Managed.Start
+func (s loaderService) Start() error {
+	return nil
+}
+
+// Stop implements managed.Managed.Stop
+func (s loaderService) Stop() error {
+	loaderLock.Lock()
+	defer loaderLock.Unlock()
+	if watcher == nil {
+		return nil
+	}
+	err := watcher.Close()
+	watcher = nil
+	watchedDirs = make(map[string]bool)
+	return err
+}
+
+// ConfigureNetwork loads the network config of a connection from files, and reloads it when the files change.
+// If configPath is empty, it uses the path in the environment variable of the connection, or FABRIC_NETWORK_CONFIG.
+// If no path is specified, the connection uses the network config embedded by `flogo configfabric`.
+// A connection already loaded from files can be configured again only with the same files, or with no file.
+func ConfigureNetwork(connectionName, configPath, matcherPath string) error {
+	fromEnv := len(configPath) == 0
+	if fromEnv {
+		configPath, matcherPath = envNetworkFiles(connectionName)
+	}
+	if len(configPath) == 0 {
+		return nil
+	}
+	files := &networkFiles{configPath: Subst(configPath)}
+	if len(matcherPath) > 0 {
+		files.matcherPath = Subst(matcherPath)
+	}
+
+	loaderLock.Lock()
+	defer loaderLock.Unlock()
+	if loaded, ok := loadedNetworks[connectionName]; ok {
+		if fromEnv || *loaded == *files {
+			return nil
+		}
+		return errors.Errorf("Network of connection %s is already loaded from %s", connectionName, loaded.configPath)
+	}
+	config, matcher, err := files.read()
+	if err != nil {
+		return err
+	}
+	if err := watchFiles(files); err != nil {
+		return err
+	}
+	logger.Infof("load network config of connection %s from %s", connectionName, files.configPath)
+	RegisterNetwork(connectionName, config, matcher)
+	loadedNetworks[connectionName] = files
+	return nil
+}
+
+// OnNetworkReload registers a function to be called after the network config of a connection is reloaded,
+// so contributions caching SDK clients of the connection can drop the stale clients.
+func OnNetworkReload(handler func(connectionName string)) {
+	loaderLock.Lock()
+	defer loaderLock.Unlock()
+	reloadHandlers = append(reloadHandlers, handler)
+}
+
+// envNetworkFiles returns the paths of network config files in environment variables for a connection
+func envNetworkFiles(connectionName string) (string, string) {
+	suffix := "_" + envName(connectionName)
+	if configPath := os.Getenv(EnvNetworkConfig + suffix); len(configPath) > 0 {
+		return configPath, os.Getenv(EnvEntityMatchers + suffix)
+	}
+	return os.Getenv(EnvNetworkConfig), os.Getenv(EnvEntityMatchers)
+}
+
+// envName converts a connection name to upper case, and replaces characters other than letters and digits with '_'
+func envName(connectionName string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		if r >= 'a' && r <= 'z' {
+			return r - 'a' + 'A'
+		}
+		return '_'
+	}, connectionName)
+}
+
+// read returns the content of network config and entity matchers files, and verifies that they are valid yaml
+func (f *networkFiles) read() ([]byte, []byte, error) {
+	config, err := ReadFile(f.configPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := verifyYaml(config); err != nil {
+		return nil, nil, errors.Wrapf(err, "Invalid network config %s", f.configPath)
+	}
+	if len(f.matcherPath) == 0 {
+		return config, nil, nil
+	}
+	matcher, err := ReadFile(f.matcherPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := verifyYaml(matcher); err != nil {
+		return nil, nil, errors.Wrapf(err, "Invalid entity matchers %s", f.matcherPath)
+	}
+	return config, matcher, nil
+}
+
+func verifyYaml(data []byte) error {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return errors.New("File is empty")
+	}
+	var node interface{}
+	return yaml.Unmarshal(data, &node)
+}
+
+// watchFiles watches the directories of network files; the caller must hold the loaderLock.
+// Directories are watched instead of files, because a Kubernetes ConfigMap is updated by replacing a symlink in its directory.
+func watchFiles(files *networkFiles) error {
+	if watcher == nil {
+		w, err := fsnotify.NewWatcher()
+		if err != nil {
+			return errors.Wrapf(err, "Failed to create file watcher")
+		}
+		watcher = w
+		go watchEvents(w)
+	}
+	for _, p := range []string{files.configPath, files.matcherPath} {
+		if len(p) == 0 {
+			continue
+		}
+		dir := filepath.Dir(p)
+		if watchedDirs[dir] {
+			continue
+		}
+		if err := watcher.Add(dir); err != nil {
+			return errors.Wrapf(err, "Failed to watch directory %s", dir)
+		}
+		logger.Infof("watch network config files in %s", dir)
+		watchedDirs[dir] = true
+	}
+	return nil
+}
+
+// watchEvents collects the connections affected by file events, and reloads them after no more event arrives in reloadDelay
+func watchEvents(w *fsnotify.Watcher) {
+	pending := make(map[string]bool)
+	timer := time.NewTimer(reloadDelay)
+	timer.Stop()
+	defer timer.Stop()
+	for {
+		select {
+		case event, ok := <-w.Events:
+			if !ok {
+				return
+			}
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			dir := filepath.Dir(event.Name)
+			for _, name := range networksInDir(dir) {
+				pending[name] = true
+			}
+			if len(pending) > 0 {
+				timer.Reset(reloadDelay)
+			}
+		case err, ok := <-w.Errors:
+			if !ok {
+				return
+			}
+			logger.Errorf("failed to watch network config files: %+v", err)
+		case <-timer.C:
+			for name := range pending {
+				reloadNetwork(name)
+			}
+			pending = make(map[string]bool)
+		}
+	}
+}
+
+// networksInDir returns names of connections that load network files from a directory
+func networksInDir(dir string) []string {
+	loaderLock.Lock()
+	defer loaderLock.Unlock()
+	var names []string
+	for name, files := range loadedNetworks {
+		if filepath.Dir(files.configPath) == dir || (len(files.matcherPath) > 0 && filepath.Dir(files.matcherPath) == dir) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// reloadNetwork registers the updated network config of a connection, and resets its cached clients.
+// An invalid config is ignored, so the connection keeps using the current config.
+func reloadNetwork(connectionName string) {
+	loaderLock.Lock()
+	files, ok := loadedNetworks[connectionName]
+	handlers := reloadHandlers
+	loaderLock.Unlock()
+	if !ok {
+		return
+	}
+
+	config, matcher, err := files.read()
+	if err != nil {
+		logger.Errorf("ignore invalid network config of connection %s: %+v", connectionName, err)
+		return
+	}
+	current, currentMatcher := LookupNetwork(connectionName)
+	if bytes.Equal(config, current) && bytes.Equal(matcher, currentMatcher) {
+		return
+	}
+	logger.Infof("reload network config of connection %s from %s", connectionName, files.configPath)
+	RegisterNetwork(connectionName, config, matcher)
+	resetConnection(connectionName)
+	for _, h := range handlers {
+		h(connectionName)
+	}
+}
+
+// resetConnection removes cached clients and SDK of a connection, so new requests use clients of the current network config.
+// The old clients are closed after their in-flight requests complete, and the old SDK is closed after all clients holding it are released.
+func resetConnection(connectionName string) {
+	clients := clientRegistry.retireConnection(connectionName)
+	go func() {
+		for _, c := range clients {
+			c.Close()
+		}
+	}()
+}
@@ -0,0 +1,117 @@
+/*
+SPDX-License-Identifier: BSD-3-Clause-Open-MPI
+*/
+
+package request
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// unloadNetwork removes a network loaded by a test
+func unloadNetwork(connectionName string) {
+	loaderLock.Lock()
+	delete(loadedNetworks, connectionName)
+	loaderLock.Unlock()
+	UnregisterNetwork(connectionName)
+}
+
+func TestConfigureNetworkFromEnv(t *testing.T) {
+	assert.Equal(t, "TEST_NETWORK_1", envName("test-network.1"), "env name should be upper case letters and digits")
+
+	os.Setenv(EnvNetworkConfig+"_ENV_NETWORK", "../../test-network/config.yaml")
+	defer os.Unsetenv(EnvNetworkConfig + "_ENV_NETWORK")
+	defer unloadNetwork("env-network")
+
+	err := ConfigureNetwork("env-network", "", "")
+	require.NoError(t, err, "load network config from env should not throw error")
+	expected, err := ReadFile("../../test-network/config.yaml")
+	require.NoError(t, err, "read network config should not throw error")
+	config, matcher := LookupNetwork("env-network")
+	assert.Equal(t, expected, config, "connection should use network config of env")
+	assert.Empty(t, matcher, "connection should not have entity matchers")
+
+	err = ConfigureNetwork("env-network", "", "")
+	assert.NoError(t, err, "configure loaded network again should not throw error")
+	err = ConfigureNetwork("env-network", "../../test-network/local_entity_matchers.yaml", "")
+	assert.Error(t, err, "configure loaded network with different file should throw error")
+
+	err = ConfigureNetwork("no-env-network", "", "")
+	assert.NoError(t, err, "connection without env should use embedded network config")
+	assert.NotContains(t, RegisteredNetworks(), "no-env-network", "connection without env should not be registered")
+}
+
+func TestReloadNetwork(t *testing.T) {
+	dir, err := ioutil.TempDir("", "network")
+	require.NoError(t, err, "create temp dir should not throw error")
+	defer os.RemoveAll(dir)
+	configPath := filepath.Join(dir, "config.yaml")
+	require.NoError(t, ioutil.WriteFile(configPath, []byte("name: v1\n"), 0644), "write config should not throw error")
+
+	err = ConfigureNetwork("reload-network", configPath, "")
+	require.NoError(t, err, "load network config should not throw error")
+	defer unloadNetwork("reload-network")
+
+	reloaded := make(chan string, 1)
+	OnNetworkReload(func(name string) {
+		if name == "reload-network" {
+			reloaded <- name
+		}
+	})
+	client := testRegistryClient("reload-network")
+	clientRegistry.Put("reload-network.mychannel", client)
+
+	// invalid config is ignored
+	require.NoError(t, ioutil.WriteFile(configPath, []byte("name: [v2\n"), 0644), "write config should not throw error")
+	time.Sleep(2 * reloadDelay)
+	config, _ := LookupNetwork("reload-network")
+	assert.Equal(t, "name: v1\n", string(config), "invalid config should not be loaded")
+
+	require.NoError(t, ioutil.WriteFile(configPath, []byte("name: v2\n"), 0644), "write config should not throw error")
+	select {
+	case <-reloaded:
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "network config should be reloaded")
+	}
+	config, _ = LookupNetwork("reload-network")
+	assert.Equal(t, "name: v2\n", string(config), "updated config should be loaded")
+	_, ok := clientRegistry.Get("reload-network.mychannel")
+	assert.False(t, ok, "client of previous config should be removed")
+	assert.Eventually(t, func() bool {
+		_, err := client.QueryChaincode("basic", "ReadAsset", nil, nil)
+		return err != nil
+	}, time.Second, 10*time.Millisecond, "client of previous config should be closed")
+}
+
+func TestRetireHeldSDK(t *testing.T) {
+	// SDK of a network config without orgs or peers, which does not read crypto files
+	spec := ConnectorSpec{Name: "retire-sdk", NetworkConfig: []byte("name: retire-sdk\nversion: 1.0.0\n")}
+
+	held, err := SharedSDK(spec)
+	require.NoError(t, err, "create shared SDK should not throw error")
+	retireSDK(spec.Name)
+	sdkLock.Lock()
+	_, open := sdkRefs[held]
+	sdkLock.Unlock()
+	assert.True(t, open, "retired SDK should not be closed while it is held")
+
+	current, err := SharedSDK(spec)
+	require.NoError(t, err, "create shared SDK should not throw error")
+	assert.NotEqual(t, held, current, "new requests should not use the retired SDK")
+
+	ReleaseSDK(held)
+	sdkLock.Lock()
+	_, open = sdkRefs[held]
+	sdkLock.Unlock()
+	assert.False(t, open, "retired SDK should be closed after it is released")
+
+	ReleaseSDK(current)
+	retireSDK(spec.Name)
+}
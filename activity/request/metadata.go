@@ -23,30 +23,92 @@ type Attribute struct {
 
 // Settings of the activity
 type Settings struct {
-	ConnectionName  string       `md:"connectionName,required"`
-	ChannelID       string       `md:"channelID,required"`
-	ChaincodeID     string       `md:"chaincodeID,required"`
-	TransactionName string       `md:"transactionName,required"`
-	Arguments       []*Attribute `md:"arguments"`
-	RequestType     string       `md:"requestType,required"`
-	UserOrgOnly     bool         `md:"userOrgOnly"`
+	ConnectionName    string       `md:"connectionName,required"`
+	ChannelID         string       `md:"channelID,required"`
+	ChaincodeID       string       `md:"chaincodeID,required"`
+	TransactionName   string       `md:"transactionName,required"`
+	Arguments         []*Attribute `md:"arguments"`
+	RequestType       string       `md:"requestType,required"`
+	UserOrgOnly       bool         `md:"userOrgOnly"`
+	EndorsementPolicy string       `md:"endorsementPolicy"`
+	// retry policy of failed requests
+	RetryAttempts             int     `md:"retryAttempts"`
+	RetryInitialBackoffMillis int     `md:"retryInitialBackoffMillis"`
+	RetryMaxBackoffMillis     int     `md:"retryMaxBackoffMillis"`
+	RetryBackoffFactor        float64 `md:"retryBackoffFactor"`
+	RetryOn                   string  `md:"retryOn"`
+	ResubmitOnConflict        bool    `md:"resubmitOnConflict"`
+	// network config files loaded at runtime
+	NetworkConfigFile  string `md:"networkConfigFile"`
+	EntityMatchersFile string `md:"entityMatchersFile"`
+	// wallet of client identities
+	Wallet           string `md:"wallet"`
+	WalletPassphrase string `md:"walletPassphrase"`
+	// field-level encryption of transient data and decryption of query results
+	EncryptedTransient  string `md:"encryptedTransient"`
+	EncryptionKey       string `md:"encryptionKey"`
+	EncryptionRecipient string `md:"encryptionRecipient"`
+	DecryptionKey       string `md:"decryptionKey"`
 }
 
 // Input of the activity
 type Input struct {
 	OrgName       string                 `md:"orgName"`
-	UserName      string                 `md:"userName,required"`
+	UserName      string                 `md:"userName"`
 	Parameters    map[string]interface{} `md:"parameters"`
 	Transient     map[string]interface{} `md:"transient"`
 	TimeoutMillis int                    `md:"timeoutMillis"`
 	Endpoints     []string               `md:"endpoints"`
+	TransactionID string                 `md:"transactionID"`
+	TargetOrgs    []string               `md:"targetOrgs"`
+	// identity supplied by the caller to sign a single request
+	MspID       string `md:"mspID"`
+	Certificate string `md:"certificate"`
+	PrivateKey  string `md:"privateKey"`
+	Wallet      string `md:"wallet"`
+	// base64 encoded request and signature of an external signer
+	Proposal    string `md:"proposal"`
+	Transaction string `md:"transaction"`
+	Signature   string `md:"signature"`
 }
 
 // Output of the activity
 type Output struct {
-	Code    int         `md:"code"`
-	Message string      `md:"message"`
-	Result  interface{} `md:"result"`
+	Code           int           `md:"code"`
+	Message        string        `md:"message"`
+	ErrorType      string        `md:"errorType"`
+	Result         interface{}   `md:"result"`
+	TransactionID  string        `md:"transactionID"`
+	ValidationCode string        `md:"validationCode"`
+	Endorsers      []interface{} `md:"endorsers"`
+	BlockNumber    uint64        `md:"blockNumber"`
+}
+
+// retryPolicy returns the retry policy of the settings, or nil if no retry setting is specified
+func (h *Settings) retryPolicy() (*RetryPolicy, error) {
+	if h.RetryAttempts == 0 && h.RetryInitialBackoffMillis == 0 && h.RetryMaxBackoffMillis == 0 &&
+		h.RetryBackoffFactor == 0 && len(h.RetryOn) == 0 && !h.ResubmitOnConflict {
+		return nil, nil
+	}
+	return NewRetryPolicy(h.RetryAttempts, h.RetryInitialBackoffMillis, h.RetryMaxBackoffMillis, h.RetryBackoffFactor, h.RetryOn, h.ResubmitOnConflict)
+}
+
+// fieldCipher returns the cipher and transient fields to encrypt, or nil if no encryption setting is specified
+func (h *Settings) fieldCipher() (*FieldCipher, []string, error) {
+	var fields []string
+	for _, f := range strings.Split(h.EncryptedTransient, ",") {
+		if f = strings.TrimSpace(f); len(f) > 0 {
+			fields = append(fields, f)
+		}
+	}
+	if len(fields) > 0 && len(h.EncryptionKey) == 0 && len(h.EncryptionRecipient) == 0 {
+		return nil, nil, errors.New("encryptionKey or encryptionRecipient must be specified to encrypt transient fields")
+	}
+	cipher, err := NewFieldCipher(h.EncryptionKey, h.EncryptionRecipient, h.DecryptionKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cipher, fields, nil
 }
 
 // construct Attribute from map of name and type
@@ -75,6 +137,18 @@ func (h *Settings) FromMap(values map[string]interface{}) error {
 	if h.ConnectionName, err = coerce.ToString(values["connectionName"]); err != nil {
 		return err
 	}
+	if h.NetworkConfigFile, err = coerce.ToString(values["networkConfigFile"]); err != nil {
+		return err
+	}
+	if h.EntityMatchersFile, err = coerce.ToString(values["entityMatchersFile"]); err != nil {
+		return err
+	}
+	if h.Wallet, err = coerce.ToString(values["wallet"]); err != nil {
+		return err
+	}
+	if h.WalletPassphrase, err = coerce.ToString(values["walletPassphrase"]); err != nil {
+		return err
+	}
 	if h.ChannelID, err = coerce.ToString(values["channelID"]); err != nil {
 		return err
 	}
@@ -90,6 +164,39 @@ func (h *Settings) FromMap(values map[string]interface{}) error {
 	if h.UserOrgOnly, err = coerce.ToBool(values["userOrgOnly"]); err != nil {
 		return err
 	}
+	if h.EndorsementPolicy, err = coerce.ToString(values["endorsementPolicy"]); err != nil {
+		return err
+	}
+	if h.RetryAttempts, err = coerce.ToInt(values["retryAttempts"]); err != nil {
+		return err
+	}
+	if h.RetryInitialBackoffMillis, err = coerce.ToInt(values["retryInitialBackoffMillis"]); err != nil {
+		return err
+	}
+	if h.RetryMaxBackoffMillis, err = coerce.ToInt(values["retryMaxBackoffMillis"]); err != nil {
+		return err
+	}
+	if h.RetryBackoffFactor, err = coerce.ToFloat64(values["retryBackoffFactor"]); err != nil {
+		return err
+	}
+	if h.RetryOn, err = coerce.ToString(values["retryOn"]); err != nil {
+		return err
+	}
+	if h.ResubmitOnConflict, err = coerce.ToBool(values["resubmitOnConflict"]); err != nil {
+		return err
+	}
+	if h.EncryptedTransient, err = coerce.ToString(values["encryptedTransient"]); err != nil {
+		return err
+	}
+	if h.EncryptionKey, err = coerce.ToString(values["encryptionKey"]); err != nil {
+		return err
+	}
+	if h.EncryptionRecipient, err = coerce.ToString(values["encryptionRecipient"]); err != nil {
+		return err
+	}
+	if h.DecryptionKey, err = coerce.ToString(values["decryptionKey"]); err != nil {
+		return err
+	}
 
 	params, err := coerce.ToString(values["parameters"])
 	if err != nil {
@@ -121,6 +228,10 @@ func (i *Input) ToMap() map[string]interface{} {
 	for _, p := range i.Endpoints {
 		eps = append(eps, p)
 	}
+	var orgs []interface{}
+	for _, o := range i.TargetOrgs {
+		orgs = append(orgs, o)
+	}
 
 	user := i.UserName
 	if len(i.OrgName) > 0 {
@@ -133,6 +244,15 @@ func (i *Input) ToMap() map[string]interface{} {
 		"endpoints":     eps,
 		"parameters":    i.Parameters,
 		"transient":     i.Transient,
+		"transactionID": i.TransactionID,
+		"targetOrgs":    orgs,
+		"mspID":         i.MspID,
+		"certificate":   i.Certificate,
+		"privateKey":    i.PrivateKey,
+		"wallet":        i.Wallet,
+		"proposal":      i.Proposal,
+		"transaction":   i.Transaction,
+		"signature":     i.Signature,
 	}
 }
 
@@ -161,6 +281,30 @@ func (i *Input) FromMap(values map[string]interface{}) error {
 	if i.Transient, err = coerce.ToObject(values["transient"]); err != nil {
 		return err
 	}
+	if i.TransactionID, err = coerce.ToString(values["transactionID"]); err != nil {
+		return err
+	}
+	if i.MspID, err = coerce.ToString(values["mspID"]); err != nil {
+		return err
+	}
+	if i.Certificate, err = coerce.ToString(values["certificate"]); err != nil {
+		return err
+	}
+	if i.PrivateKey, err = coerce.ToString(values["privateKey"]); err != nil {
+		return err
+	}
+	if i.Wallet, err = coerce.ToString(values["wallet"]); err != nil {
+		return err
+	}
+	if i.Proposal, err = coerce.ToString(values["proposal"]); err != nil {
+		return err
+	}
+	if i.Transaction, err = coerce.ToString(values["transaction"]); err != nil {
+		return err
+	}
+	if i.Signature, err = coerce.ToString(values["signature"]); err != nil {
+		return err
+	}
 
 	var eps interface{}
 	if eps, err = coerce.ToAny(values["endpoints"]); err != nil {
@@ -180,15 +324,40 @@ func (i *Input) FromMap(values map[string]interface{}) error {
 			i.Endpoints = []string{p}
 		}
 	}
+
+	var orgs interface{}
+	if orgs, err = coerce.ToAny(values["targetOrgs"]); err != nil {
+		return err
+	}
+	switch v := orgs.(type) {
+	case []interface{}:
+		for _, d := range v {
+			if o, ok := d.(string); ok {
+				i.TargetOrgs = append(i.TargetOrgs, splitOrgs(o)...)
+			}
+		}
+	case string:
+		i.TargetOrgs = splitOrgs(v)
+	}
 	return nil
 }
 
+// callerIdentity returns true if the input supplies the identity to sign the request
+func (i *Input) callerIdentity() bool {
+	return len(i.Certificate) > 0 || len(i.Wallet) > 0
+}
+
 // ToMap converts activity output to a map
 func (o *Output) ToMap() map[string]interface{} {
 	return map[string]interface{}{
-		"code":    o.Code,
-		"message": o.Message,
-		"result":  o.Result,
+		"code":           o.Code,
+		"message":        o.Message,
+		"errorType":      o.ErrorType,
+		"result":         o.Result,
+		"transactionID":  o.TransactionID,
+		"validationCode": o.ValidationCode,
+		"endorsers":      o.Endorsers,
+		"blockNumber":    o.BlockNumber,
 	}
 }
 
@@ -202,9 +371,26 @@ func (o *Output) FromMap(values map[string]interface{}) error {
 	if o.Message, err = coerce.ToString(values["message"]); err != nil {
 		return err
 	}
+	if o.ErrorType, err = coerce.ToString(values["errorType"]); err != nil {
+		return err
+	}
 	if o.Result, err = coerce.ToAny(values["result"]); err != nil {
 		return err
 	}
+	if o.TransactionID, err = coerce.ToString(values["transactionID"]); err != nil {
+		return err
+	}
+	if o.ValidationCode, err = coerce.ToString(values["validationCode"]); err != nil {
+		return err
+	}
+	if o.Endorsers, err = coerce.ToArray(values["endorsers"]); err != nil {
+		return err
+	}
+	blockNumber, err := coerce.ToInt64(values["blockNumber"])
+	if err != nil {
+		return err
+	}
+	o.BlockNumber = uint64(blockNumber)
 
 	return nil
 }
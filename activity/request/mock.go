@@ -0,0 +1,328 @@
+/*
+SPDX-License-Identifier: BSD-3-Clause-Open-MPI
+*/
+
+package request
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	cb "github.com/hyperledger/fabric-protos-go/common"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/status"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	"github.com/pkg/errors"
+)
+
+// MockBackend is an in-memory Backend that can be scripted with expected chaincode calls
+// and their canned responses. It can be registered by RegisterBackend to test Flogo flows
+// that use the Fabric Request activity without a running Fabric network.
+type MockBackend struct {
+	sync.Mutex
+	calls       []*MockCall
+	txStatus    map[string]*TransactionStatus
+	blockNumber uint64
+}
+
+// MockCall describes an expected chaincode call and the response returned for it
+type MockCall struct {
+	RequestType    string
+	ChaincodeID    string
+	Fcn            string
+	Args           []string
+	Payload        []byte
+	Status         int32
+	Err            error
+	TransactionID  string
+	ValidationCode string
+	repeat         bool
+	count          int
+	transient      map[string][]byte
+	backend        *MockBackend
+}
+
+// NewMockBackend returns an empty mock backend
+func NewMockBackend() *MockBackend {
+	return &MockBackend{txStatus: make(map[string]*TransactionStatus)}
+}
+
+// On adds an expected call of a request type, i.e., invoke, submit, simulate, endorse or query, for a chaincode transaction.
+// If no args is specified, the call matches any arguments.
+// The call returns status 200 and no data unless its response is set by Return or ReturnError.
+func (m *MockBackend) On(requestType, ccID, fcn string, args ...string) *MockCall {
+	m.Lock()
+	defer m.Unlock()
+	call := &MockCall{
+		RequestType: requestType,
+		ChaincodeID: ccID,
+		Fcn:         fcn,
+		Args:        args,
+		Status:      200,
+		backend:     m,
+	}
+	m.calls = append(m.calls, call)
+	return call
+}
+
+// Return sets the payload and chaincode status returned by the call
+func (c *MockCall) Return(payload []byte, status int32) *MockCall {
+	c.Payload = payload
+	c.Status = status
+	return c
+}
+
+// ReturnError sets the error returned by the call
+func (c *MockCall) ReturnError(err error) *MockCall {
+	c.Err = err
+	return c
+}
+
+// WithTransaction sets the transaction ID and validation code, e.g., MVCC_READ_CONFLICT, of the call.
+// By default, a random transaction ID is returned, and invoke or submit transactions are committed as VALID.
+// It panics if the validation code is not a name of Fabric TxValidationCode, so a mistyped code does not commit the transaction.
+func (c *MockCall) WithTransaction(txID, validationCode string) *MockCall {
+	if _, ok := pb.TxValidationCode_value[validationCode]; len(validationCode) > 0 && !ok {
+		panic(fmt.Sprintf("unknown validation code %s of mock call %s", validationCode, c))
+	}
+	c.TransactionID = txID
+	c.ValidationCode = validationCode
+	return c
+}
+
+// Repeatedly lets the call match any number of requests; by default, a call matches only one request.
+func (c *MockCall) Repeatedly() *MockCall {
+	c.repeat = true
+	return c
+}
+
+// Count returns the number of requests that have matched the call
+func (c *MockCall) Count() int {
+	c.backend.Lock()
+	defer c.backend.Unlock()
+	return c.count
+}
+
+// Transient returns the transient data of the last request that matched the call
+func (c *MockCall) Transient() map[string][]byte {
+	c.backend.Lock()
+	defer c.backend.Unlock()
+	return c.transient
+}
+
+func (c *MockCall) String() string {
+	return fmt.Sprintf("%s %s.%s(%s)", c.RequestType, c.ChaincodeID, c.Fcn, strings.Join(c.Args, ", "))
+}
+
+func (c *MockCall) matches(requestType string, request channel.Request) bool {
+	if c.RequestType != requestType || c.ChaincodeID != request.ChaincodeID || c.Fcn != request.Fcn {
+		return false
+	}
+	if len(c.Args) == 0 {
+		return true
+	}
+	if len(c.Args) != len(request.Args) {
+		return false
+	}
+	for i, a := range c.Args {
+		if a != string(request.Args[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Query implements Backend.Query
+func (m *MockBackend) Query(request channel.Request, options ...channel.RequestOption) (Response, error) {
+	return m.call(opQuery, request)
+}
+
+// Execute implements Backend.Execute
+func (m *MockBackend) Execute(request channel.Request, options ...channel.RequestOption) (Response, error) {
+	return m.call(opInvoke, request)
+}
+
+// Submit implements Backend.Submit
+func (m *MockBackend) Submit(request channel.Request, options ...channel.RequestOption) (Response, error) {
+	return m.call(opSubmit, request)
+}
+
+// Simulate implements Backend.Simulate
+func (m *MockBackend) Simulate(request channel.Request, options ...channel.RequestOption) (Response, error) {
+	return m.call(opSimulate, request)
+}
+
+// TransactionStatus implements Backend.TransactionStatus.
+// It returns status of transactions committed by expected invoke or submit calls,
+// or a pending status for unknown transaction IDs.
+func (m *MockBackend) TransactionStatus(txID string) (*TransactionStatus, error) {
+	m.Lock()
+	defer m.Unlock()
+	if s, ok := m.txStatus[txID]; ok {
+		result := *s
+		return &result, nil
+	}
+	return &TransactionStatus{TransactionID: txID}, nil
+}
+
+// ProcessSignedProposal implements OfflineBackend.ProcessSignedProposal.
+// The proposal matches expected calls of the endorse request type, and each target returns the same endorsement.
+func (m *MockBackend) ProcessSignedProposal(channelID string, proposal *pb.SignedProposal, targets []string, timeout time.Duration) ([]*fab.TransactionProposalResponse, error) {
+	prop := &pb.Proposal{}
+	if err := proto.Unmarshal(proposal.ProposalBytes, prop); err != nil {
+		return nil, err
+	}
+	cpp := &pb.ChaincodeProposalPayload{}
+	if err := proto.Unmarshal(prop.Payload, cpp); err != nil {
+		return nil, err
+	}
+	cis := &pb.ChaincodeInvocationSpec{}
+	if err := proto.Unmarshal(cpp.Input, cis); err != nil {
+		return nil, err
+	}
+	args := cis.GetChaincodeSpec().GetInput().GetArgs()
+	if len(args) == 0 {
+		return nil, errors.New("proposal does not specify a chaincode function")
+	}
+	response, err := m.call(opEndorse, channel.Request{
+		ChaincodeID: cis.GetChaincodeSpec().GetChaincodeId().GetName(),
+		Fcn:         string(args[0]),
+		Args:        args[1:],
+	})
+	if err != nil {
+		return nil, err
+	}
+	if response.ChaincodeStatus >= 400 {
+		return nil, status.New(status.ChaincodeStatus, response.ChaincodeStatus, string(response.Payload), nil)
+	}
+	ccResponse := &pb.Response{Status: response.ChaincodeStatus, Payload: response.Payload}
+	action, err := proto.Marshal(&pb.ChaincodeAction{Response: ccResponse})
+	if err != nil {
+		return nil, err
+	}
+	payload, err := proto.Marshal(&pb.ProposalResponsePayload{Extension: action})
+	if err != nil {
+		return nil, err
+	}
+	var result []*fab.TransactionProposalResponse
+	for _, t := range targets {
+		result = append(result, &fab.TransactionProposalResponse{
+			Endorser:        t,
+			Status:          200,
+			ChaincodeStatus: ccResponse.Status,
+			ProposalResponse: &pb.ProposalResponse{
+				Response:    ccResponse,
+				Payload:     payload,
+				Endorsement: &pb.Endorsement{},
+			},
+		})
+	}
+	return result, nil
+}
+
+// BroadcastSignedTransaction implements OfflineBackend.BroadcastSignedTransaction.
+// The transaction is committed as VALID immediately.
+func (m *MockBackend) BroadcastSignedTransaction(channelID string, envelope *fab.SignedEnvelope, timeout time.Duration) error {
+	payload := &cb.Payload{}
+	if err := proto.Unmarshal(envelope.Payload, payload); err != nil {
+		return err
+	}
+	chdr := &cb.ChannelHeader{}
+	if err := proto.Unmarshal(payload.Header.ChannelHeader, chdr); err != nil {
+		return err
+	}
+	m.Lock()
+	defer m.Unlock()
+	m.blockNumber++
+	m.txStatus[chdr.TxId] = &TransactionStatus{
+		TransactionID:  chdr.TxId,
+		Committed:      true,
+		ValidationCode: pb.TxValidationCode_VALID.String(),
+		BlockNumber:    m.blockNumber,
+	}
+	return nil
+}
+
+// returns response of the first expected call that matches the request
+func (m *MockBackend) call(requestType string, request channel.Request) (Response, error) {
+	m.Lock()
+	defer m.Unlock()
+	for _, c := range m.calls {
+		if (c.count == 0 || c.repeat) && c.matches(requestType, request) {
+			c.count++
+			c.transient = request.TransientMap
+			if c.Err != nil {
+				return Response{}, c.Err
+			}
+			txID := c.TransactionID
+			if len(txID) == 0 {
+				txID = randomTxID()
+			}
+			response := Response{Response: channel.Response{
+				TransactionID:   fab.TransactionID(txID),
+				Payload:         c.Payload,
+				ChaincodeStatus: c.Status,
+			}}
+			if requestType == opQuery || requestType == opSimulate || requestType == opEndorse {
+				return response, nil
+			}
+
+			// commit the transaction
+			code := pb.TxValidationCode_VALID
+			if len(c.ValidationCode) > 0 {
+				code = pb.TxValidationCode(pb.TxValidationCode_value[c.ValidationCode])
+			}
+			m.blockNumber++
+			m.txStatus[txID] = &TransactionStatus{
+				TransactionID:  txID,
+				Committed:      true,
+				ValidationCode: code.String(),
+				BlockNumber:    m.blockNumber,
+			}
+			if requestType == opSubmit {
+				return response, nil
+			}
+			response.TxValidationCode = code
+			response.BlockNumber = m.blockNumber
+			if code != pb.TxValidationCode_VALID {
+				return response, status.New(status.EventServerStatus, int32(code), "received invalid transaction", nil)
+			}
+			return response, nil
+		}
+	}
+	var args []string
+	for _, a := range request.Args {
+		args = append(args, string(a))
+	}
+	return Response{}, errors.Errorf("unexpected %s request %s.%s(%s)", requestType, request.ChaincodeID, request.Fcn, strings.Join(args, ", "))
+}
+
+// ExpectationsMet returns an error if any expected call has not been requested
+func (m *MockBackend) ExpectationsMet() error {
+	m.Lock()
+	defer m.Unlock()
+	var missed []string
+	for _, c := range m.calls {
+		if c.count == 0 {
+			missed = append(missed, c.String())
+		}
+	}
+	if len(missed) > 0 {
+		return errors.Errorf("expected calls not requested: %s", strings.Join(missed, "; "))
+	}
+	return nil
+}
+
+func randomTxID() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", b)
+	}
+	return hex.EncodeToString(b)
+}
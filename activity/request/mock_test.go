@@ -0,0 +1,341 @@
+/*
+SPDX-License-Identifier: BSD-3-Clause-Open-MPI
+*/
+
+package request
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/status"
+	"github.com/pkg/errors"
+	"github.com/project-flogo/core/activity"
+	"github.com/project-flogo/core/data/mapper"
+	"github.com/project-flogo/core/data/resolve"
+	"github.com/project-flogo/core/support/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// This test does not require a Fabric network
+func TestMockBackend(t *testing.T) {
+	mock := NewMockBackend()
+	mock.On(opInvoke, ccID, "CreateAsset", "asset7", "blue").Return(nil, 200)
+	mock.On(opQuery, ccID, "ReadAsset").Return([]byte(`{"ID":"asset7"}`), 200).Repeatedly()
+	mock.On(opInvoke, ccID, "DeleteAsset").ReturnError(errors.New("asset not found"))
+	RegisterBackend("mock-client", mock)
+	defer UnregisterBackend("mock-client")
+
+	fbClient, err := NewFabricClient(ConnectorSpec{
+		Name:      "mock-client",
+		UserName:  user,
+		ChannelID: channelID,
+	})
+	require.NoError(t, err, "failed to create mock fabric client")
+	defer fbClient.Release()
+
+	response, err := fbClient.ExecuteChaincode(ccID, "CreateAsset", [][]byte{[]byte("asset7"), []byte("blue")}, nil)
+	assert.NoError(t, err, "expected invoke should not throw error")
+	assert.Equal(t, int32(200), response.ChaincodeStatus, "status of expected invoke should be 200")
+
+	_, err = fbClient.ExecuteChaincode(ccID, "CreateAsset", [][]byte{[]byte("asset7"), []byte("blue")}, nil)
+	assert.Error(t, err, "invoke should match an expected call only once")
+
+	for i := 0; i < 2; i++ {
+		response, err := fbClient.QueryChaincode(ccID, "ReadAsset", [][]byte{[]byte("asset7")}, nil)
+		assert.NoError(t, err, "repeated query should not throw error")
+		assert.Equal(t, `{"ID":"asset7"}`, string(response.Payload), "query should return canned payload")
+	}
+
+	_, err = fbClient.QueryChaincode(ccID, "CreateAsset", [][]byte{[]byte("asset7"), []byte("blue")}, nil)
+	assert.Error(t, err, "query should not match expected invoke")
+
+	assert.Error(t, mock.ExpectationsMet(), "DeleteAsset is not requested")
+	_, err = fbClient.ExecuteChaincode(ccID, "DeleteAsset", [][]byte{[]byte("asset7")}, nil)
+	assert.EqualError(t, err, "asset not found", "invoke should return canned error")
+	assert.NoError(t, mock.ExpectationsMet(), "all expected calls should be requested")
+}
+
+func TestMockCallCount(t *testing.T) {
+	mock := NewMockBackend()
+	query := mock.On(opQuery, ccID, "ReadAsset").Repeatedly()
+
+	// run with -race to verify that counts are read under the lock of the mock backend
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := mock.Query(channel.Request{ChaincodeID: ccID, Fcn: "ReadAsset"})
+			assert.NoError(t, err, "expected query should not throw error")
+			query.Count()
+			query.Transient()
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 4, query.Count(), "ReadAsset should be requested 4 times")
+}
+
+func TestMockProposalWithoutArgs(t *testing.T) {
+	input, err := proto.Marshal(&pb.ChaincodeInvocationSpec{ChaincodeSpec: &pb.ChaincodeSpec{
+		ChaincodeId: &pb.ChaincodeID{Name: ccID},
+		Input:       &pb.ChaincodeInput{},
+	}})
+	require.NoError(t, err, "marshal invocation spec should not throw error")
+	payload, err := proto.Marshal(&pb.ChaincodeProposalPayload{Input: input})
+	require.NoError(t, err, "marshal proposal payload should not throw error")
+	prop, err := proto.Marshal(&pb.Proposal{Payload: payload})
+	require.NoError(t, err, "marshal proposal should not throw error")
+
+	mock := NewMockBackend()
+	mock.On(opEndorse, ccID, "ReadAsset").Repeatedly()
+	_, err = mock.ProcessSignedProposal(channelID, &pb.SignedProposal{ProposalBytes: prop}, []string{"peer0"}, 0)
+	assert.Error(t, err, "proposal without chaincode function should throw error")
+}
+
+func TestMockReadAsset(t *testing.T) {
+	mock := NewMockBackend()
+	readAsset := mock.On(opQuery, "basic", "ReadAsset", "asset1").Return([]byte(`{"ID":"asset1","owner":"Tomoko"}`), 200)
+	RegisterBackend("mock-network", mock)
+	defer UnregisterBackend("mock-network")
+
+	// configure request activity
+	settings := map[string]interface{}{
+		"connectionName":  "mock-network",
+		"channelID":       "mychannel",
+		"chaincodeID":     "basic",
+		"transactionName": "ReadAsset",
+		"parameters":      "id",
+		"requestType":     "query",
+	}
+	mf := mapper.NewFactory(resolve.GetBasicResolver())
+	ctx := test.NewActivityInitContext(settings, mf)
+	act, err := New(ctx)
+	assert.NoError(t, err, "create activity instance should not throw error")
+
+	tc := test.NewActivityContext(act.Metadata())
+
+	// input data
+	req := `{
+		"userName": "Admin",
+		"parameters": {
+			"id": "asset1"
+		}
+	}`
+	var data map[string]interface{}
+	err = json.Unmarshal([]byte(req), &data)
+	assert.NoError(t, err, "input data should be valid JSON object")
+
+	input := &Input{}
+	err = input.FromMap(data)
+	assert.NoError(t, err, "create input from map should not throw error")
+	err = tc.SetInputObject(input)
+	assert.NoError(t, err, "setting action input should not throw error")
+
+	// process request
+	done, err := act.Eval(tc)
+	assert.True(t, done, "action eval should be successful")
+	assert.NoError(t, err, "action eval should not throw error")
+	assert.Equal(t, 1, readAsset.Count(), "ReadAsset should be requested once")
+
+	// verify activity output
+	output := &Output{}
+	err = tc.GetOutputObject(output)
+	assert.NoError(t, err, "action output should not be error")
+	assert.Equal(t, 200, output.Code, "output status code should be 200")
+	result, ok := output.Result.(map[string]interface{})
+	assert.True(t, ok, "result should be a JSON object")
+	assert.Equal(t, "Tomoko", result["owner"], "owner of asset1 should be 'Tomoko'")
+}
+
+func TestMockSubmitStatus(t *testing.T) {
+	mock := NewMockBackend()
+	assert.Panics(t, func() {
+		mock.On(opSubmit, "basic", "TransferAsset").WithTransaction("tx-unknown", "MVCC_CONFLICT")
+	}, "unknown validation code should panic")
+	mock = NewMockBackend()
+	mock.On(opSubmit, "basic", "TransferAsset").Return([]byte("Tomoko"), 200)
+	mock.On(opSubmit, "basic", "TransferAsset").WithTransaction("tx-conflict", "MVCC_READ_CONFLICT")
+	RegisterBackend("mock-network", mock)
+	defer UnregisterBackend("mock-network")
+
+	settings := map[string]interface{}{
+		"connectionName":  "mock-network",
+		"channelID":       "mychannel",
+		"chaincodeID":     "basic",
+		"transactionName": "TransferAsset",
+		"parameters":      "id,newOwner",
+		"requestType":     "submit",
+	}
+	req := `{
+		"userName": "Admin",
+		"parameters": {
+			"id": "asset1",
+			"newOwner": "Jose"
+		}
+	}`
+	output, done, err := evalActivity(t, settings, req)
+	assert.True(t, done, "submit should be successful")
+	assert.NoError(t, err, "submit should not throw error")
+	assert.Equal(t, 200, output.Code, "output status code should be 200")
+	assert.Equal(t, "Tomoko", output.Message, "submit should return endorsement payload")
+	assert.NotEmpty(t, output.TransactionID, "submit should return transaction ID")
+	txID := output.TransactionID
+
+	output, _, err = evalActivity(t, settings, req)
+	assert.NoError(t, err, "submit should not throw error")
+	assert.Equal(t, "tx-conflict", output.TransactionID, "submit should return scripted transaction ID")
+
+	// check status of submitted transactions
+	settings["requestType"] = "status"
+	output, done, err = evalActivity(t, settings, `{"userName": "Admin", "transactionID": "`+txID+`"}`)
+	assert.True(t, done, "status should be successful")
+	assert.NoError(t, err, "status should not throw error")
+	assert.Equal(t, 200, output.Code, "status of valid transaction should be 200")
+	result := output.Result.(map[string]interface{})
+	assert.Equal(t, "VALID", result["validationCode"], "transaction should be valid")
+	assert.Equal(t, uint64(1), result["blockNumber"], "transaction should be committed in block 1")
+
+	output, _, _ = evalActivity(t, settings, `{"userName": "Admin", "transactionID": "tx-conflict"}`)
+	assert.Equal(t, 409, output.Code, "status of invalid transaction should be 409")
+	assert.Equal(t, "MVCC_READ_CONFLICT", output.Result.(map[string]interface{})["validationCode"], "transaction should be invalidated")
+
+	output, _, _ = evalActivity(t, settings, `{"userName": "Admin", "transactionID": "tx-unknown"}`)
+	assert.Equal(t, 202, output.Code, "status of pending transaction should be 202")
+	assert.Equal(t, false, output.Result.(map[string]interface{})["committed"], "transaction should not be committed")
+
+	_, done, err = evalActivity(t, settings, `{"userName": "Admin"}`)
+	assert.False(t, done, "status without transaction ID should fail")
+	assert.Error(t, err, "status without transaction ID should throw error")
+}
+
+// evaluates request activity of specified settings and JSON input
+func evalActivity(t *testing.T, settings map[string]interface{}, req string) (*Output, bool, error) {
+	mf := mapper.NewFactory(resolve.GetBasicResolver())
+	ctx := test.NewActivityInitContext(settings, mf)
+	act, err := New(ctx)
+	require.NoError(t, err, "create activity instance should not throw error")
+
+	tc := test.NewActivityContext(act.Metadata())
+	var data map[string]interface{}
+	err = json.Unmarshal([]byte(req), &data)
+	require.NoError(t, err, "input data should be valid JSON object")
+	input := &Input{}
+	err = input.FromMap(data)
+	require.NoError(t, err, "create input from map should not throw error")
+	err = tc.SetInputObject(input)
+	require.NoError(t, err, "setting action input should not throw error")
+
+	done, err := act.Eval(tc)
+	output := &Output{}
+	require.NoError(t, tc.GetOutputObject(output), "action output should not be error")
+	return output, done, err
+}
+
+func TestMockInvokeOutput(t *testing.T) {
+	mock := NewMockBackend()
+	mock.On(opInvoke, "basic", "TransferAsset").WithTransaction("tx-valid", "")
+	mock.On(opInvoke, "basic", "TransferAsset").WithTransaction("tx-conflict", "MVCC_READ_CONFLICT")
+	RegisterBackend("mock-network", mock)
+	defer UnregisterBackend("mock-network")
+
+	settings := map[string]interface{}{
+		"connectionName":  "mock-network",
+		"channelID":       "mychannel",
+		"chaincodeID":     "basic",
+		"transactionName": "TransferAsset",
+		"parameters":      "id,newOwner",
+		"requestType":     "invoke",
+	}
+	req := `{
+		"userName": "Admin",
+		"parameters": {
+			"id": "asset1",
+			"newOwner": "Jose"
+		}
+	}`
+	output, done, err := evalActivity(t, settings, req)
+	assert.True(t, done, "invoke should be successful")
+	assert.NoError(t, err, "invoke should not throw error")
+	assert.Equal(t, "tx-valid", output.TransactionID, "invoke should return transaction ID")
+	assert.Equal(t, "VALID", output.ValidationCode, "invoke should return validation code")
+	assert.Equal(t, uint64(1), output.BlockNumber, "invoke should return block number")
+
+	output, done, err = evalActivity(t, settings, req)
+	assert.False(t, done, "invalid invoke should fail")
+	assert.Error(t, err, "invalid invoke should throw error")
+	assert.Equal(t, "tx-conflict", output.TransactionID, "invalid invoke should return transaction ID")
+	assert.Equal(t, "MVCC_READ_CONFLICT", output.ValidationCode, "invalid invoke should return validation code")
+	assert.Equal(t, 409, output.Code, "MVCC conflict should return status 409")
+	assert.Equal(t, ErrMVCCReadConflict, output.ErrorType, "invalid invoke should return error type")
+	assert.Equal(t, uint64(2), output.BlockNumber, "invalid invoke should return block number")
+}
+
+func TestMockChaincodeError(t *testing.T) {
+	mock := NewMockBackend()
+	mock.On(opQuery, "basic", "ReadAsset", "asset9").ReturnError(
+		status.New(status.EndorserServerStatus, 500, "transaction returned with failure: the asset asset9 does not exist", nil))
+	RegisterBackend("mock-network", mock)
+	defer UnregisterBackend("mock-network")
+
+	settings := map[string]interface{}{
+		"connectionName":  "mock-network",
+		"channelID":       "mychannel",
+		"chaincodeID":     "basic",
+		"transactionName": "ReadAsset",
+		"parameters":      "id",
+		"requestType":     "query",
+	}
+	output, done, err := evalActivity(t, settings, `{"userName": "Admin", "parameters": {"id": "asset9"}}`)
+	assert.False(t, done, "chaincode error should fail")
+	require.Error(t, err, "chaincode error should throw error")
+	assert.Equal(t, 422, output.Code, "chaincode error should return status 422")
+	assert.Equal(t, ErrEndorsementFailure, output.ErrorType, "chaincode error should return error type")
+	assert.Equal(t, "the asset asset9 does not exist", output.Message, "output should contain chaincode message")
+
+	actErr, ok := err.(*activity.Error)
+	require.True(t, ok, "chaincode error should be an activity error")
+	assert.Equal(t, ErrEndorsementFailure, actErr.Code(), "activity error code should be error type")
+	assert.Equal(t, 422, actErr.Data().(map[string]interface{})["code"], "activity error data should contain status code")
+}
+
+func TestMockSimulate(t *testing.T) {
+	mock := NewMockBackend()
+	mock.On(opSimulate, "basic", "TransferAsset").Return([]byte("Tomoko"), 200).WithTransaction("tx-simulated", "")
+	RegisterBackend("mock-network", mock)
+	defer UnregisterBackend("mock-network")
+
+	settings := map[string]interface{}{
+		"connectionName":  "mock-network",
+		"channelID":       "mychannel",
+		"chaincodeID":     "basic",
+		"transactionName": "TransferAsset",
+		"parameters":      "id,newOwner",
+		"requestType":     "simulate",
+	}
+	req := `{
+		"userName": "Admin",
+		"parameters": {
+			"id": "asset1",
+			"newOwner": "Jose"
+		}
+	}`
+	output, done, err := evalActivity(t, settings, req)
+	assert.True(t, done, "simulate should be successful")
+	assert.NoError(t, err, "simulate should not throw error")
+	assert.Equal(t, 200, output.Code, "output status code should be 200")
+	assert.Equal(t, "tx-simulated", output.TransactionID, "simulate should return transaction ID")
+	result := output.Result.(map[string]interface{})
+	assert.Equal(t, "Tomoko", result["payload"], "simulation result should contain chaincode payload")
+	assert.Equal(t, true, result["consistent"], "simulation without endorsements should be consistent")
+
+	// simulated transaction is never committed
+	settings["requestType"] = "status"
+	output, _, _ = evalActivity(t, settings, `{"userName": "Admin", "transactionID": "tx-simulated"}`)
+	assert.Equal(t, 202, output.Code, "simulated transaction should not be committed")
+}
@@ -0,0 +1,83 @@
+/*
+SPDX-License-Identifier: BSD-3-Clause-Open-MPI
+*/
+
+package request
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// networkSpec contains the network config and entity matchers of a named Fabric network
+type networkSpec struct {
+	config  []byte
+	matcher []byte
+}
+
+// network configs registered by connection name
+var (
+	networkLock sync.RWMutex
+	networkMap  = map[string]*networkSpec{}
+)
+
+// RegisterNetwork registers the network config and entity matchers of a Fabric network for a connection name,
+// so activities and triggers of the connection send requests to this network.
+// Connections that are not registered use the default network config set by InitializeNetwork.
+func RegisterNetwork(connectionName string, config, matcher []byte) {
+	networkLock.Lock()
+	defer networkLock.Unlock()
+	networkMap[connectionName] = &networkSpec{config: config, matcher: matcher}
+}
+
+// UnregisterNetwork removes the network config registered for a connection name
+func UnregisterNetwork(connectionName string) {
+	networkLock.Lock()
+	defer networkLock.Unlock()
+	delete(networkMap, connectionName)
+}
+
+// RegisteredNetworks returns sorted names of registered networks
+func RegisteredNetworks() []string {
+	networkLock.RLock()
+	defer networkLock.RUnlock()
+	var names []string
+	for k := range networkMap {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupNetwork returns the network config and entity matchers registered for a connection name,
+// or the default network config if the connection is not registered.
+func LookupNetwork(connectionName string) (config []byte, matcher []byte) {
+	networkLock.RLock()
+	defer networkLock.RUnlock()
+	if spec, ok := networkMap[connectionName]; ok {
+		return spec.config, spec.matcher
+	}
+	return NetworkConfig, EntityMatcher
+}
+
+// NetworkConnector returns the connector spec of the Fabric network for a connection name
+func NetworkConnector(connectionName string) ConnectorSpec {
+	config, matcher := LookupNetwork(connectionName)
+	return ConnectorSpec{
+		Name:           connectionName,
+		NetworkConfig:  config,
+		EntityMatchers: matcher,
+	}
+}
+
+// SplitUser returns user name and org name from user@org; org name is empty if it is not specified
+func SplitUser(userName string) (string, string) {
+	tokens := strings.Split(strings.TrimSpace(userName), "@")
+	user := strings.TrimSpace(tokens[0])
+	org := ""
+	if len(tokens) > 1 {
+		org = strings.TrimSpace(tokens[1])
+	}
+	return user, org
+}
@@ -0,0 +1,59 @@
+/*
+SPDX-License-Identifier: BSD-3-Clause-Open-MPI
+*/
+
+package request
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNetworkRegistry(t *testing.T) {
+	RegisterNetwork("test-consortium", []byte("test config"), nil)
+	RegisterNetwork("prod-consortium", []byte("prod config"), []byte("prod matchers"))
+	defer UnregisterNetwork("test-consortium")
+	defer UnregisterNetwork("prod-consortium")
+
+	assert.Equal(t, []string{"prod-consortium", "test-consortium"}, RegisteredNetworks(), "registered networks should be sorted by name")
+	config, matcher := LookupNetwork("prod-consortium")
+	assert.Equal(t, "prod config", string(config), "prod network should use its own config")
+	assert.Equal(t, "prod matchers", string(matcher), "prod network should use its own entity matchers")
+
+	spec := NetworkConnector("test-consortium")
+	assert.Equal(t, "test-consortium", spec.Name, "connector name should be the connection name")
+	assert.Equal(t, "test config", string(spec.NetworkConfig), "test network should use its own config")
+	assert.Empty(t, spec.EntityMatchers, "test network should not have entity matchers")
+
+	config, _ = LookupNetwork("unknown")
+	assert.Equal(t, NetworkConfig, config, "unregistered connection should use default network config")
+}
+
+func TestMockNamedNetwork(t *testing.T) {
+	mock := NewMockBackend()
+	mock.On(opSimulate, "basic", "TransferAsset").Return([]byte("Tomoko"), 200).Repeatedly()
+	RegisterBackend("mock-network", mock)
+	defer UnregisterBackend("mock-network")
+
+	// Org3MSP is defined only in the network config registered for the connection
+	settings := map[string]interface{}{
+		"connectionName":    "mock-network",
+		"channelID":         "mychannel",
+		"chaincodeID":       "basic",
+		"transactionName":   "TransferAsset",
+		"parameters":        "id,newOwner",
+		"requestType":       "simulate",
+		"endorsementPolicy": "AND('Org1MSP.peer','Org3MSP.peer')",
+	}
+	req := `{"userName": "Admin", "parameters": {"id": "asset1", "newOwner": "Tomoko"}}`
+	_, done, _ := evalActivity(t, settings, req)
+	assert.False(t, done, "policy should not be satisfied by default network config")
+
+	RegisterNetwork("mock-network", policyTestConfig, nil)
+	defer UnregisterNetwork("mock-network")
+	output, done, err := evalActivity(t, settings, req)
+	assert.True(t, done, "simulate should use network config registered for the connection")
+	assert.NoError(t, err, "simulate should not throw error")
+	assert.Equal(t, 200, output.Code, "output status code should be 200")
+}
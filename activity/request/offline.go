@@ -0,0 +1,493 @@
+/*
+SPDX-License-Identifier: BSD-3-Clause-Open-MPI
+*/
+
+package request
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	cb "github.com/hyperledger/fabric-protos-go/common"
+	mspproto "github.com/hyperledger/fabric-protos-go/msp"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/status"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/context"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	contextImpl "github.com/hyperledger/fabric-sdk-go/pkg/context"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fab/txn"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fabsdk"
+	"github.com/pkg/errors"
+)
+
+// UnsignedRequest is a proposal or a transaction payload to be signed by an external signer, e.g., a browser wallet or an HSM,
+// so the private key of the signer is never sent to the server
+type UnsignedRequest struct {
+	TransactionID string
+	// Bytes is the serialized proposal or transaction payload
+	Bytes []byte
+	// Digest is the SHA-256 hash of the bytes, which is signed by the ECDSA key of the creator
+	Digest []byte
+}
+
+// ToMap converts unsigned request to a map of base64 encoded bytes and digest
+func (r *UnsignedRequest) ToMap() map[string]interface{} {
+	return map[string]interface{}{
+		"transactionID": r.TransactionID,
+		"bytes":         base64.StdEncoding.EncodeToString(r.Bytes),
+		"digest":        base64.StdEncoding.EncodeToString(r.Digest),
+	}
+}
+
+func newUnsignedRequest(txID string, data []byte) *UnsignedRequest {
+	digest := sha256.Sum256(data)
+	return &UnsignedRequest{TransactionID: txID, Bytes: data, Digest: digest[:]}
+}
+
+// OfflineBackend sends proposals and transactions signed by external signers to a Fabric network.
+// It is implemented by fabric-sdk-go, and by MockBackend for offline tests.
+type OfflineBackend interface {
+	// ProcessSignedProposal sends a signed proposal to endorsing peers, and returns their responses
+	ProcessSignedProposal(channelID string, proposal *pb.SignedProposal, targets []string, timeout time.Duration) ([]*fab.TransactionProposalResponse, error)
+	// BroadcastSignedTransaction sends a signed transaction envelope to an orderer of a channel
+	BroadcastSignedTransaction(channelID string, envelope *fab.SignedEnvelope, timeout time.Duration) error
+}
+
+// OfflineClient creates proposals and transactions for external signers, and sends the signed requests to a Fabric network.
+// It does not hold a client identity, and so it is not cached. The caller must call Close when its request completes.
+type OfflineClient struct {
+	name      string
+	channelID string
+	backend   OfflineBackend
+	network   *networkOrgs
+	// shared SDK held by the client until it is closed
+	sdk *fabsdk.FabricSDK
+}
+
+// NewOfflineClient returns a client for requests signed by external signers on a channel
+func NewOfflineClient(config ConnectorSpec) (*OfflineClient, error) {
+	client := &OfflineClient{
+		name:      config.Name,
+		channelID: config.ChannelID,
+	}
+	if len(config.NetworkConfig) > 0 {
+		network, err := parseNetworkOrgs(config.NetworkConfig)
+		if err != nil {
+			return nil, err
+		}
+		client.network = network
+	}
+	if backend, ok := registeredBackend(config.Name); ok {
+		offline, ok := backend.(OfflineBackend)
+		if !ok {
+			return nil, errors.Errorf("Backend of connection %s does not support offline signing", config.Name)
+		}
+		client.backend = offline
+		return client, nil
+	}
+	sdk, err := SharedSDK(config)
+	if err != nil {
+		return nil, err
+	}
+	client.sdk = sdk
+	client.backend = &sdkOfflineBackend{sdk: sdk}
+	return client, nil
+}
+
+// Close releases the shared SDK held by the client
+func (c *OfflineClient) Close() {
+	if c.sdk != nil {
+		ReleaseSDK(c.sdk)
+		c.sdk = nil
+	}
+}
+
+// transactionHeader implements fab.TransactionHeader for a creator certificate without private key
+type transactionHeader struct {
+	id        fab.TransactionID
+	creator   []byte
+	nonce     []byte
+	channelID string
+}
+
+func (h *transactionHeader) TransactionID() fab.TransactionID {
+	return h.id
+}
+
+func (h *transactionHeader) Creator() []byte {
+	return h.creator
+}
+
+func (h *transactionHeader) Nonce() []byte {
+	return h.nonce
+}
+
+func (h *transactionHeader) ChannelID() string {
+	return h.channelID
+}
+
+// CreateProposal returns an unsigned proposal of a chaincode transaction created by the PEM certificate of an MSP
+func (c *OfflineClient) CreateProposal(mspID, certificate, ccID, fcn string, args [][]byte, transient map[string][]byte) (*UnsignedRequest, error) {
+	if len(mspID) == 0 {
+		return nil, errors.New("MSP ID of creator is not specified")
+	}
+	if _, err := parseCertificate([]byte(certificate)); err != nil {
+		return nil, err
+	}
+	creator, err := proto.Marshal(&mspproto.SerializedIdentity{Mspid: mspID, IdBytes: []byte(certificate)})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to serialize creator")
+	}
+	nonce := make([]byte, 24)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, errors.Wrapf(err, "Failed to generate nonce")
+	}
+	// transaction ID is verified by peers as the hash of nonce and creator
+	txID := sha256.Sum256(append(append([]byte{}, nonce...), creator...))
+	header := &transactionHeader{
+		id:        fab.TransactionID(hex.EncodeToString(txID[:])),
+		creator:   creator,
+		nonce:     nonce,
+		channelID: c.channelID,
+	}
+	proposal, err := txn.CreateChaincodeInvokeProposal(header, fab.ChaincodeInvokeRequest{
+		ChaincodeID:  ccID,
+		Fcn:          fcn,
+		Args:         args,
+		TransientMap: transient,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to create proposal")
+	}
+	data, err := proto.Marshal(proposal.Proposal)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to serialize proposal")
+	}
+	return newUnsignedRequest(string(header.id), data), nil
+}
+
+// EndorseProposal sends a signed proposal to endorsing peers, and returns the unsigned transaction payload of the endorsements.
+// Endorsing peers are selected by endpoints, endorsement policy, or target orgs of the request options, or the org of the creator by default.
+func (c *OfflineClient) EndorseProposal(proposalBytes, signature []byte, options ...RequestOption) (*UnsignedRequest, Response, error) {
+	proposal := &pb.Proposal{}
+	if err := proto.Unmarshal(proposalBytes, proposal); err != nil {
+		return nil, Response{}, errors.Wrapf(err, "Failed to unmarshal proposal")
+	}
+	header, chdr, shdr, err := c.unmarshalHeader(proposal.Header)
+	if err != nil {
+		return nil, Response{}, err
+	}
+	response := Response{Response: channel.Response{
+		TransactionID: fab.TransactionID(chdr.TxId),
+		Proposal:      &fab.TransactionProposal{TxnID: fab.TransactionID(chdr.TxId), Proposal: proposal},
+	}}
+	if signature, err = verifySignature(shdr.Creator, proposalBytes, signature); err != nil {
+		return nil, response, err
+	}
+	opts := newRequestOptions(options)
+	targets, err := c.targets(creatorMSP(shdr.Creator), opts)
+	if err != nil {
+		return nil, response, err
+	}
+	logger.Debugf("send signed proposal of transaction %s to peers %v", chdr.TxId, targets)
+	signed := &pb.SignedProposal{ProposalBytes: proposalBytes, Signature: signature}
+	if response.Responses, err = c.backend.ProcessSignedProposal(c.channelID, signed, targets, opts.timeout()); err != nil {
+		return nil, response, err
+	}
+	if err := validateEndorsements(response.Responses); err != nil {
+		return nil, response, err
+	}
+	if resp := response.Responses[0].ProposalResponse.Response; resp != nil {
+		response.ChaincodeStatus = resp.Status
+		response.Payload = resp.Payload
+	}
+
+	tx, err := txn.New(fab.TransactionRequest{Proposal: response.Proposal, ProposalResponses: response.Responses})
+	if err != nil {
+		return nil, response, errors.Wrapf(err, "Failed to create transaction")
+	}
+	txBytes, err := proto.Marshal(tx.Transaction)
+	if err != nil {
+		return nil, response, errors.Wrapf(err, "Failed to serialize transaction")
+	}
+	payload, err := proto.Marshal(&cb.Payload{Header: header, Data: txBytes})
+	if err != nil {
+		return nil, response, errors.Wrapf(err, "Failed to serialize transaction payload")
+	}
+	return newUnsignedRequest(chdr.TxId, payload), response, nil
+}
+
+// BroadcastTransaction sends a signed transaction payload to orderer, and returns the transaction ID without waiting for the transaction to commit
+func (c *OfflineClient) BroadcastTransaction(payloadBytes, signature []byte, options ...RequestOption) (string, error) {
+	payload := &cb.Payload{}
+	if err := proto.Unmarshal(payloadBytes, payload); err != nil {
+		return "", errors.Wrapf(err, "Failed to unmarshal transaction payload")
+	}
+	if payload.Header == nil {
+		return "", errors.New("Transaction payload does not contain header")
+	}
+	header, err := proto.Marshal(payload.Header)
+	if err != nil {
+		return "", errors.Wrapf(err, "Failed to serialize transaction header")
+	}
+	_, chdr, shdr, err := c.unmarshalHeader(header)
+	if err != nil {
+		return "", err
+	}
+	if signature, err = verifySignature(shdr.Creator, payloadBytes, signature); err != nil {
+		return chdr.TxId, err
+	}
+	logger.Debugf("broadcast signed transaction %s", chdr.TxId)
+	envelope := &fab.SignedEnvelope{Payload: payloadBytes, Signature: signature}
+	return chdr.TxId, c.backend.BroadcastSignedTransaction(c.channelID, envelope, newRequestOptions(options).timeout())
+}
+
+// unmarshalHeader returns channel header and signature header of a serialized header, and verifies the channel ID
+func (c *OfflineClient) unmarshalHeader(data []byte) (*cb.Header, *cb.ChannelHeader, *cb.SignatureHeader, error) {
+	header := &cb.Header{}
+	if err := proto.Unmarshal(data, header); err != nil {
+		return nil, nil, nil, errors.Wrapf(err, "Failed to unmarshal header")
+	}
+	chdr := &cb.ChannelHeader{}
+	if err := proto.Unmarshal(header.ChannelHeader, chdr); err != nil {
+		return nil, nil, nil, errors.Wrapf(err, "Failed to unmarshal channel header")
+	}
+	if chdr.ChannelId != c.channelID {
+		return nil, nil, nil, errors.Errorf("Request of channel %s is not for channel %s", chdr.ChannelId, c.channelID)
+	}
+	shdr := &cb.SignatureHeader{}
+	if err := proto.Unmarshal(header.SignatureHeader, shdr); err != nil {
+		return nil, nil, nil, errors.Wrapf(err, "Failed to unmarshal signature header")
+	}
+	return header, chdr, shdr, nil
+}
+
+// targets returns endorsing peers of a signed proposal
+func (c *OfflineClient) targets(mspID string, opts *requestOptions) ([]string, error) {
+	if len(opts.endpoints) > 0 {
+		return opts.endpoints, nil
+	}
+	if c.network == nil {
+		return nil, errors.New("Network config is required to select endorsing peers")
+	}
+	if len(opts.endorsementPolicy) > 0 {
+		return c.network.policyPeers(opts.endorsementPolicy, c.channelID, mspID)
+	}
+	orgs := opts.targetOrgs
+	if len(orgs) == 0 {
+		orgs = []string{mspID}
+	}
+	var peers []string
+	for _, org := range orgs {
+		available := c.network.endorsingPeers(c.network.mspID(org), c.channelID)
+		if len(available) == 0 {
+			return nil, errors.Errorf("Network config does not contain endorsing peers of %s on channel %s", org, c.channelID)
+		}
+		peers = append(peers, available[0])
+	}
+	return peers, nil
+}
+
+// timeout returns the request timeout, or 0 to use the default timeout of the SDK
+func (o *requestOptions) timeout() time.Duration {
+	return time.Duration(o.timeoutMillis) * time.Millisecond
+}
+
+// validateEndorsements returns error if an endorser rejected the proposal, or the endorsements do not match
+func validateEndorsements(responses []*fab.TransactionProposalResponse) error {
+	if len(responses) == 0 {
+		return status.New(status.EndorserClientStatus, int32(status.NoPeersFound), "No endorsement is returned", nil)
+	}
+	for _, r := range responses {
+		if r.ProposalResponse == nil || r.ProposalResponse.Response == nil {
+			return errors.Errorf("Endorser %s returned empty response", r.Endorser)
+		}
+		if resp := r.ProposalResponse.Response; resp.Status < int32(cb.Status_SUCCESS) || resp.Status >= int32(cb.Status_BAD_REQUEST) {
+			return status.New(status.ChaincodeStatus, resp.Status, resp.Message, nil)
+		}
+		if !bytes.Equal(r.ProposalResponse.Payload, responses[0].ProposalResponse.Payload) {
+			return status.New(status.EndorserClientStatus, int32(status.EndorsementMismatch), "ProposalResponsePayloads do not match", nil)
+		}
+	}
+	return nil
+}
+
+// creatorMSP returns MSP ID of a serialized creator
+func creatorMSP(creator []byte) string {
+	id := &mspproto.SerializedIdentity{}
+	if err := proto.Unmarshal(creator, id); err != nil {
+		return ""
+	}
+	return id.Mspid
+}
+
+func parseCertificate(certPEM []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return nil, errors.New("Certificate is not PEM encoded")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to parse certificate")
+	}
+	return cert, nil
+}
+
+// ecdsaSignature is the ASN.1 structure of an ECDSA signature
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+// verifySignature verifies an ECDSA signature of a message by the certificate of the creator.
+// The signature can be ASN.1 DER encoded, or the concatenated R and S returned by WebCrypto.
+// It returns the DER encoded signature with low S value as required by Fabric.
+func verifySignature(creator, message, signature []byte) ([]byte, error) {
+	id := &mspproto.SerializedIdentity{}
+	if err := proto.Unmarshal(creator, id); err != nil {
+		return nil, errors.Wrapf(err, "Failed to unmarshal creator")
+	}
+	cert, err := parseCertificate(id.IdBytes)
+	if err != nil {
+		return nil, err
+	}
+	pub, ok := cert.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, errors.New("Public key of creator is not an ECDSA key")
+	}
+	sig := &ecdsaSignature{}
+	size := (pub.Curve.Params().BitSize + 7) / 8
+	if rest, err := asn1.Unmarshal(signature, sig); err != nil || len(rest) > 0 {
+		if len(signature) != 2*size {
+			return nil, errors.New("Signature is not a valid ECDSA signature")
+		}
+		sig.R = new(big.Int).SetBytes(signature[:size])
+		sig.S = new(big.Int).SetBytes(signature[size:])
+	}
+	digest := sha256.Sum256(message)
+	if !ecdsa.Verify(pub, digest[:], sig.R, sig.S) {
+		return nil, status.New(status.ClientStatus, int32(status.SignatureVerificationFailed), "Signature does not match the creator certificate", nil)
+	}
+	n := pub.Curve.Params().N
+	if sig.S.Cmp(new(big.Int).Rsh(n, 1)) > 0 {
+		sig.S = new(big.Int).Sub(n, sig.S)
+	}
+	return asn1.Marshal(*sig)
+}
+
+// sdkOfflineBackend sends signed requests to peers and orderers of the network config by using fabric-sdk-go
+type sdkOfflineBackend struct {
+	sdk *fabsdk.FabricSDK
+}
+
+// ProcessSignedProposal implements OfflineBackend.ProcessSignedProposal
+func (b *sdkOfflineBackend) ProcessSignedProposal(channelID string, proposal *pb.SignedProposal, targets []string, timeout time.Duration) ([]*fab.TransactionProposalResponse, error) {
+	// SDK context without identity, because the proposal is already signed
+	ctx, err := b.sdk.Context()()
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to create SDK context")
+	}
+	var peers []fab.Peer
+	for _, t := range targets {
+		peer, err := createPeer(ctx, t)
+		if err != nil {
+			return nil, err
+		}
+		peers = append(peers, peer)
+	}
+	reqCtx, cancel := contextImpl.NewRequest(ctx, requestTimeout(fab.PeerResponse, timeout)...)
+	defer cancel()
+
+	responses := make([]*fab.TransactionProposalResponse, len(peers))
+	errs := make([]error, len(peers))
+	var wg sync.WaitGroup
+	for i, p := range peers {
+		wg.Add(1)
+		go func(i int, p fab.Peer) {
+			defer wg.Done()
+			responses[i], errs[i] = p.ProcessTransactionProposal(reqCtx, fab.ProcessProposalRequest{SignedProposal: proposal})
+		}(i, p)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return responses, nil
+}
+
+// BroadcastSignedTransaction implements OfflineBackend.BroadcastSignedTransaction.
+// Orderers of the channel in the network config are tried in order until one accepts the transaction.
+func (b *sdkOfflineBackend) BroadcastSignedTransaction(channelID string, envelope *fab.SignedEnvelope, timeout time.Duration) error {
+	ctx, err := b.sdk.Context()()
+	if err != nil {
+		return errors.Wrapf(err, "Failed to create SDK context")
+	}
+	orderers := ctx.EndpointConfig().ChannelOrderers(channelID)
+	if len(orderers) == 0 {
+		orderers = ctx.EndpointConfig().OrderersConfig()
+	}
+	if len(orderers) == 0 {
+		return errors.Errorf("Network config does not contain orderers of channel %s", channelID)
+	}
+	reqCtx, cancel := contextImpl.NewRequest(ctx, requestTimeout(fab.OrdererResponse, timeout)...)
+	defer cancel()
+
+	var lastErr error
+	for i := range orderers {
+		orderer, err := ctx.InfraProvider().CreateOrdererFromConfig(&orderers[i])
+		if err != nil {
+			lastErr = errors.Wrapf(err, "Failed to create orderer %s", orderers[i].URL)
+			continue
+		}
+		if _, err := orderer.SendBroadcast(reqCtx, envelope); err != nil {
+			logger.Warnf("orderer %s rejected transaction: %+v", orderers[i].URL, err)
+			lastErr = err
+			continue
+		}
+		return nil
+	}
+	return lastErr
+}
+
+// createPeer returns a peer of a name or URL in the network config
+func createPeer(ctx context.Client, target string) (fab.Peer, error) {
+	cfg, ok := ctx.EndpointConfig().PeerConfig(target)
+	if !ok {
+		return nil, errors.Errorf("Peer %s is not found in network config", target)
+	}
+	networkPeer := &fab.NetworkPeer{PeerConfig: *cfg}
+	for _, p := range ctx.EndpointConfig().NetworkPeers() {
+		if p.URL == cfg.URL {
+			networkPeer.MSPID = p.MSPID
+			break
+		}
+	}
+	peer, err := ctx.InfraProvider().CreatePeerFromConfig(networkPeer)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to create peer %s", target)
+	}
+	return peer, nil
+}
+
+// requestTimeout returns options of request context for a timeout type, or a specified timeout if it is not 0
+func requestTimeout(timeoutType fab.TimeoutType, timeout time.Duration) []contextImpl.ReqContextOptions {
+	opts := []contextImpl.ReqContextOptions{contextImpl.WithTimeoutType(timeoutType)}
+	if timeout > 0 {
+		opts = append(opts, contextImpl.WithTimeout(timeout))
+	}
+	return opts
+}
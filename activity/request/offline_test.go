@@ -0,0 +1,114 @@
+/*
+SPDX-License-Identifier: BSD-3-Clause-Open-MPI
+*/
+
+package request
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// signDigest returns base64 encoded ECDSA signature of a base64 encoded digest, in DER or raw format
+func signDigest(t *testing.T, keyPEM string, digest interface{}, raw bool) string {
+	block, _ := pem.Decode([]byte(keyPEM))
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	require.NoError(t, err, "parse private key should not throw error")
+	hash, err := base64.StdEncoding.DecodeString(digest.(string))
+	require.NoError(t, err, "digest should be base64 encoded")
+	r, s, err := ecdsa.Sign(rand.Reader, key.(*ecdsa.PrivateKey), hash)
+	require.NoError(t, err, "sign digest should not throw error")
+	if raw {
+		sig := make([]byte, 64)
+		rb, sb := r.Bytes(), s.Bytes()
+		copy(sig[32-len(rb):32], rb)
+		copy(sig[64-len(sb):], sb)
+		return base64.StdEncoding.EncodeToString(sig)
+	}
+	sig, err := asn1.Marshal(ecdsaSignature{R: r, S: s})
+	require.NoError(t, err, "marshal signature should not throw error")
+	return base64.StdEncoding.EncodeToString(sig)
+}
+
+func offlineSettings(requestType string) map[string]interface{} {
+	return map[string]interface{}{
+		"connectionName":  "mock-offline",
+		"channelID":       "mychannel",
+		"chaincodeID":     "basic",
+		"transactionName": "TransferAsset",
+		"parameters":      "id,newOwner",
+		"requestType":     requestType,
+	}
+}
+
+func TestOfflineSigning(t *testing.T) {
+	mock := NewMockBackend()
+	mock.On(opEndorse, "basic", "TransferAsset", "asset1", "Tomoko").Return([]byte(`{"oldOwner":"Brad"}`), 200)
+	RegisterBackend("mock-offline", mock)
+	defer UnregisterBackend("mock-offline")
+	cert, key := newTestKeyPair(t, "alice")
+
+	// phase 1: create unsigned proposal for creator certificate
+	req, _ := json.Marshal(map[string]interface{}{
+		"mspID":       "Org1MSP",
+		"certificate": cert,
+		"parameters":  map[string]interface{}{"id": "asset1", "newOwner": "Tomoko"},
+	})
+	output, done, err := evalActivity(t, offlineSettings(opPropose), string(req))
+	require.True(t, done, "propose should be successful")
+	require.NoError(t, err, "propose should not throw error")
+	proposal := output.Result.(map[string]interface{})
+	assert.Equal(t, proposal["transactionID"], output.TransactionID, "output should contain transaction ID")
+
+	// invalid signature is rejected
+	_, otherKey := newTestKeyPair(t, "bob")
+	req, _ = json.Marshal(map[string]interface{}{
+		"proposal":  proposal["bytes"],
+		"signature": signDigest(t, otherKey, proposal["digest"], false),
+		"endpoints": []interface{}{"peer0.org1.example.com"},
+	})
+	output, done, _ = evalActivity(t, offlineSettings(opEndorse), string(req))
+	assert.False(t, done, "proposal signed by another key should fail")
+	assert.Equal(t, ErrAccessDenied, output.ErrorType, "error type should be ACCESS_DENIED")
+
+	// phase 2: endorse signed proposal
+	req, _ = json.Marshal(map[string]interface{}{
+		"proposal":  proposal["bytes"],
+		"signature": signDigest(t, key, proposal["digest"], true),
+		"endpoints": []interface{}{"peer0.org1.example.com"},
+	})
+	output, done, err = evalActivity(t, offlineSettings(opEndorse), string(req))
+	require.True(t, done, "endorse should be successful")
+	require.NoError(t, err, "endorse should not throw error")
+	assert.Equal(t, 200, output.Code, "output status code should be 200")
+	assert.Equal(t, proposal["transactionID"], output.TransactionID, "endorsed transaction should have the ID of the proposal")
+	assert.Len(t, output.Endorsers, 1, "output should contain one endorser")
+	transaction := output.Result.(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"oldOwner": "Brad"}, transaction["response"], "result should contain chaincode response")
+
+	// phase 3: broadcast signed transaction
+	req, _ = json.Marshal(map[string]interface{}{
+		"transaction": transaction["bytes"],
+		"signature":   signDigest(t, key, transaction["digest"], false),
+	})
+	output, done, err = evalActivity(t, offlineSettings(opBroadcast), string(req))
+	require.True(t, done, "broadcast should be successful")
+	require.NoError(t, err, "broadcast should not throw error")
+	assert.Equal(t, proposal["transactionID"], output.TransactionID, "broadcast should return the transaction ID")
+	status, err := mock.TransactionStatus(output.TransactionID)
+	assert.NoError(t, err, "transaction status should not throw error")
+	assert.True(t, status.Committed, "broadcast transaction should be committed")
+	assert.NoError(t, mock.ExpectationsMet(), "all expected calls should be requested")
+
+	_, done, _ = evalActivity(t, offlineSettings(opBroadcast), `{"transaction": "abc"}`)
+	assert.False(t, done, "broadcast without signature should fail")
+}
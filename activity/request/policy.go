@@ -0,0 +1,305 @@
+/*
+SPDX-License-Identifier: BSD-3-Clause-Open-MPI
+*/
+
+package request
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	cb "github.com/hyperledger/fabric-protos-go/common"
+	mspproto "github.com/hyperledger/fabric-protos-go/msp"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	"github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/common/policydsl"
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// MSPFilter implements TargetFilter interface for peers of a set of MSPs
+type MSPFilter struct {
+	MSPIDs map[string]bool
+}
+
+// Accept implements fab.TargetFilter interface
+func (f *MSPFilter) Accept(peer fab.Peer) bool {
+	return f.MSPIDs[peer.MSPID()]
+}
+
+// networkOrgs contains organizations and channel peers defined in a network config
+type networkOrgs struct {
+	defaultOrg string
+	// org name => MSP ID
+	mspIDs map[string]string
+	// MSP ID => peers of the org in the order of the network config
+	peers map[string][]string
+	// channel ID => peer => true if the peer is an endorsing peer of the channel
+	channelPeers map[string]map[string]bool
+}
+
+// parseNetworkOrgs returns organizations and peers of a network config
+func parseNetworkOrgs(networkConfig []byte) (*networkOrgs, error) {
+	var data map[interface{}]interface{}
+	if err := yaml.Unmarshal(networkConfig, &data); err != nil {
+		return nil, errors.Wrapf(err, "Failed to parse network config")
+	}
+	result := &networkOrgs{
+		mspIDs:       make(map[string]string),
+		peers:        make(map[string][]string),
+		channelPeers: make(map[string]map[string]bool),
+	}
+	if org, ok := execYamlPath(data, "client.organization").(string); ok {
+		result.defaultOrg = org
+	}
+	orgs, _ := data["organizations"].(map[interface{}]interface{})
+	for k, v := range orgs {
+		org, _ := v.(map[interface{}]interface{})
+		mspid, _ := org["mspid"].(string)
+		if len(mspid) == 0 {
+			continue
+		}
+		result.mspIDs[k.(string)] = mspid
+		peers, _ := org["peers"].([]interface{})
+		for _, p := range peers {
+			if name, ok := p.(string); ok {
+				result.peers[mspid] = append(result.peers[mspid], name)
+			}
+		}
+	}
+	channels, _ := data["channels"].(map[interface{}]interface{})
+	for k, v := range channels {
+		peers, _ := execYamlPath(v, "peers").(map[interface{}]interface{})
+		endorsing := make(map[string]bool)
+		for p, opts := range peers {
+			// peers are endorsing peers by default
+			isEndorser := true
+			if e, ok := execYamlPath(opts, "endorsingPeer").(bool); ok {
+				isEndorser = e
+			}
+			endorsing[p.(string)] = isEndorser
+		}
+		result.channelPeers[k.(string)] = endorsing
+	}
+	return result, nil
+}
+
+// mspID returns MSP ID of an org name, or the MSP ID itself if it is not an org name
+func (n *networkOrgs) mspID(org string) string {
+	if mspid, ok := n.mspIDs[org]; ok {
+		return mspid
+	}
+	return org
+}
+
+// orgName returns the org name of an MSP ID
+func (n *networkOrgs) orgName(mspid string) (string, bool) {
+	for org, id := range n.mspIDs {
+		if id == mspid {
+			return org, true
+		}
+	}
+	return "", false
+}
+
+// mspFilter returns a target filter for peers of a list of MSP IDs or org names
+func (n *networkOrgs) mspFilter(orgs []string) *MSPFilter {
+	filter := &MSPFilter{MSPIDs: make(map[string]bool)}
+	for _, org := range orgs {
+		filter.MSPIDs[n.mspID(org)] = true
+	}
+	return filter
+}
+
+// endorsingPeers returns endorsing peers of an MSP on a channel.
+// If the channel is not defined in the network config, all peers of the MSP are returned.
+func (n *networkOrgs) endorsingPeers(mspid, channelID string) []string {
+	channelPeers, ok := n.channelPeers[channelID]
+	if !ok {
+		return n.peers[mspid]
+	}
+	var result []string
+	for _, p := range n.peers[mspid] {
+		if channelPeers[p] {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// policyPeers returns the smallest set of peers on a channel that satisfies an endorsement policy,
+// e.g., AND('Org1MSP.peer','Org2MSP.peer'). When multiple sets of the same size satisfy the policy,
+// the set that includes the preferred MSP, i.e., MSP of the client, is used.
+func (n *networkOrgs) policyPeers(policy, channelID, preferredMSP string) ([]string, error) {
+	candidates, err := policyMSPs(policy)
+	if err != nil {
+		return nil, err
+	}
+	var lastErr error
+	for _, msps := range sortMSPSets(candidates, preferredMSP) {
+		var peers []string
+		for _, mspid := range sortedKeys(msps) {
+			available := n.endorsingPeers(mspid, channelID)
+			if len(available) < msps[mspid] {
+				lastErr = errors.Errorf("Network config does not contain %d endorsing peers of %s on channel %s", msps[mspid], mspid, channelID)
+				peers = nil
+				break
+			}
+			peers = append(peers, available[:msps[mspid]]...)
+		}
+		if peers != nil {
+			return peers, nil
+		}
+	}
+	if lastErr == nil {
+		lastErr = errors.Errorf("Endorsement policy %s cannot be satisfied", policy)
+	}
+	return nil, lastErr
+}
+
+// policyMSPs parses an endorsement policy expression, and returns the minimal sets of MSP IDs that satisfy the policy.
+// Each set maps MSP ID to the number of distinct signers required from the MSP.
+func policyMSPs(policy string) ([]map[string]int, error) {
+	envelope, err := policydsl.FromString(policy)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to parse endorsement policy %s", policy)
+	}
+	var identities []string
+	for _, p := range envelope.Identities {
+		if p.PrincipalClassification != mspproto.MSPPrincipal_ROLE {
+			return nil, errors.Errorf("Unsupported principal classification %s in endorsement policy %s", p.PrincipalClassification, policy)
+		}
+		role := &mspproto.MSPRole{}
+		if err := proto.Unmarshal(p.Principal, role); err != nil {
+			return nil, errors.Wrapf(err, "Failed to unmarshal principal of endorsement policy %s", policy)
+		}
+		identities = append(identities, role.MspIdentifier)
+	}
+	return satisfyingMSPs(envelope.Rule, identities)
+}
+
+// satisfyingMSPs returns minimal sets of MSP IDs that satisfy a signature policy rule
+func satisfyingMSPs(rule *cb.SignaturePolicy, identities []string) ([]map[string]int, error) {
+	switch t := rule.Type.(type) {
+	case *cb.SignaturePolicy_SignedBy:
+		if int(t.SignedBy) >= len(identities) {
+			return nil, errors.Errorf("Invalid identity index %d in endorsement policy", t.SignedBy)
+		}
+		return []map[string]int{{identities[t.SignedBy]: 1}}, nil
+	case *cb.SignaturePolicy_NOutOf_:
+		var children [][]map[string]int
+		for _, r := range t.NOutOf.Rules {
+			sets, err := satisfyingMSPs(r, identities)
+			if err != nil {
+				return nil, err
+			}
+			children = append(children, sets)
+		}
+		return nOutOf(int(t.NOutOf.N), children), nil
+	default:
+		return nil, errors.Errorf("Unsupported signature policy type %T", rule.Type)
+	}
+}
+
+// nOutOf combines the satisfying sets of every N children, and returns the sets that are not a superset of another set
+func nOutOf(n int, children [][]map[string]int) []map[string]int {
+	if n <= 0 {
+		return []map[string]int{{}}
+	}
+	var result []map[string]int
+	var combine func(start int, remaining int, acc map[string]int)
+	combine = func(start int, remaining int, acc map[string]int) {
+		if remaining == 0 {
+			result = append(result, acc)
+			return
+		}
+		for i := start; i <= len(children)-remaining; i++ {
+			for _, set := range children[i] {
+				combine(i+1, remaining-1, mergeMSPs(acc, set))
+			}
+		}
+	}
+	combine(0, n, map[string]int{})
+	return minimalMSPSets(result)
+}
+
+func mergeMSPs(a, b map[string]int) map[string]int {
+	result := make(map[string]int)
+	for k, v := range a {
+		result[k] = v
+	}
+	for k, v := range b {
+		result[k] += v
+	}
+	return result
+}
+
+// minimalMSPSets removes duplicate sets and sets that contain another set
+func minimalMSPSets(sets []map[string]int) []map[string]int {
+	var result []map[string]int
+	for i, s := range sets {
+		minimal := true
+		for j, o := range sets {
+			if i == j {
+				continue
+			}
+			if containsMSPs(s, o) && (!containsMSPs(o, s) || j < i) {
+				minimal = false
+				break
+			}
+		}
+		if minimal {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// containsMSPs returns true if set a requires at least the signers of set b
+func containsMSPs(a, b map[string]int) bool {
+	for k, v := range b {
+		if a[k] < v {
+			return false
+		}
+	}
+	return true
+}
+
+// sortMSPSets orders sets by the number of required peers, and then prefers sets that include the preferred MSP
+func sortMSPSets(sets []map[string]int, preferredMSP string) []map[string]int {
+	size := func(s map[string]int) int {
+		total := 0
+		for _, v := range s {
+			total += v
+		}
+		return total
+	}
+	sort.SliceStable(sets, func(i, j int) bool {
+		si, sj := size(sets[i]), size(sets[j])
+		if si != sj {
+			return si < sj
+		}
+		return sets[i][preferredMSP] > 0 && sets[j][preferredMSP] == 0
+	})
+	return sets
+}
+
+func sortedKeys(m map[string]int) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// splitOrgs returns a list of org names or MSP IDs from a comma-delimited string
+func splitOrgs(orgs string) []string {
+	var result []string
+	for _, o := range strings.Split(orgs, ",") {
+		if o = strings.TrimSpace(o); len(o) > 0 {
+			result = append(result, o)
+		}
+	}
+	return result
+}
@@ -0,0 +1,118 @@
+/*
+SPDX-License-Identifier: BSD-3-Clause-Open-MPI
+*/
+
+package request
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// network config of 3 orgs, where org3 has 2 peers, but peer1.org3 is not an endorsing peer of mychannel
+var policyTestConfig = []byte(`
+client:
+  organization: org2
+channels:
+  mychannel:
+    peers:
+      peer0.org1.example.com:
+        endorsingPeer: true
+      peer0.org2.example.com: {}
+      peer0.org3.example.com:
+        endorsingPeer: true
+      peer1.org3.example.com:
+        endorsingPeer: false
+organizations:
+  org1:
+    mspid: Org1MSP
+    peers:
+      - peer0.org1.example.com
+  org2:
+    mspid: Org2MSP
+    peers:
+      - peer0.org2.example.com
+  org3:
+    mspid: Org3MSP
+    peers:
+      - peer0.org3.example.com
+      - peer1.org3.example.com
+`)
+
+func TestPolicyMSPs(t *testing.T) {
+	sets, err := policyMSPs("AND('Org1MSP.peer','Org2MSP.peer')")
+	require.NoError(t, err, "parse AND policy should not throw error")
+	assert.Equal(t, []map[string]int{{"Org1MSP": 1, "Org2MSP": 1}}, sets, "AND policy should require both orgs")
+
+	sets, err = policyMSPs("OR('Org1MSP.member','Org2MSP.member')")
+	require.NoError(t, err, "parse OR policy should not throw error")
+	assert.Equal(t, []map[string]int{{"Org1MSP": 1}, {"Org2MSP": 1}}, sets, "OR policy should be satisfied by either org")
+
+	sets, err = policyMSPs("OutOf(2, 'Org1MSP.peer', 'Org2MSP.peer', 'Org3MSP.peer')")
+	require.NoError(t, err, "parse OutOf policy should not throw error")
+	assert.Equal(t, 3, len(sets), "2 out of 3 orgs should have 3 combinations")
+
+	sets, err = policyMSPs("AND('Org1MSP.peer', OR('Org1MSP.peer', 'Org2MSP.peer'))")
+	require.NoError(t, err, "parse nested policy should not throw error")
+	assert.Equal(t, []map[string]int{{"Org1MSP": 2}, {"Org1MSP": 1, "Org2MSP": 1}}, sets, "nested policy should require 2 signers")
+
+	_, err = policyMSPs("AND('Org1MSP.peer'")
+	assert.Error(t, err, "invalid policy should throw error")
+}
+
+func TestPolicyPeers(t *testing.T) {
+	network, err := parseNetworkOrgs(policyTestConfig)
+	require.NoError(t, err, "parse network config should not throw error")
+	assert.Equal(t, "Org3MSP", network.mspID("org3"), "MSP ID of org3 should be Org3MSP")
+	assert.Equal(t, "Org3MSP", network.mspID("Org3MSP"), "MSP ID should be accepted as target org")
+
+	peers, err := network.policyPeers("AND('Org1MSP.peer','Org3MSP.peer')", "mychannel", "Org2MSP")
+	require.NoError(t, err, "AND policy should be satisfied")
+	assert.Equal(t, []string{"peer0.org1.example.com", "peer0.org3.example.com"}, peers, "AND policy should select 1 peer of each org")
+
+	peers, err = network.policyPeers("OutOf(2, 'Org1MSP.peer', 'Org2MSP.peer', 'Org3MSP.peer')", "mychannel", "Org2MSP")
+	require.NoError(t, err, "OutOf policy should be satisfied")
+	assert.Equal(t, 2, len(peers), "OutOf policy should select 2 peers")
+	assert.Contains(t, peers, "peer0.org2.example.com", "OutOf policy should prefer peer of client org")
+
+	peers, err = network.policyPeers("OR(AND('Org3MSP.peer','Org3MSP.peer'), AND('Org1MSP.peer','Org2MSP.peer'))", "mychannel", "Org3MSP")
+	require.NoError(t, err, "policy should be satisfied by org1 and org2")
+	assert.Equal(t, []string{"peer0.org1.example.com", "peer0.org2.example.com"}, peers, "non-endorsing peer of org3 should not be selected")
+
+	_, err = network.policyPeers("AND('Org1MSP.peer','Org4MSP.peer')", "mychannel", "Org2MSP")
+	assert.Error(t, err, "policy of unknown org should not be satisfied")
+}
+
+func TestTargetOrgs(t *testing.T) {
+	fbc := &FabricClient{channelID: "mychannel"}
+	require.NoError(t, fbc.setNetwork(ConnectorSpec{NetworkConfig: policyTestConfig, ChannelID: "mychannel"}), "parse network config should not throw error")
+	assert.Equal(t, "Org2MSP", fbc.mspID, "MSP ID of client org should be Org2MSP")
+
+	_, targetFilter, err := fbc.targets(newRequestOptions([]RequestOption{WithTargetOrgs("org1", "Org3MSP")}))
+	require.NoError(t, err, "set target orgs should not throw error")
+	filter, ok := targetFilter.(*MSPFilter)
+	require.True(t, ok, "target orgs should set MSP filter")
+	assert.Equal(t, map[string]bool{"Org1MSP": true, "Org3MSP": true}, filter.MSPIDs, "org names should be converted to MSP IDs")
+
+	peers, targetFilter, err := fbc.targets(newRequestOptions([]RequestOption{
+		WithTargetOrgs("org1"),
+		WithEndorsementPolicy("AND('Org1MSP.peer','Org2MSP.peer')"),
+	}))
+	require.NoError(t, err, "set endorsement policy should not throw error")
+	assert.Nil(t, targetFilter, "endorsement policy should take precedence over target orgs")
+	assert.Equal(t, []string{"peer0.org1.example.com", "peer0.org2.example.com"}, peers, "policy should select peers of org1 and org2")
+
+	peers, _, err = fbc.targets(newRequestOptions([]RequestOption{
+		WithEndpoints("peer0.org1.example.com"),
+		WithEndorsementPolicy("AND('Org1MSP.peer','Org2MSP.peer')"),
+	}))
+	require.NoError(t, err, "set endpoints should not throw error")
+	assert.Equal(t, []string{"peer0.org1.example.com"}, peers, "endpoints should take precedence over endorsement policy")
+
+	peers, targetFilter, err = fbc.targets(newRequestOptions(nil))
+	require.NoError(t, err, "request without target options should not throw error")
+	assert.Nil(t, peers, "request without target options should not select peers")
+	assert.Nil(t, targetFilter, "request without userOrgOnly should not filter peers")
+}
@@ -0,0 +1,442 @@
+/*
+SPDX-License-Identifier: BSD-3-Clause-Open-MPI
+*/
+
+package request
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/fabsdk"
+	"github.com/project-flogo/core/engine"
+)
+
+const (
+	// DefaultMaxClients is the default max number of cached Fabric clients
+	DefaultMaxClients = 100
+	// DefaultClientIdleTimeout is the default period after which an unused Fabric client is closed
+	DefaultClientIdleTimeout = 30 * time.Minute
+)
+
+// cached Fabric client connections, which are closed when the Flogo engine stops
+var clientRegistry = NewClientRegistry(DefaultMaxClients, DefaultClientIdleTimeout)
+
+func init() {
+	// managed services are stopped in the order of registration, so SDKs are closed after the clients using them
+	engine.LifeCycle(clientRegistry)
+	engine.LifeCycle(sdkService{})
+}
+
+// sdkService closes SDKs shared by Fabric clients when the Flogo engine stops
+type sdkService struct{}
+
+// Start implements managed.Managed.Start
+func (s sdkService) Start() error {
+	return nil
+}
+
+// Stop implements managed.Managed.Stop
+func (s sdkService) Stop() error {
+	closeSharedSDKs()
+	return nil
+}
+
+// CachedClient is a client that can be cached by ClientRegistry, i.e., FabricClient or ServiceClient
+type CachedClient interface {
+	// ConnectionName returns the name of the connection that the client is created for
+	ConnectionName() string
+	// Release releases the client held for a caller of ClientRegistry.GetOrCreate
+	Release()
+	// Close closes the client after callers holding the client release it
+	Close()
+	retain()
+}
+
+// ServiceClient is a client of a fabric-sdk-go service, e.g., ledger or msp client, that can be cached by ClientRegistry.
+// It holds the shared SDK that it is created from, and releases the SDK when it is closed.
+type ServiceClient struct {
+	name    string
+	sdk     *fabsdk.FabricSDK
+	service interface{}
+	clientRefs
+}
+
+// NewServiceClient returns a client of a service created from an SDK returned by SharedSDK.
+// The client takes over the hold of the SDK, and releases it when the client is closed.
+func NewServiceClient(connectionName string, sdk *fabsdk.FabricSDK, service interface{}) *ServiceClient {
+	return &ServiceClient{name: connectionName, sdk: sdk, service: service}
+}
+
+// Service returns the fabric-sdk-go service client
+func (c *ServiceClient) Service() interface{} {
+	return c.service
+}
+
+// ConnectionName implements CachedClient.ConnectionName
+func (c *ServiceClient) ConnectionName() string {
+	return c.name
+}
+
+// Release implements CachedClient.Release
+func (c *ServiceClient) Release() {
+	c.release()
+}
+
+// Close implements CachedClient.Close. The SDK is released after callers holding the client release it.
+func (c *ServiceClient) Close() {
+	if c.closeWait() && c.sdk != nil {
+		ReleaseSDK(c.sdk)
+	}
+}
+
+// clientRefs counts in-flight requests and callers holding a client, so closing the client waits for them to release it
+type clientRefs struct {
+	refLock  sync.Mutex
+	released *sync.Cond
+	refs     int
+	closed   bool
+}
+
+// retain holds the client, so Close waits until it is released.
+// The caller must already hold the client, or hold the lock of the registry that caches the client.
+func (r *clientRefs) retain() {
+	r.refLock.Lock()
+	defer r.refLock.Unlock()
+	r.refs++
+}
+
+// release releases a reference of the client, and wakes up Close if it is the last reference
+func (r *clientRefs) release() {
+	r.refLock.Lock()
+	defer r.refLock.Unlock()
+	r.refs--
+	if r.refs <= 0 && r.released != nil {
+		r.released.Broadcast()
+	}
+}
+
+// acquire holds the client for a request, and returns false if the client is closed
+func (r *clientRefs) acquire() bool {
+	r.refLock.Lock()
+	defer r.refLock.Unlock()
+	if r.closed {
+		return false
+	}
+	r.refs++
+	return true
+}
+
+// closeWait waits until all references are released, and marks the client as closed.
+// It returns false if the client is already closed.
+func (r *clientRefs) closeWait() bool {
+	r.refLock.Lock()
+	defer r.refLock.Unlock()
+	if r.released == nil {
+		r.released = sync.NewCond(&r.refLock)
+	}
+	for r.refs > 0 {
+		r.released.Wait()
+	}
+	if r.closed {
+		return false
+	}
+	r.closed = true
+	return true
+}
+
+// ClientRegistry is a concurrency-safe cache of Fabric clients.
+// When the number of clients exceeds the size limit, the least recently used client is evicted.
+// Clients that are not used for longer than the idle timeout are also evicted.
+// Evicted clients are closed after their in-flight requests complete, and callers of GetOrCreate release them.
+// It implements managed.Managed, so all clients are closed when the Flogo engine stops.
+type ClientRegistry struct {
+	sync.Mutex
+	maxSize     int
+	idleTimeout time.Duration
+	// key => element of lru list, with the most recently used client at front
+	entries map[string]*list.Element
+	lru     *list.List
+	// key => client being created, which concurrent callers of the same key wait for
+	pending map[string]*pendingClient
+	done    chan struct{}
+}
+
+// pendingClient is a client being created outside the registry lock
+type pendingClient struct {
+	done chan struct{}
+	err  error
+	// stale is set if the connection is reset while the client is created, so the new client is not cached
+	stale bool
+}
+
+type registryEntry struct {
+	key      string
+	client   CachedClient
+	lastUsed time.Time
+}
+
+// NewClientRegistry returns a client registry of a size limit and an idle timeout
+func NewClientRegistry(maxSize int, idleTimeout time.Duration) *ClientRegistry {
+	return &ClientRegistry{
+		maxSize:     maxSize,
+		idleTimeout: idleTimeout,
+		entries:     make(map[string]*list.Element),
+		lru:         list.New(),
+		pending:     make(map[string]*pendingClient),
+	}
+}
+
+// SetLimits updates the size limit and idle timeout, and evicts clients exceeding the new limits.
+// A non-positive value keeps the current setting.
+func (r *ClientRegistry) SetLimits(maxSize int, idleTimeout time.Duration) {
+	r.Lock()
+	if maxSize > 0 {
+		r.maxSize = maxSize
+	}
+	if idleTimeout > 0 {
+		r.idleTimeout = idleTimeout
+	}
+	evicted := r.evict(time.Now())
+	r.Unlock()
+	closeClients(evicted)
+}
+
+// Len returns the number of cached clients
+func (r *ClientRegistry) Len() int {
+	r.Lock()
+	defer r.Unlock()
+	return r.lru.Len()
+}
+
+// Get returns the client cached for a key, and marks it as the most recently used.
+// The client is not held for the caller, so it may be closed by eviction; requests should use GetOrCreate instead.
+func (r *ClientRegistry) Get(key string) (CachedClient, bool) {
+	r.Lock()
+	defer r.Unlock()
+	elem, ok := r.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*registryEntry)
+	entry.lastUsed = time.Now()
+	r.lru.MoveToFront(elem)
+	return entry.client, true
+}
+
+// GetOrCreate returns the client cached for a key, or creates and caches a new client if it is not cached.
+// The client is created outside the registry lock, so a slow network does not block requests of other keys.
+// Concurrent requests of the same key wait for the client being created, so they do not create duplicate clients.
+// The client is held for the caller before the registry is unlocked, so it is not closed by eviction until the caller calls Release.
+func (r *ClientRegistry) GetOrCreate(key string, create func() (CachedClient, error)) (CachedClient, error) {
+	r.Lock()
+	if elem, ok := r.entries[key]; ok {
+		entry := elem.Value.(*registryEntry)
+		entry.lastUsed = time.Now()
+		r.lru.MoveToFront(elem)
+		entry.client.retain()
+		r.Unlock()
+		return entry.client, nil
+	}
+	if p, ok := r.pending[key]; ok {
+		r.Unlock()
+		<-p.done
+		if p.err != nil {
+			return nil, p.err
+		}
+		// look up the new client again, which may have been evicted, or not cached because it is stale
+		return r.GetOrCreate(key, create)
+	}
+	p := &pendingClient{done: make(chan struct{})}
+	r.pending[key] = p
+	r.Unlock()
+
+	client, err := create()
+	r.Lock()
+	delete(r.pending, key)
+	p.err = err
+	var evicted []CachedClient
+	if err == nil {
+		client.retain()
+		if p.stale {
+			// close the client of a previous network config after the caller releases it
+			logger.Infof("do not cache Fabric client %s created before its connection is reset", key)
+			evicted = append(evicted, client)
+		} else {
+			evicted = r.put(key, client)
+		}
+	}
+	r.Unlock()
+	close(p.done)
+	closeClients(evicted)
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// Put caches a client for a key, and closes the client previously cached for the same key
+func (r *ClientRegistry) Put(key string, client CachedClient) {
+	r.Lock()
+	evicted := r.put(key, client)
+	r.Unlock()
+	closeClients(evicted)
+}
+
+// Remove removes and closes the client cached for a key
+func (r *ClientRegistry) Remove(key string) {
+	r.Lock()
+	var evicted []CachedClient
+	if elem, ok := r.entries[key]; ok {
+		evicted = append(evicted, r.remove(elem))
+	}
+	r.Unlock()
+	closeClients(evicted)
+}
+
+// RemoveConnection removes the clients of a connection, e.g., after its network config is reloaded.
+// The removed clients are closed after callers holding them release them.
+func (r *ClientRegistry) RemoveConnection(connectionName string) {
+	r.Lock()
+	removed := r.removeConnection(connectionName)
+	r.Unlock()
+	closeClients(removed)
+}
+
+// retireConnection removes the clients of a connection without closing them, and returns the removed clients.
+// The shared SDK of the connection is retired under the same lock, so a concurrent request cannot cache a new client on the retired SDK.
+func (r *ClientRegistry) retireConnection(connectionName string) []CachedClient {
+	r.Lock()
+	defer r.Unlock()
+	retireSDK(connectionName)
+	return r.removeConnection(connectionName)
+}
+
+// removeConnection removes the clients of a connection, and returns the removed clients; the caller must hold the lock
+func (r *ClientRegistry) removeConnection(connectionName string) []CachedClient {
+	r.markPendingStale()
+	var removed []CachedClient
+	for e := r.lru.Front(); e != nil; {
+		next := e.Next()
+		if c := e.Value.(*registryEntry).client; c.ConnectionName() == connectionName {
+			removed = append(removed, r.remove(e))
+		}
+		e = next
+	}
+	return removed
+}
+
+// Start implements managed.Managed.Start. It starts a background process that evicts idle clients.
+func (r *ClientRegistry) Start() error {
+	r.Lock()
+	defer r.Unlock()
+	if r.done != nil {
+		return nil
+	}
+	r.done = make(chan struct{})
+	go r.evictIdle(r.done)
+	return nil
+}
+
+// Stop implements managed.Managed.Stop. It closes all cached clients.
+func (r *ClientRegistry) Stop() error {
+	r.Close()
+	return nil
+}
+
+// Close stops idle eviction, and closes all cached clients after their in-flight requests complete
+func (r *ClientRegistry) Close() {
+	r.Lock()
+	if r.done != nil {
+		close(r.done)
+		r.done = nil
+	}
+	var clients []CachedClient
+	for e := r.lru.Front(); e != nil; e = e.Next() {
+		clients = append(clients, e.Value.(*registryEntry).client)
+	}
+	r.entries = make(map[string]*list.Element)
+	r.lru.Init()
+	r.markPendingStale()
+	r.Unlock()
+
+	logger.Infof("close %d cached Fabric clients", len(clients))
+	for _, c := range clients {
+		c.Close()
+	}
+}
+
+// put adds a client and returns the evicted clients; the caller must hold the lock
+func (r *ClientRegistry) put(key string, client CachedClient) []CachedClient {
+	var evicted []CachedClient
+	if elem, ok := r.entries[key]; ok {
+		if old := r.remove(elem); old != client {
+			evicted = append(evicted, old)
+		}
+	}
+	r.entries[key] = r.lru.PushFront(&registryEntry{key: key, client: client, lastUsed: time.Now()})
+	return append(evicted, r.evict(time.Now())...)
+}
+
+// evict removes clients exceeding the size limit or idle timeout; the caller must hold the lock
+func (r *ClientRegistry) evict(now time.Time) []CachedClient {
+	var evicted []CachedClient
+	for r.maxSize > 0 && r.lru.Len() > r.maxSize {
+		entry := r.lru.Back().Value.(*registryEntry)
+		logger.Infof("evict least recently used Fabric client %s", entry.key)
+		evicted = append(evicted, r.remove(r.lru.Back()))
+	}
+	for r.idleTimeout > 0 && r.lru.Len() > 0 {
+		entry := r.lru.Back().Value.(*registryEntry)
+		if now.Sub(entry.lastUsed) <= r.idleTimeout {
+			break
+		}
+		logger.Infof("evict Fabric client %s idle since %s", entry.key, entry.lastUsed.Format(time.RFC3339))
+		evicted = append(evicted, r.remove(r.lru.Back()))
+	}
+	return evicted
+}
+
+// markPendingStale prevents clients being created from being cached, because they may use a retired SDK; the caller must hold the lock
+func (r *ClientRegistry) markPendingStale() {
+	for _, p := range r.pending {
+		p.stale = true
+	}
+}
+
+func (r *ClientRegistry) remove(elem *list.Element) CachedClient {
+	entry := r.lru.Remove(elem).(*registryEntry)
+	delete(r.entries, entry.key)
+	return entry.client
+}
+
+// evictIdle checks idle clients periodically until done is closed
+func (r *ClientRegistry) evictIdle(done chan struct{}) {
+	r.Lock()
+	interval := r.idleTimeout / 2
+	r.Unlock()
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			r.Lock()
+			evicted := r.evict(now)
+			r.Unlock()
+			closeClients(evicted)
+		}
+	}
+}
+
+// closeClients closes evicted clients in background, so the registry is not blocked by in-flight requests
+func closeClients(clients []CachedClient) {
+	for _, c := range clients {
+		go c.Close()
+	}
+}
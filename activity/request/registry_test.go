@@ -0,0 +1,237 @@
+/*
+SPDX-License-Identifier: BSD-3-Clause-Open-MPI
+*/
+
+package request
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testRegistryClient(name string) *FabricClient {
+	mock := NewMockBackend()
+	mock.On(opQuery, "basic", "ReadAsset").Return([]byte(name), 200).Repeatedly()
+	return &FabricClient{name: name, client: mock}
+}
+
+func TestClientRegistryLRU(t *testing.T) {
+	registry := NewClientRegistry(2, time.Hour)
+	c1, c2, c3 := testRegistryClient("c1"), testRegistryClient("c2"), testRegistryClient("c3")
+	registry.Put("c1", c1)
+	registry.Put("c2", c2)
+
+	client, ok := registry.Get("c1")
+	require.True(t, ok, "c1 should be cached")
+	assert.Equal(t, c1, client, "cached client should be c1")
+
+	created, err := registry.GetOrCreate("c3", func() (CachedClient, error) { return c3, nil })
+	require.NoError(t, err, "create c3 should not throw error")
+	assert.Equal(t, c3, created, "new client should be c3")
+	created.Release()
+	assert.Equal(t, 2, registry.Len(), "registry should not exceed size limit")
+	_, ok = registry.Get("c2")
+	assert.False(t, ok, "least recently used client c2 should be evicted")
+
+	// evicted client is closed in background
+	assert.Eventually(t, func() bool {
+		_, err := c2.QueryChaincode("basic", "ReadAsset", nil, nil)
+		return err != nil
+	}, time.Second, 10*time.Millisecond, "evicted client should be closed")
+
+	cached, err := registry.GetOrCreate("c1", func() (CachedClient, error) { return c3, nil })
+	require.NoError(t, err, "get c1 should not throw error")
+	assert.Equal(t, c1, cached, "cached client should not be created again")
+	cached.Release()
+
+	registry.Close()
+	assert.Equal(t, 0, registry.Len(), "close should remove all clients")
+	_, err = c1.QueryChaincode("basic", "ReadAsset", nil, nil)
+	assert.Error(t, err, "request of closed client should throw error")
+}
+
+func TestClientRegistryIdle(t *testing.T) {
+	registry := NewClientRegistry(10, time.Hour)
+	registry.Put("c1", testRegistryClient("c1"))
+	registry.Put("c2", testRegistryClient("c2"))
+
+	// mark c1 as idle for 2 hours
+	registry.entries["c1"].Value.(*registryEntry).lastUsed = time.Now().Add(-2 * time.Hour)
+	registry.SetLimits(0, 0)
+	_, ok := registry.Get("c1")
+	assert.False(t, ok, "idle client should be evicted")
+	_, ok = registry.Get("c2")
+	assert.True(t, ok, "recently used client should not be evicted")
+
+	require.NoError(t, registry.Start(), "start registry should not throw error")
+	require.NoError(t, registry.Stop(), "stop registry should not throw error")
+	assert.Equal(t, 0, registry.Len(), "stop should close all clients")
+}
+
+func TestCloseWaitsForRequests(t *testing.T) {
+	client := testRegistryClient("c1")
+	require.NoError(t, client.acquire(), "acquire open client should not throw error")
+
+	closed := make(chan struct{})
+	go func() {
+		client.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		t.Fatal("close should wait for in-flight request")
+	case <-time.After(50 * time.Millisecond):
+	}
+	client.release()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("close should complete after in-flight request")
+	}
+	assert.Error(t, client.acquire(), "acquire closed client should throw error")
+}
+
+func TestEvictHeldClient(t *testing.T) {
+	registry := NewClientRegistry(1, time.Hour)
+	c1 := testRegistryClient("c1")
+	held, err := registry.GetOrCreate("c1", func() (CachedClient, error) { return c1, nil })
+	require.NoError(t, err, "create c1 should not throw error")
+
+	// c1 is evicted by c2 before the caller sends its request
+	c2, err := registry.GetOrCreate("c2", func() (CachedClient, error) { return testRegistryClient("c2"), nil })
+	require.NoError(t, err, "create c2 should not throw error")
+	defer c2.Release()
+	_, ok := registry.Get("c1")
+	assert.False(t, ok, "c1 should be evicted")
+	time.Sleep(50 * time.Millisecond)
+	_, err = c1.QueryChaincode("basic", "ReadAsset", nil, nil)
+	assert.NoError(t, err, "evicted client should not be closed before the caller releases it")
+
+	held.Release()
+	assert.Eventually(t, func() bool {
+		_, err := c1.QueryChaincode("basic", "ReadAsset", nil, nil)
+		return err != nil
+	}, time.Second, 10*time.Millisecond, "evicted client should be closed after the caller releases it")
+}
+
+func TestCreateOutsideLock(t *testing.T) {
+	registry := NewClientRegistry(10, time.Hour)
+	defer registry.Close()
+
+	// c1 is slow to create, e.g., its peers are unreachable
+	creating := make(chan struct{})
+	unblock := make(chan struct{})
+	var created int32
+	slow := func() (CachedClient, error) {
+		atomic.AddInt32(&created, 1)
+		close(creating)
+		<-unblock
+		return testRegistryClient("c1"), nil
+	}
+	results := make(chan CachedClient, 2)
+	go func() {
+		c, err := registry.GetOrCreate("c1", slow)
+		assert.NoError(t, err, "create c1 should not throw error")
+		results <- c
+	}()
+	<-creating
+	go func() {
+		c, err := registry.GetOrCreate("c1", slow)
+		assert.NoError(t, err, "concurrent request of c1 should not throw error")
+		results <- c
+	}()
+
+	c2, err := registry.GetOrCreate("c2", func() (CachedClient, error) { return testRegistryClient("c2"), nil })
+	require.NoError(t, err, "create c2 should not be blocked by c1")
+	c2.Release()
+
+	close(unblock)
+	first, second := <-results, <-results
+	assert.Equal(t, first, second, "concurrent requests of c1 should share the same client")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&created), "c1 should be created once")
+	first.Release()
+	second.Release()
+}
+
+func TestCreateDuringReset(t *testing.T) {
+	registry := NewClientRegistry(10, time.Hour)
+	defer registry.Close()
+
+	creating := make(chan struct{})
+	unblock := make(chan struct{})
+	result := make(chan CachedClient, 1)
+	go func() {
+		c, err := registry.GetOrCreate("reset-network.mychannel", func() (CachedClient, error) {
+			close(creating)
+			<-unblock
+			return testRegistryClient("reset-network"), nil
+		})
+		assert.NoError(t, err, "create client should not throw error")
+		result <- c
+	}()
+	<-creating
+	registry.retireConnection("reset-network")
+	close(unblock)
+
+	stale := (<-result).(*FabricClient)
+	_, err := stale.QueryChaincode("basic", "ReadAsset", nil, nil)
+	assert.NoError(t, err, "client created during reset should be usable by its caller")
+	_, ok := registry.Get("reset-network.mychannel")
+	assert.False(t, ok, "client created during reset should not be cached")
+	stale.Release()
+	assert.Eventually(t, func() bool {
+		_, err := stale.QueryChaincode("basic", "ReadAsset", nil, nil)
+		return err != nil
+	}, time.Second, 10*time.Millisecond, "client created during reset should be closed after it is released")
+}
+
+// isOpen returns true if a client is not closed
+func isOpen(c *clientRefs) bool {
+	if !c.acquire() {
+		return false
+	}
+	c.release()
+	return true
+}
+
+func TestRemoveConnection(t *testing.T) {
+	registry := NewClientRegistry(10, time.Hour)
+	defer registry.Close()
+	for _, name := range []string{"org1", "org10", "org1.net"} {
+		registry.Put(name+".mychannel.User1", NewServiceClient(name, nil, name))
+	}
+	client, err := registry.GetOrCreate("org1.mychannel.User1", func() (CachedClient, error) { return nil, errors.New("cached client should be used") })
+	require.NoError(t, err, "get cached service client should not throw error")
+	held := client.(*ServiceClient)
+	assert.Equal(t, "org1", held.Service(), "cached service client should be returned")
+
+	registry.RemoveConnection("org1")
+	_, ok := registry.Get("org1.mychannel.User1")
+	assert.False(t, ok, "client of removed connection should be removed")
+	_, ok = registry.Get("org10.mychannel.User1")
+	assert.True(t, ok, "client of connection with the removed name as prefix should not be removed")
+	_, ok = registry.Get("org1.net.mychannel.User1")
+	assert.True(t, ok, "client of connection with the removed name as prefix should not be removed")
+
+	time.Sleep(50 * time.Millisecond)
+	assert.True(t, isOpen(&held.clientRefs), "removed client should not be closed before it is released")
+	held.Release()
+	assert.Eventually(t, func() bool {
+		return !isOpen(&held.clientRefs)
+	}, time.Second, 10*time.Millisecond, "removed client should be closed after it is released")
+}
+
+func TestClientKey(t *testing.T) {
+	spec := ConnectorSpec{Name: "test", ChannelID: "mychannel", UserName: "User1", OrgName: "org1"}
+	other := spec
+	other.ChannelID = "otherchannel"
+	assert.NotEqual(t, clientKey(spec), clientKey(other), "clients of different channels should not share cache key")
+	other = spec
+	other.UserName = "Admin"
+	assert.NotEqual(t, clientKey(spec), clientKey(other), "clients of different users should not share cache key")
+}
@@ -0,0 +1,162 @@
+/*
+SPDX-License-Identifier: BSD-3-Clause-Open-MPI
+*/
+
+package request
+
+import (
+	"strings"
+	"time"
+
+	cb "github.com/hyperledger/fabric-protos-go/common"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/retry"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/status"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+)
+
+// RetryPolicy configures retries of failed Fabric requests.
+// Zero values of attempts and backoff use the defaults of fabric-sdk-go.
+type RetryPolicy struct {
+	// Attempts is the max number of retries of a failed request
+	Attempts       int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	BackoffFactor  float64
+	// RetryOn are the error types to retry, e.g., TIMEOUT or CONNECTION_FAILURE.
+	// If it is empty, retry errors that are retried by fabric-sdk-go channel client by default.
+	RetryOn []string
+	// ResubmitOnConflict re-endorses and resubmits an invoke transaction that is invalidated
+	// by MVCC_READ_CONFLICT or PHANTOM_READ_CONFLICT, up to the number of Attempts
+	ResubmitOnConflict bool
+}
+
+// retryableCodes are the status codes of fabric-sdk-go for the error types that can be retried by channel client
+var retryableCodes = map[string]map[status.Group][]status.Code{
+	ErrTimeout: {
+		status.ClientStatus:         {status.Timeout},
+		status.EndorserClientStatus: {status.Timeout},
+		status.OrdererClientStatus:  {status.Timeout},
+		status.GRPCTransportStatus:  {status.Code(codes.DeadlineExceeded)},
+	},
+	ErrConnectionFailure: {
+		status.EndorserClientStatus: {status.ConnectionFailed},
+		status.OrdererClientStatus:  {status.ConnectionFailed},
+		status.GRPCTransportStatus:  {status.Code(codes.Unavailable)},
+		status.EndorserServerStatus: {status.Code(cb.Status_SERVICE_UNAVAILABLE)},
+		status.OrdererServerStatus:  {status.Code(cb.Status_SERVICE_UNAVAILABLE)},
+	},
+	ErrEndorsementFailure: {
+		status.EndorserServerStatus: {status.Code(cb.Status_INTERNAL_SERVER_ERROR), status.PvtDataDisseminationFailed},
+		status.ChaincodeStatus:      {status.Code(cb.Status_INTERNAL_SERVER_ERROR)},
+	},
+	ErrEndorsementMismatch: {
+		status.EndorserClientStatus: {status.EndorsementMismatch},
+	},
+	ErrChaincodeNotFound: {
+		status.EndorserClientStatus: {status.ChaincodeNameNotFound},
+	},
+	ErrPolicyFailure: {
+		status.EventServerStatus: {status.Code(pb.TxValidationCode_ENDORSEMENT_POLICY_FAILURE)},
+	},
+}
+
+// NewRetryPolicy returns a retry policy for error types in a comma-delimited string, e.g., "TIMEOUT,CONNECTION_FAILURE"
+func NewRetryPolicy(attempts, initialBackoffMillis, maxBackoffMillis int, backoffFactor float64, retryOn string, resubmitOnConflict bool) (*RetryPolicy, error) {
+	policy := &RetryPolicy{
+		Attempts:           attempts,
+		InitialBackoff:     time.Duration(initialBackoffMillis) * time.Millisecond,
+		MaxBackoff:         time.Duration(maxBackoffMillis) * time.Millisecond,
+		BackoffFactor:      backoffFactor,
+		ResubmitOnConflict: resubmitOnConflict,
+	}
+	for _, t := range strings.Split(retryOn, ",") {
+		t = strings.ToUpper(strings.TrimSpace(t))
+		if len(t) == 0 {
+			continue
+		}
+		if t == ErrMVCCReadConflict || t == ErrPhantomReadConflict {
+			return nil, errors.Errorf("Error type %s is retried by resubmitOnConflict", t)
+		}
+		if _, ok := retryableCodes[t]; !ok {
+			return nil, errors.Errorf("Error type %s cannot be retried", t)
+		}
+		policy.RetryOn = append(policy.RetryOn, t)
+	}
+	return policy, nil
+}
+
+// channelOpts returns retry options of fabric-sdk-go channel client, which are the defaults of fabric-sdk-go if no policy is set.
+// If ResubmitOnConflict is set, invalidated transactions are not retried by channel client, they are resubmitted by FabricClient.
+func (p *RetryPolicy) channelOpts() retry.Opts {
+	opts := retry.DefaultChannelOpts
+	if p == nil {
+		return opts
+	}
+	if p.Attempts > 0 {
+		opts.Attempts = p.Attempts
+	}
+	if p.InitialBackoff > 0 {
+		opts.InitialBackoff = p.InitialBackoff
+	}
+	if p.MaxBackoff > 0 {
+		opts.MaxBackoff = p.MaxBackoff
+	}
+	if p.BackoffFactor > 0 {
+		opts.BackoffFactor = p.BackoffFactor
+	}
+	if len(p.RetryOn) == 0 {
+		if p.ResubmitOnConflict {
+			opts.RetryableCodes = withoutConflicts(opts.RetryableCodes)
+		}
+		return opts
+	}
+	opts.RetryableCodes = make(map[status.Group][]status.Code)
+	for _, t := range p.RetryOn {
+		for g, c := range retryableCodes[t] {
+			opts.RetryableCodes[g] = append(opts.RetryableCodes[g], c...)
+		}
+	}
+	return opts
+}
+
+// backoff returns the backoff period before a retry attempt starting from 0
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	opts := p.channelOpts()
+	backoff, max := float64(opts.InitialBackoff), float64(opts.MaxBackoff)
+	for i := 0; i < attempt && backoff < max; i++ {
+		backoff *= opts.BackoffFactor
+	}
+	if backoff > max {
+		backoff = max
+	}
+	return time.Duration(backoff)
+}
+
+// withoutConflicts removes validation codes of read conflicts from retryable status codes
+func withoutConflicts(codes map[status.Group][]status.Code) map[status.Group][]status.Code {
+	result := make(map[status.Group][]status.Code)
+	for g, cs := range codes {
+		for _, c := range cs {
+			if g == status.EventServerStatus && isConflict(int32(c)) {
+				continue
+			}
+			result[g] = append(result[g], c)
+		}
+	}
+	return result
+}
+
+func isConflict(validationCode int32) bool {
+	code := pb.TxValidationCode(validationCode)
+	return code == pb.TxValidationCode_MVCC_READ_CONFLICT || code == pb.TxValidationCode_PHANTOM_READ_CONFLICT
+}
+
+// isConflictError returns true if a transaction is invalidated by read conflict
+func isConflictError(err error) bool {
+	if s, ok := status.FromError(err); ok && s.Group == status.EventServerStatus {
+		return isConflict(s.Code)
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+/*
+SPDX-License-Identifier: BSD-3-Clause-Open-MPI
+*/
+
+package request
+
+import (
+	"testing"
+	"time"
+
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/retry"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/status"
+	"github.com/project-flogo/core/data/mapper"
+	"github.com/project-flogo/core/data/resolve"
+	"github.com/project-flogo/core/support/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	var defaultPolicy *RetryPolicy
+	opts := defaultPolicy.channelOpts()
+	assert.Equal(t, retry.DefaultAttempts, opts.Attempts, "nil policy should use default attempts")
+	assert.Equal(t, retry.DefaultChannelOpts.RetryableCodes, opts.RetryableCodes, "nil policy should retry the default codes of fabric-sdk-go")
+	assert.Contains(t, opts.RetryableCodes[status.EventServerStatus], status.Code(pb.TxValidationCode_MVCC_READ_CONFLICT), "nil policy should retry MVCC conflict by channel client")
+
+	resubmit, err := NewRetryPolicy(3, 0, 0, 0, "", true)
+	require.NoError(t, err, "create retry policy should not throw error")
+	opts = resubmit.channelOpts()
+	assert.NotContains(t, opts.RetryableCodes[status.EventServerStatus], status.Code(pb.TxValidationCode_MVCC_READ_CONFLICT), "MVCC conflict should not be retried by channel client if it is resubmitted")
+	assert.Contains(t, opts.RetryableCodes[status.EventServerStatus], status.Code(pb.TxValidationCode_ENDORSEMENT_POLICY_FAILURE), "policy failure should be retried by default")
+	assert.Contains(t, opts.RetryableCodes[status.EndorserClientStatus], status.EndorsementMismatch, "default codes should retry endorsement mismatch")
+
+	policy, err := NewRetryPolicy(5, 100, 1000, 3, "timeout, CONNECTION_FAILURE", true)
+	require.NoError(t, err, "create retry policy should not throw error")
+	assert.Equal(t, []string{ErrTimeout, ErrConnectionFailure}, policy.RetryOn, "error types should be normalized")
+	opts = policy.channelOpts()
+	assert.Equal(t, 5, opts.Attempts, "retry attempts should be 5")
+	assert.Equal(t, []status.Code{status.Timeout}, opts.RetryableCodes[status.ClientStatus], "client timeout should be retried")
+	assert.Equal(t, []status.Code{status.Timeout, status.ConnectionFailed}, opts.RetryableCodes[status.EndorserClientStatus], "endorser timeout and connection failure should be retried")
+	assert.Empty(t, opts.RetryableCodes[status.EventServerStatus], "invalidated transaction should not be retried by channel client")
+
+	assert.Equal(t, 100*time.Millisecond, policy.backoff(0), "first backoff should be initial backoff")
+	assert.Equal(t, 900*time.Millisecond, policy.backoff(2), "backoff should increase by factor")
+	assert.Equal(t, time.Second, policy.backoff(3), "backoff should not exceed max backoff")
+
+	_, err = NewRetryPolicy(3, 0, 0, 0, "MVCC_READ_CONFLICT", false)
+	assert.Error(t, err, "MVCC conflict should be retried by resubmitOnConflict only")
+	_, err = NewRetryPolicy(3, 0, 0, 0, "UNKNOWN", false)
+	assert.Error(t, err, "unknown error type should not be retried")
+}
+
+func TestResubmitOnConflict(t *testing.T) {
+	mock := NewMockBackend()
+	mock.On(opInvoke, "marbles", "TransferMarble").WithTransaction("tx-conflict-1", "MVCC_READ_CONFLICT")
+	mock.On(opInvoke, "marbles", "TransferMarble").WithTransaction("tx-conflict-2", "PHANTOM_READ_CONFLICT")
+	valid := mock.On(opInvoke, "marbles", "TransferMarble").WithTransaction("tx-valid", "")
+	RegisterBackend("mock-network", mock)
+	defer UnregisterBackend("mock-network")
+
+	settings := map[string]interface{}{
+		"connectionName":            "mock-network",
+		"channelID":                 "mychannel",
+		"chaincodeID":               "marbles",
+		"transactionName":           "TransferMarble",
+		"parameters":                "name,owner",
+		"requestType":               "invoke",
+		"retryAttempts":             2,
+		"retryInitialBackoffMillis": 1,
+		"resubmitOnConflict":        true,
+	}
+	req := `{
+		"userName": "Admin",
+		"parameters": {
+			"name": "marble1",
+			"owner": "tom"
+		}
+	}`
+	output, done, err := evalActivity(t, settings, req)
+	assert.True(t, done, "invoke should be successful after resubmit")
+	assert.NoError(t, err, "invoke should not throw error after resubmit")
+	assert.Equal(t, "tx-valid", output.TransactionID, "output should contain transaction ID of the last attempt")
+	assert.Equal(t, 1, valid.Count(), "transaction should be committed by the last attempt")
+
+	mock.On(opInvoke, "marbles", "TransferMarble").WithTransaction("tx-conflict-3", "MVCC_READ_CONFLICT").Repeatedly()
+	output, done, err = evalActivity(t, settings, req)
+	assert.False(t, done, "invoke should fail after max attempts")
+	assert.Error(t, err, "invoke should throw error after max attempts")
+	assert.Equal(t, ErrMVCCReadConflict, output.ErrorType, "error type should be MVCC conflict")
+	assert.NoError(t, mock.ExpectationsMet(), "all expected calls should be requested")
+
+	settings["retryOn"] = "MVCC_READ_CONFLICT"
+	_, err = New(test.NewActivityInitContext(settings, mapper.NewFactory(resolve.GetBasicResolver())))
+	assert.Error(t, err, "MVCC conflict is not a valid retry error type")
+}
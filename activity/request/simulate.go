@@ -0,0 +1,89 @@
+/*
+SPDX-License-Identifier: BSD-3-Clause-Open-MPI
+*/
+
+package request
+
+import (
+	"bytes"
+
+	"github.com/golang/protobuf/proto"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/pkg/errors"
+)
+
+// Simulation is the result of an endorse-only transaction that is not sent to orderer
+type Simulation struct {
+	Payload       string            `json:"payload"`
+	Consistent    bool              `json:"consistent"`
+	ReadWriteSets []*NsReadWriteSet `json:"rwsets"`
+	Endorsements  []*Endorsement    `json:"endorsements"`
+}
+
+// Endorsement is the simulation result returned by an endorser
+type Endorsement struct {
+	Endorser
+	Status        int32             `json:"status"`
+	Message       string            `json:"message,omitempty"`
+	Payload       string            `json:"payload"`
+	ReadWriteSets []*NsReadWriteSet `json:"rwsets"`
+}
+
+// ToMap converts simulation result to a JSON object for Flogo mapping
+func (s *Simulation) ToMap() (map[string]interface{}, error) {
+	result, err := toJSONObject(s)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to convert simulation result to map")
+	}
+	return result, nil
+}
+
+// Simulation decodes the chaincode response and read/write sets returned by each endorser.
+// Endorsements are consistent if all endorsers returned the same proposal response payload and status.
+func (r *Response) Simulation() (*Simulation, error) {
+	result := &Simulation{
+		Payload:    string(r.Payload),
+		Consistent: true,
+	}
+	endorsers := r.Endorsers()
+	var firstPayload []byte
+	for i, p := range r.Responses {
+		e := &Endorsement{Endorser: *endorsers[i], Status: p.Status}
+		if p.ProposalResponse != nil {
+			if resp := p.ProposalResponse.Response; resp != nil {
+				e.Status = resp.Status
+				e.Message = resp.Message
+				e.Payload = string(resp.Payload)
+			}
+			rwsets, err := decodeProposalResponsePayload(p.ProposalResponse.Payload)
+			if err != nil {
+				return nil, errors.Wrapf(err, "Failed to decode proposal response of %s", p.Endorser)
+			}
+			e.ReadWriteSets = rwsets
+			if i == 0 {
+				firstPayload = p.ProposalResponse.Payload
+				result.ReadWriteSets = rwsets
+			} else if !bytes.Equal(firstPayload, p.ProposalResponse.Payload) || e.Status != result.Endorsements[0].Status {
+				result.Consistent = false
+			}
+		}
+		result.Endorsements = append(result.Endorsements, e)
+	}
+	return result, nil
+}
+
+// decodeProposalResponsePayload returns the read/write sets of a serialized ProposalResponsePayload
+func decodeProposalResponsePayload(payload []byte) ([]*NsReadWriteSet, error) {
+	if len(payload) == 0 {
+		return nil, nil
+	}
+	responsePayload := &pb.ProposalResponsePayload{}
+	if err := proto.Unmarshal(payload, responsePayload); err != nil {
+		return nil, errors.Wrapf(err, "Failed to unmarshal proposal response payload")
+	}
+	ccAction := &pb.ChaincodeAction{}
+	if err := proto.Unmarshal(responsePayload.Extension, ccAction); err != nil {
+		return nil, errors.Wrapf(err, "Failed to unmarshal chaincode action")
+	}
+	return DecodeReadWriteSets(ccAction.Results)
+}
@@ -0,0 +1,419 @@
+/*
+SPDX-License-Identifier: BSD-3-Clause-Open-MPI
+*/
+
+package request
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/scrypt"
+)
+
+// ErrIdentityNotFound is returned when a label is not found in a wallet
+var ErrIdentityNotFound = errors.New("Identity not found in wallet")
+
+// WalletIdentity is an X.509 identity stored in a wallet
+type WalletIdentity struct {
+	MspID string
+	// PEM encoded certificate
+	Certificate string
+	// PEM encoded private key
+	PrivateKey string
+}
+
+// Wallet stores identities by label
+type Wallet interface {
+	Put(label string, identity *WalletIdentity) error
+	// Get returns ErrIdentityNotFound if the label is not found
+	Get(label string) (*WalletIdentity, error)
+	Remove(label string) error
+	// List returns sorted labels of the wallet
+	List() ([]string, error)
+}
+
+// fingerprint identifies the content of an identity, so cached clients of the same label are replaced when the identity is updated
+func (id *WalletIdentity) fingerprint() string {
+	hash := sha256.Sum256([]byte(id.MspID + id.Certificate + id.PrivateKey))
+	return hex.EncodeToString(hash[:8])
+}
+
+// verify returns error if the certificate and private key of the identity are not a valid key pair
+func (id *WalletIdentity) verify() error {
+	if len(id.MspID) == 0 {
+		return errors.New("MSP ID of identity is not specified")
+	}
+	if _, err := tls.X509KeyPair([]byte(id.Certificate), []byte(id.PrivateKey)); err != nil {
+		return errors.Wrapf(err, "Invalid certificate or private key of identity")
+	}
+	return nil
+}
+
+// wallets registered by name, and file-system wallets opened by path with hash of their passphrases
+var (
+	walletLock        sync.RWMutex
+	walletMap         = map[string]Wallet{}
+	walletPassphrases = map[string]string{}
+)
+
+// RegisterWallet registers a wallet by name, so activities can select identities from the wallet of the name
+func RegisterWallet(name string, wallet Wallet) {
+	walletLock.Lock()
+	defer walletLock.Unlock()
+	walletMap[name] = wallet
+	delete(walletPassphrases, name)
+}
+
+// UnregisterWallet removes the wallet registered for a name
+func UnregisterWallet(name string) {
+	walletLock.Lock()
+	defer walletLock.Unlock()
+	delete(walletMap, name)
+	delete(walletPassphrases, name)
+}
+
+// LookupWallet returns the wallet registered by name, or the file-system wallet of the name opened by OpenWallet.
+// It does not open a new wallet, so names from untrusted input cannot access arbitrary directories.
+func LookupWallet(name string) (Wallet, bool) {
+	walletLock.RLock()
+	defer walletLock.RUnlock()
+	w, ok := walletMap[name]
+	return w, ok
+}
+
+// OpenWallet returns the wallet registered by name, or opens a file-system wallet in the directory of the name.
+// The file-system wallet is encrypted if a passphrase is specified, and it returns error if the passphrase does not match
+// the passphrase of the wallet, or the passphrase that opened the wallet before.
+// It is used for wallets of activity settings only; wallets of request input are resolved by LookupWallet.
+func OpenWallet(name, passphrase string) (Wallet, error) {
+	walletLock.Lock()
+	defer walletLock.Unlock()
+	hash := sha256.Sum256([]byte(passphrase))
+	passphraseHash := hex.EncodeToString(hash[:])
+	if w, ok := walletMap[name]; ok {
+		if opened, ok := walletPassphrases[name]; ok && opened != passphraseHash {
+			return nil, errors.Errorf("Passphrase does not match the opened wallet %s", name)
+		}
+		return w, nil
+	}
+	var w Wallet
+	var err error
+	if len(passphrase) > 0 {
+		w, err = NewEncryptedFileWallet(name, passphrase)
+	} else {
+		w, err = NewFileSystemWallet(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	walletMap[name] = w
+	walletPassphrases[name] = passphraseHash
+	return w, nil
+}
+
+// inMemoryWallet stores identities in memory
+type inMemoryWallet struct {
+	sync.RWMutex
+	identities map[string]*WalletIdentity
+}
+
+// NewInMemoryWallet returns a wallet that stores identities in memory
+func NewInMemoryWallet() Wallet {
+	return &inMemoryWallet{identities: make(map[string]*WalletIdentity)}
+}
+
+// Put implements Wallet.Put
+func (w *inMemoryWallet) Put(label string, identity *WalletIdentity) error {
+	if err := verifyLabel(label); err != nil {
+		return err
+	}
+	w.Lock()
+	defer w.Unlock()
+	id := *identity
+	w.identities[label] = &id
+	return nil
+}
+
+// Get implements Wallet.Get
+func (w *inMemoryWallet) Get(label string) (*WalletIdentity, error) {
+	w.RLock()
+	defer w.RUnlock()
+	identity, ok := w.identities[label]
+	if !ok {
+		return nil, ErrIdentityNotFound
+	}
+	id := *identity
+	return &id, nil
+}
+
+// Remove implements Wallet.Remove
+func (w *inMemoryWallet) Remove(label string) error {
+	w.Lock()
+	defer w.Unlock()
+	delete(w.identities, label)
+	return nil
+}
+
+// List implements Wallet.List
+func (w *inMemoryWallet) List() ([]string, error) {
+	w.RLock()
+	defer w.RUnlock()
+	var labels []string
+	for k := range w.identities {
+		labels = append(labels, k)
+	}
+	sort.Strings(labels)
+	return labels, nil
+}
+
+// walletFileSuffix is the file extension of identities in a file-system wallet
+const walletFileSuffix = ".id"
+
+// fileSystemWallet stores each identity in a file named by its label, using the same JSON format as Fabric SDKs for Node.js and Java
+type fileSystemWallet struct {
+	dir string
+	// key encrypts identity files if it is not nil
+	key []byte
+}
+
+// identityFile is the JSON content of an identity file
+type identityFile struct {
+	Credentials struct {
+		Certificate string `json:"certificate"`
+		PrivateKey  string `json:"privateKey"`
+	} `json:"credentials"`
+	MspID   string `json:"mspId"`
+	Type    string `json:"type"`
+	Version int    `json:"version"`
+}
+
+// encryptedFile is the JSON content of an encrypted identity file
+type encryptedFile struct {
+	Nonce []byte `json:"nonce"`
+	Data  []byte `json:"data"`
+}
+
+// NewFileSystemWallet returns a wallet that stores identities in a directory
+func NewFileSystemWallet(dir string) (Wallet, error) {
+	dir = Subst(dir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, errors.Wrapf(err, "Failed to create wallet directory %s", dir)
+	}
+	return &fileSystemWallet{dir: dir}, nil
+}
+
+// walletCheckFile stores a value encrypted by the key of an encrypted wallet, which verifies the passphrase when the wallet is opened
+const walletCheckFile = ".check"
+
+// walletCheckValue is the plain text of the check file
+var walletCheckValue = []byte("fabric-client wallet")
+
+// NewEncryptedFileWallet returns a wallet that stores identities in a directory, encrypted by AES-GCM with a key derived from a passphrase.
+// It returns error if the passphrase cannot decrypt the wallet.
+func NewEncryptedFileWallet(dir, passphrase string) (Wallet, error) {
+	if len(passphrase) == 0 {
+		return nil, errors.New("Passphrase of encrypted wallet is not specified")
+	}
+	w, err := NewFileSystemWallet(dir)
+	if err != nil {
+		return nil, err
+	}
+	fw := w.(*fileSystemWallet)
+	salt, err := walletSalt(fw.dir)
+	if err != nil {
+		return nil, err
+	}
+	if fw.key, err = scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32); err != nil {
+		return nil, errors.Wrapf(err, "Failed to derive wallet key")
+	}
+	if err := fw.verifyKey(); err != nil {
+		return nil, err
+	}
+	return fw, nil
+}
+
+// verifyKey decrypts the check file of the wallet, or an identity of a wallet created without a check file, and writes the check file if it does not exist
+func (w *fileSystemWallet) verifyKey() error {
+	checkFile := filepath.Join(w.dir, walletCheckFile)
+	if data, err := ioutil.ReadFile(checkFile); err == nil {
+		if value, err := w.decrypt(data); err != nil || !bytes.Equal(value, walletCheckValue) {
+			return errors.Errorf("Invalid passphrase of wallet %s", w.dir)
+		}
+		return nil
+	}
+	labels, err := w.List()
+	if err != nil {
+		return err
+	}
+	if len(labels) > 0 {
+		if _, err := w.Get(labels[0]); err != nil {
+			return errors.Wrapf(err, "Invalid passphrase of wallet %s", w.dir)
+		}
+	}
+	data, err := w.encrypt(walletCheckValue)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(checkFile, data, 0600); err != nil {
+		return errors.Wrapf(err, "Failed to write wallet check file")
+	}
+	return nil
+}
+
+// walletSalt returns the salt of an encrypted wallet, which is created when the wallet is first opened
+func walletSalt(dir string) ([]byte, error) {
+	saltFile := filepath.Join(dir, ".salt")
+	if salt, err := ioutil.ReadFile(saltFile); err == nil {
+		return salt, nil
+	}
+	salt := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, errors.Wrapf(err, "Failed to generate wallet salt")
+	}
+	if err := ioutil.WriteFile(saltFile, salt, 0600); err != nil {
+		return nil, errors.Wrapf(err, "Failed to write wallet salt")
+	}
+	return salt, nil
+}
+
+// Put implements Wallet.Put
+func (w *fileSystemWallet) Put(label string, identity *WalletIdentity) error {
+	if err := verifyLabel(label); err != nil {
+		return err
+	}
+	content := &identityFile{MspID: identity.MspID, Type: "X.509", Version: 1}
+	content.Credentials.Certificate = identity.Certificate
+	content.Credentials.PrivateKey = identity.PrivateKey
+	data, err := json.Marshal(content)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to serialize identity %s", label)
+	}
+	if w.key != nil {
+		if data, err = w.encrypt(data); err != nil {
+			return err
+		}
+	}
+	// write to a temp file and rename it, so readers do not see a partially written file
+	path := w.path(label)
+	if err := ioutil.WriteFile(path+".tmp", data, 0600); err != nil {
+		return errors.Wrapf(err, "Failed to write identity %s", label)
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// Get implements Wallet.Get
+func (w *fileSystemWallet) Get(label string) (*WalletIdentity, error) {
+	if err := verifyLabel(label); err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(w.path(label))
+	if os.IsNotExist(err) {
+		return nil, ErrIdentityNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to read identity %s", label)
+	}
+	if w.key != nil {
+		if data, err = w.decrypt(data); err != nil {
+			return nil, errors.Wrapf(err, "Failed to decrypt identity %s", label)
+		}
+	}
+	content := &identityFile{}
+	if err := json.Unmarshal(data, content); err != nil {
+		return nil, errors.Wrapf(err, "Failed to parse identity %s", label)
+	}
+	return &WalletIdentity{
+		MspID:       content.MspID,
+		Certificate: content.Credentials.Certificate,
+		PrivateKey:  content.Credentials.PrivateKey,
+	}, nil
+}
+
+// Remove implements Wallet.Remove
+func (w *fileSystemWallet) Remove(label string) error {
+	if err := verifyLabel(label); err != nil {
+		return err
+	}
+	if err := os.Remove(w.path(label)); err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "Failed to remove identity %s", label)
+	}
+	return nil
+}
+
+// List implements Wallet.List
+func (w *fileSystemWallet) List() ([]string, error) {
+	files, err := ioutil.ReadDir(w.dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to read wallet directory %s", w.dir)
+	}
+	var labels []string
+	for _, f := range files {
+		if !f.IsDir() && strings.HasSuffix(f.Name(), walletFileSuffix) {
+			labels = append(labels, strings.TrimSuffix(f.Name(), walletFileSuffix))
+		}
+	}
+	sort.Strings(labels)
+	return labels, nil
+}
+
+func (w *fileSystemWallet) path(label string) string {
+	return filepath.Join(w.dir, label+walletFileSuffix)
+}
+
+func (w *fileSystemWallet) encrypt(data []byte) ([]byte, error) {
+	gcm, err := newGCM(w.key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, errors.Wrapf(err, "Failed to generate nonce")
+	}
+	return json.Marshal(&encryptedFile{Nonce: nonce, Data: gcm.Seal(nil, nonce, data, nil)})
+}
+
+func (w *fileSystemWallet) decrypt(data []byte) ([]byte, error) {
+	content := &encryptedFile{}
+	if err := json.Unmarshal(data, content); err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(w.key)
+	if err != nil {
+		return nil, err
+	}
+	if len(content.Nonce) != gcm.NonceSize() {
+		return nil, errors.New("Invalid nonce")
+	}
+	return gcm.Open(nil, content.Nonce, content.Data, nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to create cipher")
+	}
+	return cipher.NewGCM(block)
+}
+
+// verifyLabel returns error if a label cannot be used as a file name
+func verifyLabel(label string) error {
+	if len(label) == 0 || strings.ContainsAny(label, `/\`) || label == "." || label == ".." {
+		return errors.Errorf("Invalid wallet label '%s'", label)
+	}
+	return nil
+}
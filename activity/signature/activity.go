@@ -0,0 +1,167 @@
+/*
+SPDX-License-Identifier: BSD-3-Clause-Open-MPI
+*/
+
+package signature
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	"github.com/open-dovetail/fabric-client/activity/request"
+	"github.com/open-dovetail/fabric-client/activity/signcert"
+	"github.com/pkg/errors"
+	"github.com/project-flogo/core/activity"
+	"github.com/project-flogo/core/support/log"
+)
+
+const (
+	opSign   = "sign"
+	opVerify = "verify"
+)
+
+// Create a new logger
+var logger = log.ChildLogger(log.RootLogger(), "activity-fabclient-signature")
+
+var activityMd = activity.ToMetadata(&Settings{}, &Input{}, &Output{})
+
+func init() {
+	_ = activity.Register(&Activity{}, New)
+}
+
+// Activity fabric signature activity struct
+type Activity struct {
+	connectionName string
+	operation      string
+	wallet         request.Wallet
+}
+
+// New creates a new Activity
+func New(ctx activity.InitContext) (activity.Activity, error) {
+	s := &Settings{}
+	if err := s.FromMap(ctx.Settings()); err != nil {
+		logger.Errorf("failed to configure signature activity %v", err)
+		return nil, err
+	}
+	switch s.Operation {
+	case opSign, opVerify:
+	default:
+		return nil, errors.Errorf("unsupported signature operation %s", s.Operation)
+	}
+
+	act := &Activity{connectionName: s.ConnectionName, operation: s.Operation}
+	if len(s.Wallet) > 0 {
+		wallet, err := request.OpenWallet(s.Wallet, s.WalletPassphrase)
+		if err != nil {
+			logger.Errorf("failed to open wallet %s: %v", s.Wallet, err)
+			return nil, err
+		}
+		act.wallet = wallet
+	}
+	return act, nil
+}
+
+// Metadata implements activity.Activity.Metadata
+func (a *Activity) Metadata() *activity.Metadata {
+	return activityMd
+}
+
+// Eval implements activity.Activity.Eval
+func (a *Activity) Eval(ctx activity.Context) (done bool, err error) {
+	logger.Debugf("%v", a)
+
+	// check input args
+	input := &Input{}
+	if err = ctx.GetInputObject(input); err != nil {
+		return false, err
+	}
+	message, err := CanonicalJSON(input.Data)
+	if err != nil {
+		return a.setError(ctx, 400, err)
+	}
+
+	if a.operation == opSign {
+		return a.sign(ctx, input, message)
+	}
+	return a.verify(ctx, input, message)
+}
+
+// sign signs a message by the private key of the input user
+func (a *Activity) sign(ctx activity.Context, input *Input, message []byte) (bool, error) {
+	if len(input.UserName) == 0 {
+		return a.setError(ctx, 400, errors.New("user name is not specified"))
+	}
+	identity, err := a.userIdentity(input.UserName)
+	if err != nil {
+		return a.setError(ctx, 404, err)
+	}
+	if len(identity.PrivateKey) == 0 {
+		return a.setError(ctx, 404, errors.Errorf("private key of %s is not found", input.UserName))
+	}
+	signature, err := Sign([]byte(identity.PrivateKey), message)
+	if err != nil {
+		return a.setError(ctx, 500, err)
+	}
+	output := &Output{
+		Code:        200,
+		Message:     fmt.Sprintf("Signed %d bytes by %s", len(message), input.UserName),
+		Signature:   base64.StdEncoding.EncodeToString(signature),
+		Certificate: identity.Certificate,
+		MspID:       identity.MspID,
+		Valid:       true,
+	}
+	ctx.SetOutputObject(output)
+	return true, nil
+}
+
+// verify verifies the signature of a message by the input certificate, or the certificate of the input user.
+// An invalid signature returns valid=false with the reason in message, so flows can reject the message.
+func (a *Activity) verify(ctx activity.Context, input *Input, message []byte) (bool, error) {
+	signature, err := base64.StdEncoding.DecodeString(input.Signature)
+	if err != nil || len(signature) == 0 {
+		return a.setError(ctx, 400, errors.New("signature is not specified or not base64 encoded"))
+	}
+	output := &Output{Code: 200}
+	if len(input.Certificate) > 0 {
+		output.Certificate = request.DecodePEM(input.Certificate)
+	} else if len(input.UserName) > 0 {
+		identity, err := a.userIdentity(input.UserName)
+		if err != nil {
+			return a.setError(ctx, 404, err)
+		}
+		output.Certificate = identity.Certificate
+		output.MspID = identity.MspID
+	} else {
+		return a.setError(ctx, 400, errors.New("certificate or user name is not specified"))
+	}
+
+	if err := Verify([]byte(output.Certificate), message, signature); err != nil {
+		logger.Infof("signature is not valid: %v", err)
+		output.Message = err.Error()
+	} else {
+		output.Valid = true
+		output.Message = "Signature is valid"
+	}
+	ctx.SetOutputObject(output)
+	return true, nil
+}
+
+// userIdentity returns the identity of a wallet label, or of a user@org in the cryptoPath of the network config
+func (a *Activity) userIdentity(user string) (*request.WalletIdentity, error) {
+	if a.wallet != nil {
+		identity, err := a.wallet.Get(user)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to get identity %s from wallet", user)
+		}
+		return identity, nil
+	}
+	return signcert.NetworkUserIdentity(signcert.LookupNetwork(a.connectionName), user)
+}
+
+// setError sets activity output for a failed signature request
+func (a *Activity) setError(ctx activity.Context, code int, err error) (bool, error) {
+	logger.Errorf("signature %s request returned error %+v", a.operation, err)
+	output := &Output{Code: code, Message: err.Error()}
+	ctx.SetOutputObject(output)
+	return false, err
+}
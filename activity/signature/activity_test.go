@@ -0,0 +1,79 @@
+/*
+SPDX-License-Identifier: BSD-3-Clause-Open-MPI
+*/
+
+package signature
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/open-dovetail/fabric-client/activity/request"
+	"github.com/project-flogo/core/data/mapper"
+	"github.com/project-flogo/core/data/resolve"
+	"github.com/project-flogo/core/support/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func evalActivity(t *testing.T, operation string, input *Input) (*Output, bool, error) {
+	settings := map[string]interface{}{
+		"operation": operation,
+		"wallet":    "test-signers",
+	}
+	mf := mapper.NewFactory(resolve.GetBasicResolver())
+	ctx := test.NewActivityInitContext(settings, mf)
+	act, err := New(ctx)
+	require.NoError(t, err, "create activity instance should not throw error")
+
+	tc := test.NewActivityContext(act.Metadata())
+	err = tc.SetInputObject(input)
+	require.NoError(t, err, "setting action input should not throw error")
+	done, err := act.Eval(tc)
+
+	output := &Output{}
+	err2 := tc.GetOutputObject(output)
+	assert.NoError(t, err2, "action output should not be error")
+	return output, done, err
+}
+
+func TestSignatureActivity(t *testing.T) {
+	wallet := request.NewInMemoryWallet()
+	request.RegisterWallet("test-signers", wallet)
+	defer request.UnregisterWallet("test-signers")
+	cert, key := newTestKeyPair(t, "alice")
+	require.NoError(t, wallet.Put("alice", &request.WalletIdentity{MspID: "Org1MSP", Certificate: cert, PrivateKey: key}), "put identity should not throw error")
+	bobCert, _ := newTestKeyPair(t, "bob")
+	require.NoError(t, wallet.Put("bob", &request.WalletIdentity{MspID: "Org2MSP", Certificate: bobCert}), "put identity should not throw error")
+
+	data := map[string]interface{}{"event": "delivered", "id": "asset1"}
+	output, done, err := evalActivity(t, opSign, &Input{UserName: "alice", Data: data})
+	require.True(t, done, "sign should be successful")
+	require.NoError(t, err, "sign should not throw error")
+	assert.Equal(t, 200, output.Code, "output status code should be 200")
+	assert.Equal(t, "Org1MSP", output.MspID, "output should contain signer MSP ID")
+	assert.Equal(t, cert, output.Certificate, "output should contain signer certificate")
+	signature := output.Signature
+
+	// verify by user name and by supplied certificate
+	output, done, err = evalActivity(t, opVerify, &Input{UserName: "alice", Data: data, Signature: signature})
+	require.True(t, done, "verify should be successful")
+	require.NoError(t, err, "verify should not throw error")
+	assert.True(t, output.Valid, "signature should be valid for the signer")
+	certB64 := base64.StdEncoding.EncodeToString([]byte(cert))
+	output, _, _ = evalActivity(t, opVerify, &Input{Certificate: certB64, Data: `{"id":"asset1","event":"delivered"}`, Signature: signature})
+	assert.False(t, output.Valid, "non-canonical JSON text should not match the signature")
+	output, _, _ = evalActivity(t, opVerify, &Input{Certificate: certB64, Data: `{"event":"delivered","id":"asset1"}`, Signature: signature})
+	assert.True(t, output.Valid, "canonical JSON text should match the signature")
+
+	output, done, err = evalActivity(t, opVerify, &Input{UserName: "bob", Data: data, Signature: signature})
+	assert.True(t, done, "verify with another user should complete")
+	assert.NoError(t, err, "verify with another user should not throw error")
+	assert.False(t, output.Valid, "signature should not be valid for another user")
+
+	output, done, _ = evalActivity(t, opSign, &Input{UserName: "bob", Data: data})
+	assert.False(t, done, "sign without private key should fail")
+	assert.Equal(t, 404, output.Code, "output status code should be 404")
+	_, done, _ = evalActivity(t, opVerify, &Input{UserName: "alice", Data: data, Signature: "not base64"})
+	assert.False(t, done, "verify invalid signature encoding should fail")
+}
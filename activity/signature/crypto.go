@@ -0,0 +1,107 @@
+/*
+SPDX-License-Identifier: BSD-3-Clause-Open-MPI
+*/
+
+package signature
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+
+	"github.com/open-dovetail/fabric-client/activity/request"
+	"github.com/pkg/errors"
+)
+
+// ecdsaSignature is the ASN.1 structure of an ECDSA signature used by Fabric
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+// Sign returns the DER encoded ECDSA signature of the SHA-256 hash of a message, with low-S normalization as required by Fabric
+func Sign(keyPEM []byte, message []byte) ([]byte, error) {
+	key, err := request.ParseECPrivateKey(keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(message)
+	r, s, err := ecdsa.Sign(rand.Reader, key, hash[:])
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to sign message")
+	}
+	s = toLowS(key.Curve, s)
+	return asn1.Marshal(ecdsaSignature{R: r, S: s})
+}
+
+// Verify verifies a DER encoded ECDSA signature of a message against the public key of a PEM encoded certificate.
+// It returns an error if the signature is not valid, or it is not low-S normalized as required by Fabric.
+func Verify(certPEM []byte, message, signature []byte) error {
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return errors.New("Certificate is not PEM encoded")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to parse x509 certificate")
+	}
+	pubKey, ok := cert.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return errors.New("Certificate does not contain an ECDSA public key")
+	}
+
+	sig := &ecdsaSignature{}
+	rest, err := asn1.Unmarshal(signature, sig)
+	if err != nil || len(rest) > 0 || sig.R == nil || sig.S == nil {
+		return errors.New("Signature is not a DER encoded ECDSA signature")
+	}
+	if sig.R.Sign() <= 0 || sig.S.Sign() <= 0 {
+		return errors.New("Signature contains non-positive values")
+	}
+	if sig.S.Cmp(halfOrder(pubKey.Curve)) > 0 {
+		return errors.New("Signature is not low-S normalized")
+	}
+	hash := sha256.Sum256(message)
+	if !ecdsa.Verify(pubKey, hash[:], sig.R, sig.S) {
+		return errors.New("Signature does not match the message and certificate")
+	}
+	return nil
+}
+
+// CanonicalJSON returns the canonical JSON of a value, i.e., object keys are sorted, and no insignificant whitespace or HTML escaping.
+// A string or byte array is returned as is, so flows can sign arbitrary text.
+func CanonicalJSON(value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, errors.New("Data to sign is not specified")
+	case string:
+		return []byte(v), nil
+	case []byte:
+		return v, nil
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(value); err != nil {
+		return nil, errors.Wrapf(err, "Failed to serialize data as JSON")
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// toLowS returns s if it is not larger than half order of the curve, or the order minus s otherwise
+func toLowS(curve elliptic.Curve, s *big.Int) *big.Int {
+	if s.Cmp(halfOrder(curve)) > 0 {
+		return new(big.Int).Sub(curve.Params().N, s)
+	}
+	return s
+}
+
+func halfOrder(curve elliptic.Curve) *big.Int {
+	return new(big.Int).Rsh(curve.Params().N, 1)
+}
@@ -0,0 +1,81 @@
+/*
+SPDX-License-Identifier: BSD-3-Clause-Open-MPI
+*/
+
+package signature
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestKeyPair returns a self-signed PEM certificate and its PKCS8 private key
+func newTestKeyPair(t *testing.T, name string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err, "generate key should not throw error")
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err, "create certificate should not throw error")
+	keyDer, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err, "marshal private key should not throw error")
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDer}))
+}
+
+func TestSignVerify(t *testing.T) {
+	cert, key := newTestKeyPair(t, "alice")
+	message := []byte(`{"amount":100,"id":"asset1"}`)
+	for i := 0; i < 10; i++ {
+		// repeat to cover signatures that require low-S normalization
+		sig, err := Sign([]byte(key), message)
+		require.NoError(t, err, "sign should not throw error")
+		parsed := &ecdsaSignature{}
+		_, err = asn1.Unmarshal(sig, parsed)
+		require.NoError(t, err, "signature should be DER encoded")
+		assert.True(t, parsed.S.Cmp(halfOrder(elliptic.P256())) <= 0, "signature should be low-S")
+		assert.NoError(t, Verify([]byte(cert), message, sig), "signature should be valid")
+
+		// high-S signature of the same message is rejected
+		highS, _ := asn1.Marshal(ecdsaSignature{R: parsed.R, S: new(big.Int).Sub(elliptic.P256().Params().N, parsed.S)})
+		assert.Error(t, Verify([]byte(cert), message, highS), "high-S signature should be rejected")
+	}
+
+	sig, _ := Sign([]byte(key), message)
+	assert.Error(t, Verify([]byte(cert), []byte(`{"amount":101,"id":"asset1"}`), sig), "tampered message should be rejected")
+	other, _ := newTestKeyPair(t, "bob")
+	assert.Error(t, Verify([]byte(other), message, sig), "signature should not match another certificate")
+}
+
+func TestCanonicalJSON(t *testing.T) {
+	data, err := CanonicalJSON(map[string]interface{}{
+		"id":     "asset1",
+		"amount": 100,
+		"owner":  map[string]interface{}{"name": "Tom & Jerry", "email": "tom@example.com"},
+		"tags":   []interface{}{"b", "a"},
+	})
+	require.NoError(t, err, "canonical JSON should not throw error")
+	assert.Equal(t, `{"amount":100,"id":"asset1","owner":{"email":"tom@example.com","name":"Tom & Jerry"},"tags":["b","a"]}`, string(data), "keys should be sorted without whitespace")
+
+	data, err = CanonicalJSON("plain text")
+	assert.NoError(t, err, "string data should not throw error")
+	assert.Equal(t, "plain text", string(data), "string data should be signed as is")
+
+	_, err = CanonicalJSON(nil)
+	assert.Error(t, err, "nil data should throw error")
+}
@@ -0,0 +1,127 @@
+/*
+SPDX-License-Identifier: BSD-3-Clause-Open-MPI
+*/
+
+package signature
+
+import (
+	"strings"
+
+	"github.com/project-flogo/core/data/coerce"
+)
+
+// Settings of the activity
+type Settings struct {
+	ConnectionName string `md:"connectionName"`
+	Operation      string `md:"operation,required"`
+	// wallet of signing identities
+	Wallet           string `md:"wallet"`
+	WalletPassphrase string `md:"walletPassphrase"`
+}
+
+// Input of the activity
+type Input struct {
+	UserName string      `md:"userName"`
+	Data     interface{} `md:"data,required"`
+	// base64 encoded signature to verify
+	Signature string `md:"signature"`
+	// PEM or base64 encoded PEM certificate to verify a signature, which overrides the certificate of userName
+	Certificate string `md:"certificate"`
+}
+
+// Output of the activity
+type Output struct {
+	Code    int    `md:"code"`
+	Message string `md:"message"`
+	// base64 encoded signature of sign operation
+	Signature string `md:"signature"`
+	// PEM certificate and MSP ID of the signer
+	Certificate string `md:"certificate"`
+	MspID       string `md:"mspID"`
+	// result of verify operation
+	Valid bool `md:"valid"`
+}
+
+// FromMap sets activity settings from a map
+func (h *Settings) FromMap(values map[string]interface{}) error {
+	var err error
+	if h.ConnectionName, err = coerce.ToString(values["connectionName"]); err != nil {
+		return err
+	}
+	if h.Operation, err = coerce.ToString(values["operation"]); err != nil {
+		return err
+	}
+	if h.Wallet, err = coerce.ToString(values["wallet"]); err != nil {
+		return err
+	}
+	if h.WalletPassphrase, err = coerce.ToString(values["walletPassphrase"]); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ToMap converts activity input to a map
+func (i *Input) ToMap() map[string]interface{} {
+	return map[string]interface{}{
+		"userName":    i.UserName,
+		"data":        i.Data,
+		"signature":   i.Signature,
+		"certificate": i.Certificate,
+	}
+}
+
+// FromMap sets activity input values from a map
+func (i *Input) FromMap(values map[string]interface{}) error {
+	var err error
+	if i.UserName, err = coerce.ToString(values["userName"]); err != nil {
+		return err
+	}
+	i.UserName = strings.TrimSpace(i.UserName)
+	if i.Data, err = coerce.ToAny(values["data"]); err != nil {
+		return err
+	}
+	if i.Signature, err = coerce.ToString(values["signature"]); err != nil {
+		return err
+	}
+	if i.Certificate, err = coerce.ToString(values["certificate"]); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ToMap converts activity output to a map
+func (o *Output) ToMap() map[string]interface{} {
+	return map[string]interface{}{
+		"code":        o.Code,
+		"message":     o.Message,
+		"signature":   o.Signature,
+		"certificate": o.Certificate,
+		"mspID":       o.MspID,
+		"valid":       o.Valid,
+	}
+}
+
+// FromMap sets activity output values from a map
+func (o *Output) FromMap(values map[string]interface{}) error {
+
+	var err error
+	if o.Code, err = coerce.ToInt(values["code"]); err != nil {
+		return err
+	}
+	if o.Message, err = coerce.ToString(values["message"]); err != nil {
+		return err
+	}
+	if o.Signature, err = coerce.ToString(values["signature"]); err != nil {
+		return err
+	}
+	if o.Certificate, err = coerce.ToString(values["certificate"]); err != nil {
+		return err
+	}
+	if o.MspID, err = coerce.ToString(values["mspID"]); err != nil {
+		return err
+	}
+	if o.Valid, err = coerce.ToBool(values["valid"]); err != nil {
+		return err
+	}
+	return nil
+}
@@ -5,21 +5,41 @@ SPDX-License-Identifier: BSD-3-Clause-Open-MPI
 package signcert
 
 import (
+	"fmt"
+	"strings"
+
+	"github.com/open-dovetail/fabric-client/activity/request"
+	"github.com/pkg/errors"
 	"github.com/project-flogo/core/activity"
 	"github.com/project-flogo/core/support/log"
 )
 
+const (
+	opCertificate = "certificate"
+	opUsers       = "users"
+)
+
 // Create a new logger
 var logger = log.ChildLogger(log.RootLogger(), "activity-fabclient-signcert")
 
-// NetworkConfig is the content of fabric network config file
+// NetworkConfig is the content of the default fabric network config file,
+// which is used by connections that do not have a network config in the request activity
 var NetworkConfig []byte
 
-// InitializeNetwork can be called to initialize Fabric network config
+// InitializeNetwork can be called to initialize the default Fabric network config
 func InitializeNetwork(config []byte) {
 	NetworkConfig = config
 }
 
+// LookupNetwork returns the network config of a connection registered or loaded at runtime by the request activity,
+// or the default network config
+func LookupNetwork(connectionName string) []byte {
+	if config, _ := request.LookupNetwork(connectionName); len(config) > 0 {
+		return config
+	}
+	return NetworkConfig
+}
+
 var activityMd = activity.ToMetadata(&Settings{}, &Input{}, &Output{})
 
 func init() {
@@ -28,11 +48,36 @@ func init() {
 
 // Activity fabric signcert activity struct
 type Activity struct {
+	connectionName string
+	operation      string
+	wallet         request.Wallet
+	verify         bool
 }
 
 // New creates a new Activity
 func New(ctx activity.InitContext) (activity.Activity, error) {
-	return &Activity{}, nil
+	s := &Settings{}
+	if err := s.FromMap(ctx.Settings()); err != nil {
+		logger.Errorf("failed to configure signcert activity %v", err)
+		return nil, err
+	}
+	switch s.Operation {
+	case "":
+		s.Operation = opCertificate
+	case opCertificate, opUsers:
+	default:
+		return nil, errors.Errorf("unsupported signcert operation %s", s.Operation)
+	}
+	act := &Activity{connectionName: s.ConnectionName, operation: s.Operation, verify: s.Verify}
+	if len(s.Wallet) > 0 {
+		wallet, err := request.OpenWallet(s.Wallet, s.WalletPassphrase)
+		if err != nil {
+			logger.Errorf("failed to open wallet %s: %v", s.Wallet, err)
+			return nil, err
+		}
+		act.wallet = wallet
+	}
+	return act, nil
 }
 
 // Metadata implements activity.Activity.Metadata
@@ -50,16 +95,94 @@ func (a *Activity) Eval(ctx activity.Context) (done bool, err error) {
 		return false, err
 	}
 
+	if a.operation == opUsers {
+		return a.listUsers(ctx, input.OrgName)
+	}
+	if len(input.UserName) == 0 {
+		output := &Output{Code: 400, Message: "user name is not specified"}
+		ctx.SetOutputObject(output)
+		return false, errors.New("user name is not specified")
+	}
+
 	user := input.UserName
 	if len(input.OrgName) > 0 {
 		user += "@" + input.OrgName
 	}
-	cert := UserCertificate(user)
+	var cert []byte
+	var mspid string
+	if a.wallet != nil {
+		cert, mspid = walletUserCert(a.wallet, user)
+	} else {
+		cert, mspid = networkUserCert(LookupNetwork(a.connectionName), user)
+	}
+	if cert == nil {
+		output := &Output{Code: 404, Message: fmt.Sprintf("certificate of %s is not found", user)}
+		ctx.SetOutputObject(output)
+		return false, errors.Errorf("certificate of %s is not found", user)
+	}
 
+	details, err := ParseCertificate(cert, mspid)
+	if err != nil {
+		logger.Errorf("failed to parse certificate of %s: %v", user, err)
+		output := &Output{Code: 500, Message: err.Error()}
+		ctx.SetOutputObject(output)
+		return false, err
+	}
 	output := &Output{Code: 200,
 		Message: "",
-		Result:  cert,
+		Result:  certificateText(cert, user),
+		Details: details.ToMap(),
+	}
+	if a.verify {
+		verification, err := a.verifyCertificate(cert, mspid)
+		if err != nil {
+			logger.Errorf("failed to verify certificate of %s: %v", user, err)
+			output := &Output{Code: 500, Message: err.Error()}
+			ctx.SetOutputObject(output)
+			return false, err
+		}
+		if !verification.Valid {
+			logger.Infof("certificate of %s is not valid: %v", user, verification.Reasons)
+			output.Message = strings.Join(verification.Reasons, "; ")
+		}
+		output.Verification = verification.ToMap()
 	}
 	ctx.SetOutputObject(output)
 	return true, nil
 }
+
+// listUsers returns identities in the wallet, or users in the cryptoPath of the network config, of an org if specified
+func (a *Activity) listUsers(ctx activity.Context, org string) (bool, error) {
+	var users []*UserInfo
+	var err error
+	if a.wallet != nil {
+		users, err = WalletUsers(a.wallet)
+	} else {
+		users, err = NetworkUsers(LookupNetwork(a.connectionName), org)
+	}
+	if err != nil {
+		logger.Errorf("failed to list users: %v", err)
+		output := &Output{Code: 500, Message: err.Error()}
+		ctx.SetOutputObject(output)
+		return false, err
+	}
+	result := []interface{}{}
+	for _, u := range users {
+		result = append(result, u.ToMap())
+	}
+	output := &Output{Code: 200,
+		Message: fmt.Sprintf("Found %d users", len(result)),
+		Result:  result,
+	}
+	ctx.SetOutputObject(output)
+	return true, nil
+}
+
+// verifyCertificate verifies a certificate against the MSP folder of its org in the network config
+func (a *Activity) verifyCertificate(cert []byte, mspid string) (*Verification, error) {
+	mspDir, err := networkMSPDir(LookupNetwork(a.connectionName), mspid)
+	if err != nil {
+		return nil, err
+	}
+	return VerifyCertificate(cert, mspDir)
+}
@@ -8,11 +8,14 @@ import (
 	"encoding/json"
 	"os"
 	"testing"
+	"time"
 
+	"github.com/open-dovetail/fabric-client/activity/request"
 	"github.com/project-flogo/core/data/mapper"
 	"github.com/project-flogo/core/data/resolve"
 	"github.com/project-flogo/core/support/test"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var cryptoPath = "../../../hyperledger/fabric-samples/test-network/organizations"
@@ -85,3 +88,31 @@ func TestSigncert(t *testing.T) {
 	assert.Equal(t, 200, output.Code, "output status code should be 200")
 	assert.Contains(t, output.Result.(string), "CN=Admin@org1.example.com", "no data should be returned by this transaction")
 }
+
+func TestListWalletUsers(t *testing.T) {
+	wallet := request.NewInMemoryWallet()
+	request.RegisterWallet("test-users", wallet)
+	defer request.UnregisterWallet("test-users")
+	ca := newTestCA(t, "ca.org1.example.com")
+	err := wallet.Put("alice", &request.WalletIdentity{MspID: "Org1MSP", Certificate: string(ca.issue(t, 2, "client", time.Now().AddDate(0, 1, 0)))})
+	require.NoError(t, err, "put identity should not throw error")
+
+	mf := mapper.NewFactory(resolve.GetBasicResolver())
+	ctx := test.NewActivityInitContext(map[string]interface{}{"operation": "users", "wallet": "test-users"}, mf)
+	act, err := New(ctx)
+	require.NoError(t, err, "create activity instance should not throw error")
+	tc := test.NewActivityContext(act.Metadata())
+	done, err := act.Eval(tc)
+	assert.True(t, done, "list users should be successful")
+	assert.NoError(t, err, "list users should not throw error")
+
+	output := &Output{}
+	err = tc.GetOutputObject(output)
+	assert.NoError(t, err, "action output should not be error")
+	users := output.Result.([]interface{})
+	require.Len(t, users, 1, "wallet should contain 1 user")
+	user := users[0].(map[string]interface{})
+	assert.Equal(t, "alice", user["name"], "user name should be the wallet label")
+	assert.Equal(t, "Org1MSP", user["mspid"], "user should contain MSP ID")
+	assert.Equal(t, false, user["hasPrivateKey"], "user should not have private key")
+}
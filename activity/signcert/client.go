@@ -6,6 +6,9 @@ package signcert
 
 import (
 	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
@@ -14,9 +17,9 @@ import (
 	"strings"
 
 	"github.com/grantae/certinfo"
-	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/core"
 	pvmsp "github.com/hyperledger/fabric-sdk-go/pkg/common/providers/msp"
 	"github.com/hyperledger/fabric-sdk-go/pkg/msp"
+	"github.com/open-dovetail/fabric-client/activity/request"
 	"github.com/pkg/errors"
 	yaml "gopkg.in/yaml.v2"
 )
@@ -44,8 +47,55 @@ func yamlChildNode(parent interface{}, name string) (interface{}, bool) {
 	return c, ok
 }
 
-// UserCertificate returns certificate string of a specified user@org
+// UserCertificate returns certificate string of a specified user@org in the default network
 func UserCertificate(user string) string {
+	return NetworkUserCertificate(NetworkConfig, user)
+}
+
+// NetworkUserCertificate returns certificate string of a specified user@org in a network config
+func NetworkUserCertificate(networkConfig []byte, user string) string {
+	cert, _ := networkUserCert(networkConfig, user)
+	if cert == nil {
+		return ""
+	}
+	return certificateText(cert, user)
+}
+
+// networkUserCert returns the PEM certificate and MSP ID of a specified user@org in the cryptoPath of a network config
+func networkUserCert(networkConfig []byte, user string) ([]byte, string) {
+	mspPath, u, mspid, err := userCryptoPath(networkConfig, user)
+	if err != nil {
+		logger.Debugf("cannot find crypto path for %s: %v", user, err)
+		return nil, ""
+	}
+	return loadUserCert(mspPath, u, mspid), mspid
+}
+
+// loadUserCert returns the PEM certificate of a user in a crypto path, or nil if it is not found
+func loadUserCert(mspPath, u, mspid string) []byte {
+	certStore, err := msp.NewFileCertStore(mspPath)
+	if err != nil {
+		logger.Debugf("cannot find crypto path for %s: %v", u, err)
+		return nil
+	}
+
+	// read the cert file
+	cert, err := certStore.Load(&pvmsp.IdentityIdentifier{
+		ID:    u,
+		MSPID: mspid,
+	})
+	if err != nil {
+		logger.Debugf("cannot read cert file of %s", u)
+		return nil
+	}
+	return cert.([]byte)
+}
+
+// userCryptoPath returns the cryptoPath, user name and MSP ID of a specified user@org in a network config
+func userCryptoPath(networkConfig []byte, user string) (string, string, string, error) {
+	if len(networkConfig) == 0 {
+		return "", "", "", errors.New("Network config is not specified")
+	}
 	userTokens := strings.Split(user, "@")
 	u := userTokens[0]
 	org := ""
@@ -53,60 +103,167 @@ func UserCertificate(user string) string {
 		org = userTokens[1]
 	}
 	var data map[interface{}]interface{}
-	yaml.Unmarshal(NetworkConfig, &data)
-	cryptoPath := execYamlPath(data, "client.cryptoconfig.path").(string)
+	if err := yaml.Unmarshal(networkConfig, &data); err != nil {
+		return "", "", "", errors.Wrapf(err, "Failed to parse network config")
+	}
+	cryptoPath, _ := execYamlPath(data, "client.cryptoconfig.path").(string)
 	if len(org) == 0 {
 		// use network client org if user org is not specified
-		org = execYamlPath(data, "client.organization").(string)
-	}
-
-	// find cert file for specified user and org
-	var certStore core.KVStore
-	var mspid interface{}
-	var err error
-	for k, v := range data["organizations"].(map[interface{}]interface{}) {
-		if k.(string) == org {
-			mspid, _ = yamlChildNode(v, "mspid")
-			if pathTemplate, ok := yamlChildNode(v, "cryptoPath"); ok {
-				if !filepath.IsAbs(pathTemplate.(string)) {
-					pathTemplate = filepath.Join(cryptoPath, pathTemplate.(string))
-				}
-				certStore, err = msp.NewFileCertStore(Subst(pathTemplate.(string)))
-			}
-			break
+		clientOrg, ok := execYamlPath(data, "client.organization").(string)
+		if !ok {
+			return "", "", "", errors.New("Client organization is not specified in network config")
 		}
+		org = clientOrg
 	}
-	if err != nil || certStore == nil {
-		logger.Debugf("cannot find crypto path for org %s", org)
-		return ""
+
+	// find crypto path for specified user and org
+	orgConfig, ok := yamlChildNode(data["organizations"], org)
+	if !ok {
+		return "", "", "", errors.Errorf("Org %s is not found in network config", org)
+	}
+	mspid, ok := execYamlPath(orgConfig, "mspid").(string)
+	if !ok {
+		return "", "", "", errors.Errorf("No mspid is configured for org %s", org)
 	}
+	mspPath, ok := orgCryptoPath(orgConfig, cryptoPath)
+	if !ok {
+		return "", "", "", errors.Errorf("No cryptoPath is configured for org %s", org)
+	}
+	return mspPath, u, mspid, nil
+}
 
-	// read the cert file
-	cert, err := certStore.Load(&pvmsp.IdentityIdentifier{
-		ID:    u,
-		MSPID: mspid.(string),
-	})
+// orgCryptoPath returns the cryptoPath template of an org config, which is relative to the cryptoconfig path of the client
+func orgCryptoPath(orgConfig interface{}, cryptoPath string) (string, bool) {
+	pathTemplate, ok := execYamlPath(orgConfig, "cryptoPath").(string)
+	if !ok {
+		return "", false
+	}
+	if !filepath.IsAbs(pathTemplate) {
+		pathTemplate = filepath.Join(cryptoPath, pathTemplate)
+	}
+	return Subst(pathTemplate), true
+}
+
+// UserIdentity returns the MSP ID, certificate and private key of a specified user@org in the default network
+func UserIdentity(user string) (*request.WalletIdentity, error) {
+	return NetworkUserIdentity(NetworkConfig, user)
+}
+
+// NetworkUserIdentity returns the MSP ID, certificate and private key of a specified user@org in the cryptoPath of a network config.
+// The private key is empty if it is not found in the keystore of the user.
+func NetworkUserIdentity(networkConfig []byte, user string) (*request.WalletIdentity, error) {
+	mspPath, u, mspid, err := userCryptoPath(networkConfig, user)
 	if err != nil {
-		logger.Debugf("cannot read cert file of %s@%s", u, org)
+		return nil, errors.Wrapf(err, "Failed to find crypto path of %s", user)
+	}
+	cert := loadUserCert(mspPath, u, mspid)
+	if cert == nil {
+		return nil, errors.Errorf("Certificate of %s is not found", user)
+	}
+	identity := &request.WalletIdentity{MspID: mspid, Certificate: string(cert)}
+
+	ski, err := subjectKeyIdentifier(cert)
+	if err != nil {
+		return nil, err
+	}
+	keyStore, err := msp.NewFileKeyStore(mspPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to open keystore of %s", user)
+	}
+	key, err := keyStore.Load(&pvmsp.PrivKeyKey{ID: u, MSPID: mspid, SKI: ski})
+	if err != nil {
+		logger.Debugf("cannot read private key of %s: %v", user, err)
+		return identity, nil
+	}
+	identity.PrivateKey = string(key.([]byte))
+	return identity, nil
+}
+
+// subjectKeyIdentifier returns the SKI of an ECDSA certificate as computed by the Fabric crypto suite
+func subjectKeyIdentifier(certPEM []byte) ([]byte, error) {
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return nil, errors.New("Certificate is not PEM encoded")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to parse x509 certificate")
+	}
+	key, ok := cert.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, errors.New("Certificate does not contain an ECDSA public key")
+	}
+	hash := sha256.Sum256(elliptic.Marshal(key.Curve, key.X, key.Y))
+	return hash[:], nil
+}
+
+// networkMSPDir returns the MSP folder of an org in the cryptoPath of a network config, which contains cacerts,
+// intermediatecerts, crls and config.yaml. The org MSP folder is derived from a cryptoPath of format
+// peerOrganizations/org1.example.com/users/{username}@org1.example.com/msp as peerOrganizations/org1.example.com/msp.
+func networkMSPDir(networkConfig []byte, mspid string) (string, error) {
+	if len(networkConfig) == 0 {
+		return "", errors.New("Network config is not specified")
+	}
+	var data map[interface{}]interface{}
+	if err := yaml.Unmarshal(networkConfig, &data); err != nil {
+		return "", errors.Wrapf(err, "Failed to parse network config")
+	}
+	cryptoPath, _ := execYamlPath(data, "client.cryptoconfig.path").(string)
+	orgs, _ := data["organizations"].(map[interface{}]interface{})
+	for _, v := range orgs {
+		if id, _ := yamlChildNode(v, "mspid"); id != mspid {
+			continue
+		}
+		mspPath, ok := orgCryptoPath(v, cryptoPath)
+		if !ok {
+			return "", errors.Errorf("No cryptoPath is configured for MSP %s", mspid)
+		}
+		mspPath = filepath.ToSlash(mspPath)
+		if i := strings.LastIndex(mspPath, "/users/"); i >= 0 {
+			return filepath.FromSlash(mspPath[:i] + "/msp"), nil
+		}
+		return filepath.FromSlash(mspPath), nil
+	}
+	return "", errors.Errorf("MSP %s is not found in network config", mspid)
+}
+
+// WalletUserCertificate returns certificate string of an identity label in a wallet
+func WalletUserCertificate(wallet request.Wallet, label string) string {
+	cert, _ := walletUserCert(wallet, label)
+	if cert == nil {
 		return ""
 	}
+	return certificateText(cert, label)
+}
+
+// walletUserCert returns the PEM certificate and MSP ID of an identity label in a wallet
+func walletUserCert(wallet request.Wallet, label string) ([]byte, string) {
+	identity, err := wallet.Get(label)
+	if err != nil {
+		logger.Debugf("cannot find identity %s in wallet: %v", label, err)
+		return nil, ""
+	}
+	return []byte(identity.Certificate), identity.MspID
+}
 
+// certificateText returns the text info of a PEM encoded certificate
+func certificateText(cert []byte, user string) string {
 	// extract cert info from the certificate
-	block, rest := pem.Decode(cert.([]byte))
-	if block == nil || len(rest) > 0 {
-		logger.Debugf("failed to decode pem cert file of %s@%s", u, org)
+	block, rest := pem.Decode(cert)
+	if block == nil || len(bytes.TrimSpace(rest)) > 0 {
+		logger.Debugf("failed to decode pem cert file of %s", user)
 		return ""
 	}
 	blkBytes, err := x509.ParseCertificate(block.Bytes)
 	if err != nil {
-		logger.Debugf("failed to parse x509 certificate of %s@%s", u, org)
+		logger.Debugf("failed to parse x509 certificate of %s", user)
 		return ""
 	}
 
 	// print out cert info
 	certText, err := certinfo.CertificateText(blkBytes)
 	if err != nil {
-		logger.Debugf("failed to print cert info of %s@%s", u, org)
+		logger.Debugf("failed to print cert info of %s", user)
 		return ""
 	}
 	return certText
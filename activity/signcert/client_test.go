@@ -5,9 +5,17 @@ SPDX-License-Identifier: BSD-3-Clause-Open-MPI
 package signcert
 
 import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // This test requires to start the fabric test-network using
@@ -20,3 +28,96 @@ func TestUserCert(t *testing.T) {
 	logger.Infof("user cert: %s\n", cert)
 	assert.Contains(t, cert, "CN=User1@org1.example.com", "cert info should contain User1 as cn")
 }
+
+func TestNetworkMSPDir(t *testing.T) {
+	mspDir, err := networkMSPDir(NetworkConfig, "Org1MSP")
+	assert.NoError(t, err, "MSP folder of Org1MSP should be found")
+	assert.Equal(t, filepath.Join(cryptoPath, "peerOrganizations/org1.example.com/msp"), mspDir, "MSP folder should be the org msp")
+	_, err = networkMSPDir(NetworkConfig, "Org9MSP")
+	assert.Error(t, err, "unknown MSP should throw error")
+}
+
+// writeTestCrypto writes users Admin and User1 with private keys, and User2 without private key, in a cryptoPath of org1,
+// and returns the network config of the cryptoPath
+func writeTestCrypto(t *testing.T, dir string) []byte {
+	ca := newTestCA(t, "ca.org1.example.com")
+	for i, u := range []string{"Admin", "User1", "User2"} {
+		mspDir := filepath.Join(dir, "peerOrganizations/org1.example.com/users", u+"@org1.example.com", "msp")
+		writeTestFile(t, filepath.Join(mspDir, "signcerts", u+"@org1.example.com-cert.pem"), ca.issue(t, int64(i+2), "client", time.Now().AddDate(0, 1, 0)))
+		if u != "User2" {
+			keyDer, err := x509.MarshalPKCS8PrivateKey(ca.key)
+			require.NoError(t, err, "marshal private key should not throw error")
+			writeTestFile(t, filepath.Join(mspDir, "keystore", "priv_sk"), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDer}))
+		}
+	}
+	return []byte(fmt.Sprintf(`client:
+  organization: org1
+  cryptoconfig:
+    path: %s
+organizations:
+  org1:
+    mspid: Org1MSP
+    cryptoPath: peerOrganizations/org1.example.com/users/{username}@org1.example.com/msp
+  org2:
+    mspid: Org2MSP
+    cryptoPath: peerOrganizations/org2.example.com/users/{username}@org2.example.com/msp
+`, dir))
+}
+
+func TestNetworkUserIdentity(t *testing.T) {
+	dir, err := ioutil.TempDir("", "crypto")
+	require.NoError(t, err, "create temp folder should not throw error")
+	defer os.RemoveAll(dir)
+	config := writeTestCrypto(t, dir)
+
+	identity, err := NetworkUserIdentity(config, "User1@org1")
+	require.NoError(t, err, "user identity should be found")
+	assert.Equal(t, "Org1MSP", identity.MspID, "identity should contain MSP ID")
+	assert.Contains(t, identity.Certificate, "BEGIN CERTIFICATE", "identity should contain PEM certificate")
+	assert.Contains(t, identity.PrivateKey, "BEGIN PRIVATE KEY", "identity should contain PEM private key")
+
+	_, err = NetworkUserIdentity(config, "User3@org1")
+	assert.Error(t, err, "unknown user should throw error")
+}
+
+func TestNetworkUsers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "crypto")
+	require.NoError(t, err, "create temp folder should not throw error")
+	defer os.RemoveAll(dir)
+	config := writeTestCrypto(t, dir)
+
+	users, err := NetworkUsers(config, "")
+	require.NoError(t, err, "list users should not throw error")
+	require.Len(t, users, 3, "org1 should have 3 users, and org2 folder does not exist")
+	assert.Equal(t, "Admin", users[0].Name, "users should be sorted by name")
+	assert.Equal(t, "org1", users[0].Org, "user should contain org name")
+	assert.Equal(t, "Org1MSP", users[0].MspID, "user should contain MSP ID")
+	assert.NotEmpty(t, users[0].Subject, "user should contain cert subject")
+	assert.Len(t, users[0].Fingerprint, 64, "user should contain SHA-256 fingerprint of cert")
+	assert.True(t, users[1].HasPrivateKey, "User1 should have private key")
+	assert.False(t, users[2].HasPrivateKey, "User2 should not have private key")
+	user := users[2].ToMap()
+	assert.Equal(t, false, user["expired"], "certificate of User2 should not be expired")
+	assert.NotEmpty(t, user["notAfter"], "user should contain cert expiry")
+
+	users, err = NetworkUsers(config, "org2")
+	assert.NoError(t, err, "list users of org2 should not throw error")
+	assert.Empty(t, users, "org2 should not have users")
+	_, err = NetworkUsers(config, "org3")
+	assert.Error(t, err, "unknown org should throw error")
+}
+
+func TestIncompleteNetworkConfig(t *testing.T) {
+	_, err := NetworkUserIdentity(nil, "User1@org1")
+	assert.Error(t, err, "missing network config should throw error")
+	_, err = NetworkUsers(nil, "")
+	assert.Error(t, err, "users of missing network config should throw error")
+
+	noMSP := []byte("client:\n  organization: org1\norganizations:\n  org1:\n    cryptoPath: users/{username}/msp\n")
+	_, err = NetworkUserIdentity(noMSP, "User1")
+	assert.Error(t, err, "org without mspid should throw error")
+	noClientOrg := []byte("organizations:\n  org1:\n    mspid: Org1MSP\n")
+	_, err = NetworkUserIdentity(noClientOrg, "User1")
+	assert.Error(t, err, "user without org should throw error if client org is not specified")
+	assert.Empty(t, NetworkUserCertificate(noClientOrg, "User1"), "certificate should not be found if client org is not specified")
+}
@@ -0,0 +1,140 @@
+/*
+SPDX-License-Identifier: BSD-3-Clause-Open-MPI
+*/
+
+package signcert
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// attrOID is the certificate extension of attributes issued by Fabric CA
+var attrOID = asn1.ObjectIdentifier{1, 2, 3, 4, 5, 6, 7, 8, 1}
+
+// CertificateDetails contains fields of an X.509 certificate that can be mapped by Flogo flows
+type CertificateDetails struct {
+	Subject             string
+	Issuer              string
+	CommonName          string
+	OrganizationalUnits []string
+	// SerialNumber is hex encoded as used by Fabric CA
+	SerialNumber       string
+	NotBefore          time.Time
+	NotAfter           time.Time
+	KeyAlgorithm       string
+	SignatureAlgorithm string
+	// SANs contains DNS names, email addresses, IP addresses and URIs of the subject alternative names
+	SANs           []string
+	SubjectKeyID   string
+	AuthorityKeyID string
+	MspID          string
+	// Attributes are issued by Fabric CA, e.g., hf.EnrollmentID, role and email
+	Attributes map[string]string
+}
+
+// ParseCertificate returns details of a PEM encoded certificate of an MSP
+func ParseCertificate(certPEM []byte, mspID string) (*CertificateDetails, error) {
+	block, rest := pem.Decode(certPEM)
+	if block == nil || len(bytes.TrimSpace(rest)) > 0 {
+		return nil, errors.New("Certificate is not a single PEM block")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to parse x509 certificate")
+	}
+	details := &CertificateDetails{
+		Subject:             cert.Subject.String(),
+		Issuer:              cert.Issuer.String(),
+		CommonName:          cert.Subject.CommonName,
+		OrganizationalUnits: cert.Subject.OrganizationalUnit,
+		SerialNumber:        cert.SerialNumber.Text(16),
+		NotBefore:           cert.NotBefore,
+		NotAfter:            cert.NotAfter,
+		KeyAlgorithm:        keyAlgorithm(cert),
+		SignatureAlgorithm:  cert.SignatureAlgorithm.String(),
+		SubjectKeyID:        hex.EncodeToString(cert.SubjectKeyId),
+		AuthorityKeyID:      hex.EncodeToString(cert.AuthorityKeyId),
+		MspID:               mspID,
+		Attributes:          make(map[string]string),
+	}
+	details.SANs = append(details.SANs, cert.DNSNames...)
+	details.SANs = append(details.SANs, cert.EmailAddresses...)
+	for _, ip := range cert.IPAddresses {
+		details.SANs = append(details.SANs, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		details.SANs = append(details.SANs, uri.String())
+	}
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(attrOID) {
+			// Fabric CA attributes are JSON of format {"attrs":{"name":"value"}}
+			attrs := &struct {
+				Attrs map[string]string `json:"attrs"`
+			}{}
+			if err := json.Unmarshal(ext.Value, attrs); err != nil {
+				return nil, errors.Wrapf(err, "Failed to decode Fabric CA attributes")
+			}
+			for k, v := range attrs.Attrs {
+				details.Attributes[k] = v
+			}
+		}
+	}
+	return details, nil
+}
+
+// ToMap converts certificate details to a map, with times in RFC3339 format
+func (d *CertificateDetails) ToMap() map[string]interface{} {
+	attrs := make(map[string]interface{})
+	for k, v := range d.Attributes {
+		attrs[k] = v
+	}
+	return map[string]interface{}{
+		"subject":            d.Subject,
+		"issuer":             d.Issuer,
+		"cn":                 d.CommonName,
+		"ou":                 toInterfaces(d.OrganizationalUnits),
+		"serialNumber":       d.SerialNumber,
+		"notBefore":          d.NotBefore.UTC().Format(time.RFC3339),
+		"notAfter":           d.NotAfter.UTC().Format(time.RFC3339),
+		"keyAlgorithm":       d.KeyAlgorithm,
+		"signatureAlgorithm": d.SignatureAlgorithm,
+		"sans":               toInterfaces(d.SANs),
+		"subjectKeyID":       d.SubjectKeyID,
+		"authorityKeyID":     d.AuthorityKeyID,
+		"mspid":              d.MspID,
+		"attributes":         attrs,
+	}
+}
+
+// keyAlgorithm returns the public key algorithm and its size or curve, e.g., ECDSA P-256 or RSA 2048
+func keyAlgorithm(cert *x509.Certificate) string {
+	switch key := cert.PublicKey.(type) {
+	case *ecdsa.PublicKey:
+		return "ECDSA " + key.Curve.Params().Name
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA %d", key.N.BitLen())
+	case ed25519.PublicKey:
+		return "Ed25519"
+	}
+	return cert.PublicKeyAlgorithm.String()
+}
+
+func toInterfaces(values []string) []interface{} {
+	result := []interface{}{}
+	for _, v := range values {
+		result = append(result, v)
+	}
+	return result
+}
@@ -0,0 +1,65 @@
+/*
+SPDX-License-Identifier: BSD-3-Clause-Open-MPI
+*/
+
+package signcert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCertificate(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err, "generate key should not throw error")
+	notBefore := time.Date(2021, 3, 1, 18, 20, 0, 0, time.UTC)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(0x1f2e),
+		Subject: pkix.Name{
+			CommonName:         "alice",
+			OrganizationalUnit: []string{"client", "org1"},
+		},
+		NotBefore:      notBefore,
+		NotAfter:       notBefore.AddDate(1, 0, 0),
+		DNSNames:       []string{"alice.org1.example.com"},
+		EmailAddresses: []string{"alice@example.com"},
+		SubjectKeyId:   []byte{1, 2, 3, 4},
+		ExtraExtensions: []pkix.Extension{{
+			Id:    attrOID,
+			Value: []byte(`{"attrs":{"hf.EnrollmentID":"alice","role":"broker","email":"alice@example.com"}}`),
+		}},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err, "create certificate should not throw error")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+
+	details, err := ParseCertificate(certPEM, "Org1MSP")
+	require.NoError(t, err, "parse certificate should not throw error")
+	assert.Equal(t, "alice", details.CommonName, "common name should be alice")
+	assert.ElementsMatch(t, []string{"client", "org1"}, details.OrganizationalUnits, "certificate should have 2 OUs")
+	assert.Equal(t, "1f2e", details.SerialNumber, "serial number should be hex encoded")
+	assert.Equal(t, "ECDSA P-256", details.KeyAlgorithm, "key algorithm should be ECDSA P-256")
+	assert.Equal(t, "01020304", details.SubjectKeyID, "subject key ID should be hex encoded")
+	assert.Equal(t, []string{"alice.org1.example.com", "alice@example.com"}, details.SANs, "SANs should include DNS name and email")
+
+	result := details.ToMap()
+	assert.Equal(t, "Org1MSP", result["mspid"], "result should contain MSP ID")
+	assert.Equal(t, "2021-03-01T18:20:00Z", result["notBefore"], "notBefore should be in RFC3339 format")
+	assert.Equal(t, "2022-03-01T18:20:00Z", result["notAfter"], "notAfter should be in RFC3339 format")
+	attrs := result["attributes"].(map[string]interface{})
+	assert.Equal(t, "broker", attrs["role"], "role attribute should be decoded")
+	assert.Equal(t, "alice@example.com", attrs["email"], "email attribute should be decoded")
+
+	_, err = ParseCertificate([]byte("not a certificate"), "Org1MSP")
+	assert.Error(t, err, "invalid PEM should throw error")
+}
@@ -10,9 +10,12 @@ replace go.uber.org/multierr => go.uber.org/multierr v1.6.0
 
 replace github.com/grantae/certinfo => github.com/yxuco/certinfo v0.0.1
 
+replace github.com/open-dovetail/fabric-client/activity/request => ../request
+
 require (
 	github.com/grantae/certinfo v0.0.0-00010101000000-000000000000
 	github.com/hyperledger/fabric-sdk-go v1.0.0
+	github.com/open-dovetail/fabric-client/activity/request v0.0.1
 	github.com/pkg/errors v0.9.1
 	github.com/project-flogo/core v1.2.0
 	github.com/stretchr/testify v1.6.1
@@ -13,12 +13,38 @@ import (
 
 // Settings of the activity
 type Settings struct {
+	ConnectionName   string `md:"connectionName"`
+	Operation        string `md:"operation"`
+	Wallet           string `md:"wallet"`
+	WalletPassphrase string `md:"walletPassphrase"`
+	Verify           bool   `md:"verify"`
+}
+
+// FromMap sets activity settings from a map
+func (h *Settings) FromMap(values map[string]interface{}) error {
+	var err error
+	if h.ConnectionName, err = coerce.ToString(values["connectionName"]); err != nil {
+		return err
+	}
+	if h.Operation, err = coerce.ToString(values["operation"]); err != nil {
+		return err
+	}
+	if h.Wallet, err = coerce.ToString(values["wallet"]); err != nil {
+		return err
+	}
+	if h.WalletPassphrase, err = coerce.ToString(values["walletPassphrase"]); err != nil {
+		return err
+	}
+	if h.Verify, err = coerce.ToBool(values["verify"]); err != nil {
+		return err
+	}
+	return nil
 }
 
 // Input of the activity
 type Input struct {
 	OrgName  string `md:"orgName"`
-	UserName string `md:"userName,required"`
+	UserName string `md:"userName"`
 }
 
 // Output of the activity
@@ -26,6 +52,10 @@ type Output struct {
 	Code    int         `md:"code"`
 	Message string      `md:"message"`
 	Result  interface{} `md:"result"`
+	// Details of the certificate as a JSON object
+	Details map[string]interface{} `md:"details"`
+	// Verification result of the certificate against the org MSP if verify is configured
+	Verification map[string]interface{} `md:"verification"`
 }
 
 // ToMap converts activity input to a map
@@ -55,6 +85,12 @@ func (i *Input) FromMap(values map[string]interface{}) error {
 	if len(tokens) > 1 {
 		i.OrgName = strings.TrimSpace(tokens[1])
 	}
+	if len(i.OrgName) == 0 {
+		if i.OrgName, err = coerce.ToString(values["orgName"]); err != nil {
+			return err
+		}
+		i.OrgName = strings.TrimSpace(i.OrgName)
+	}
 
 	return nil
 }
@@ -62,9 +98,11 @@ func (i *Input) FromMap(values map[string]interface{}) error {
 // ToMap converts activity output to a map
 func (o *Output) ToMap() map[string]interface{} {
 	return map[string]interface{}{
-		"code":    o.Code,
-		"message": o.Message,
-		"result":  o.Result,
+		"code":         o.Code,
+		"message":      o.Message,
+		"result":       o.Result,
+		"details":      o.Details,
+		"verification": o.Verification,
 	}
 }
 
@@ -81,6 +119,12 @@ func (o *Output) FromMap(values map[string]interface{}) error {
 	if o.Result, err = coerce.ToAny(values["result"]); err != nil {
 		return err
 	}
+	if o.Details, err = coerce.ToObject(values["details"]); err != nil {
+		return err
+	}
+	if o.Verification, err = coerce.ToObject(values["verification"]); err != nil {
+		return err
+	}
 
 	return nil
 }
@@ -0,0 +1,196 @@
+/*
+SPDX-License-Identifier: BSD-3-Clause-Open-MPI
+*/
+
+package signcert
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/open-dovetail/fabric-client/activity/request"
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// UserInfo describes an identity that the app can act as
+type UserInfo struct {
+	Name          string
+	Org           string
+	MspID         string
+	Subject       string
+	NotAfter      time.Time
+	HasPrivateKey bool
+	// Fingerprint is the hex encoded SHA-256 hash of the DER encoded certificate
+	Fingerprint string
+}
+
+// ToMap converts user info to a map, with cert expiry in RFC3339 format
+func (u *UserInfo) ToMap() map[string]interface{} {
+	return map[string]interface{}{
+		"name":          u.Name,
+		"org":           u.Org,
+		"mspid":         u.MspID,
+		"notAfter":      u.NotAfter.UTC().Format(time.RFC3339),
+		"expired":       time.Now().After(u.NotAfter),
+		"hasPrivateKey": u.HasPrivateKey,
+	}
+}
+
+// NetworkUsers returns users found in the cryptoPath of orgs in a network config, sorted by org and name.
+// If org is specified, only users of the org are returned. Users without a certificate are skipped.
+func NetworkUsers(networkConfig []byte, org string) ([]*UserInfo, error) {
+	if len(networkConfig) == 0 {
+		return nil, errors.New("Network config is not specified")
+	}
+	var data map[interface{}]interface{}
+	if err := yaml.Unmarshal(networkConfig, &data); err != nil {
+		return nil, errors.Wrapf(err, "Failed to parse network config")
+	}
+	cryptoPath, _ := execYamlPath(data, "client.cryptoconfig.path").(string)
+	orgs, _ := data["organizations"].(map[interface{}]interface{})
+	if len(org) > 0 {
+		if _, ok := orgs[org]; !ok {
+			return nil, errors.Errorf("Org %s is not found in network config", org)
+		}
+	}
+
+	var orgNames []string
+	for k := range orgs {
+		if name, ok := k.(string); ok && (len(org) == 0 || name == org) {
+			orgNames = append(orgNames, name)
+		}
+	}
+	sort.Strings(orgNames)
+
+	var result []*UserInfo
+	for _, name := range orgNames {
+		mspPath, ok := orgCryptoPath(orgs[name], cryptoPath)
+		if !ok {
+			logger.Debugf("no cryptoPath is configured for org %s", name)
+			continue
+		}
+		users, err := usersInCryptoPath(mspPath)
+		if err != nil {
+			return nil, err
+		}
+		for _, u := range users {
+			identity, err := NetworkUserIdentity(networkConfig, u+"@"+name)
+			if err != nil {
+				logger.Debugf("skip user %s@%s: %v", u, name, err)
+				continue
+			}
+			details, err := ParseCertificate([]byte(identity.Certificate), identity.MspID)
+			if err != nil {
+				logger.Debugf("skip user %s@%s: %v", u, name, err)
+				continue
+			}
+			result = append(result, &UserInfo{
+				Name:          u,
+				Org:           name,
+				MspID:         identity.MspID,
+				Subject:       details.Subject,
+				NotAfter:      details.NotAfter,
+				HasPrivateKey: len(identity.PrivateKey) > 0,
+				Fingerprint:   certFingerprint([]byte(identity.Certificate)),
+			})
+		}
+	}
+	return result, nil
+}
+
+// WalletUsers returns identities in a wallet, sorted by label
+func WalletUsers(wallet request.Wallet) ([]*UserInfo, error) {
+	labels, err := wallet.List()
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to list identities in wallet")
+	}
+	var result []*UserInfo
+	for _, label := range labels {
+		identity, err := wallet.Get(label)
+		if err != nil {
+			logger.Debugf("skip identity %s: %v", label, err)
+			continue
+		}
+		details, err := ParseCertificate([]byte(identity.Certificate), identity.MspID)
+		if err != nil {
+			logger.Debugf("skip identity %s: %v", label, err)
+			continue
+		}
+		result = append(result, &UserInfo{
+			Name:          label,
+			MspID:         identity.MspID,
+			Subject:       details.Subject,
+			NotAfter:      details.NotAfter,
+			HasPrivateKey: len(identity.PrivateKey) > 0,
+			Fingerprint:   certFingerprint([]byte(identity.Certificate)),
+		})
+	}
+	return result, nil
+}
+
+// certFingerprint returns the hex encoded SHA-256 hash of the DER bytes of a PEM encoded certificate
+func certFingerprint(certPEM []byte) string {
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return ""
+	}
+	hash := sha256.Sum256(block.Bytes)
+	return hex.EncodeToString(hash[:])
+}
+
+// usersInCryptoPath returns user names matching the {username} segment of a cryptoPath template,
+// e.g., Admin and User1 for .../users/{username}@org1.example.com/msp
+func usersInCryptoPath(pathTemplate string) ([]string, error) {
+	path := filepath.ToSlash(pathTemplate)
+	pos := -1
+	placeholder := ""
+	for _, p := range []string{"{username}", "{userName}"} {
+		if pos = strings.Index(path, p); pos >= 0 {
+			placeholder = p
+			break
+		}
+	}
+	if pos < 0 {
+		logger.Debugf("cryptoPath %s does not contain {username}", pathTemplate)
+		return nil, nil
+	}
+
+	// split the path segment of the placeholder into prefix and suffix of user folder names
+	dir := ""
+	prefix := path[:pos]
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		dir, prefix = prefix[:i+1], prefix[i+1:]
+	}
+	suffix := path[pos+len(placeholder):]
+	if i := strings.Index(suffix, "/"); i >= 0 {
+		suffix = suffix[:i]
+	}
+	if len(dir) == 0 {
+		dir = "."
+	}
+
+	files, err := ioutil.ReadDir(filepath.FromSlash(dir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			logger.Debugf("user folder %s does not exist", dir)
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "Failed to read user folder %s", dir)
+	}
+	var result []string
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() && len(name) > len(prefix)+len(suffix) && strings.HasPrefix(name, prefix) && strings.HasSuffix(name, suffix) {
+			result = append(result, name[len(prefix):len(name)-len(suffix)])
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,277 @@
+/*
+SPDX-License-Identifier: BSD-3-Clause-Open-MPI
+*/
+
+package signcert
+
+import (
+	"bytes"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// default OU identifiers of Fabric node OU classification
+var defaultNodeOUs = map[string]string{
+	"client":  "client",
+	"peer":    "peer",
+	"admin":   "admin",
+	"orderer": "orderer",
+}
+
+// mspConfig is the NodeOUs section of an MSP config.yaml
+type mspConfig struct {
+	NodeOUs struct {
+		Enable              bool         `yaml:"Enable"`
+		ClientOUIdentifier  ouIdentifier `yaml:"ClientOUIdentifier"`
+		PeerOUIdentifier    ouIdentifier `yaml:"PeerOUIdentifier"`
+		AdminOUIdentifier   ouIdentifier `yaml:"AdminOUIdentifier"`
+		OrdererOUIdentifier ouIdentifier `yaml:"OrdererOUIdentifier"`
+	} `yaml:"NodeOUs"`
+}
+
+type ouIdentifier struct {
+	Certificate                  string `yaml:"Certificate"`
+	OrganizationalUnitIdentifier string `yaml:"OrganizationalUnitIdentifier"`
+}
+
+// Verification is the result of verifying a certificate against an org MSP
+type Verification struct {
+	Valid bool
+	// NodeOU is the node OU classification of the certificate, i.e., client, peer, admin, or orderer
+	NodeOU  string
+	Reasons []string
+}
+
+// ToMap converts verification result to a map
+func (v *Verification) ToMap() map[string]interface{} {
+	return map[string]interface{}{
+		"valid":   v.Valid,
+		"nodeOU":  v.NodeOU,
+		"reasons": toInterfaces(v.Reasons),
+	}
+}
+
+func (v *Verification) fail(format string, args ...interface{}) {
+	v.Valid = false
+	v.Reasons = append(v.Reasons, fmt.Sprintf(format, args...))
+}
+
+// VerifyCertificate verifies a PEM encoded certificate against the cacerts, intermediatecerts, crls and config.yaml of an MSP folder.
+// It checks the trust chain, validity window, key usage, node OU classification and revocation of the certificate.
+func VerifyCertificate(certPEM []byte, mspDir string) (*Verification, error) {
+	block, rest := pem.Decode(certPEM)
+	if block == nil || len(bytes.TrimSpace(rest)) > 0 {
+		return nil, errors.New("Certificate is not a single PEM block")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to parse x509 certificate")
+	}
+	roots, err := readCertificates(filepath.Join(mspDir, "cacerts"))
+	if err != nil {
+		return nil, err
+	}
+	if len(roots) == 0 {
+		return nil, errors.Errorf("No CA certificate found in MSP folder %s", mspDir)
+	}
+	intermediates, err := readCertificates(filepath.Join(mspDir, "intermediatecerts"))
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Verification{Valid: true}
+	now := time.Now()
+	if now.Before(cert.NotBefore) {
+		result.fail("certificate is not valid before %s", cert.NotBefore.UTC().Format(time.RFC3339))
+	}
+	if now.After(cert.NotAfter) {
+		result.fail("certificate expired at %s", cert.NotAfter.UTC().Format(time.RFC3339))
+	}
+
+	// verify trust chain within the validity window of the certificate, so expiry is reported only once
+	opts := x509.VerifyOptions{
+		Roots:         x509.NewCertPool(),
+		Intermediates: x509.NewCertPool(),
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+	if opts.CurrentTime.Before(cert.NotBefore) {
+		opts.CurrentTime = cert.NotBefore
+	} else if opts.CurrentTime.After(cert.NotAfter) {
+		opts.CurrentTime = cert.NotAfter
+	}
+	for _, c := range roots {
+		opts.Roots.AddCert(c)
+	}
+	for _, c := range intermediates {
+		opts.Intermediates.AddCert(c)
+	}
+	var issuer *x509.Certificate
+	if chains, err := cert.Verify(opts); err != nil {
+		result.fail("certificate is not issued by a CA of the MSP: %v", err)
+	} else if len(chains[0]) > 1 {
+		issuer = chains[0][1]
+	}
+
+	if cert.IsCA {
+		result.fail("certificate is a CA certificate")
+	}
+	if cert.KeyUsage != 0 && cert.KeyUsage&x509.KeyUsageDigitalSignature == 0 {
+		result.fail("certificate key usage does not include digital signature")
+	}
+
+	if err := verifyNodeOU(cert, mspDir, result); err != nil {
+		return nil, err
+	}
+	if err := verifyRevocation(cert, issuer, filepath.Join(mspDir, "crls"), result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// verifyNodeOU checks that the certificate has exactly one node OU of the MSP config.yaml, or of the default OUs if NodeOUs is not configured
+func verifyNodeOU(cert *x509.Certificate, mspDir string, result *Verification) error {
+	nodeOUs, err := readNodeOUs(filepath.Join(mspDir, "config.yaml"))
+	if err != nil {
+		return err
+	}
+	var matched []string
+	for role, ou := range nodeOUs {
+		for _, v := range cert.Subject.OrganizationalUnit {
+			if v == ou {
+				matched = append(matched, role)
+				break
+			}
+		}
+	}
+	sort.Strings(matched)
+	switch len(matched) {
+	case 0:
+		result.fail("certificate does not have a node OU of client, peer, admin, or orderer")
+	case 1:
+		result.NodeOU = matched[0]
+	default:
+		result.fail("certificate has multiple node OUs: %s", strings.Join(matched, ", "))
+	}
+	return nil
+}
+
+// readNodeOUs returns OU identifiers by node role from an MSP config.yaml
+func readNodeOUs(configFile string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(configFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return defaultNodeOUs, nil
+		}
+		return nil, errors.Wrapf(err, "Failed to read MSP config %s", configFile)
+	}
+	config := &mspConfig{}
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, errors.Wrapf(err, "Failed to parse MSP config %s", configFile)
+	}
+	if !config.NodeOUs.Enable {
+		return defaultNodeOUs, nil
+	}
+	result := make(map[string]string)
+	for role, ou := range map[string]string{
+		"client":  config.NodeOUs.ClientOUIdentifier.OrganizationalUnitIdentifier,
+		"peer":    config.NodeOUs.PeerOUIdentifier.OrganizationalUnitIdentifier,
+		"admin":   config.NodeOUs.AdminOUIdentifier.OrganizationalUnitIdentifier,
+		"orderer": config.NodeOUs.OrdererOUIdentifier.OrganizationalUnitIdentifier,
+	} {
+		if len(ou) > 0 {
+			result[role] = ou
+		}
+	}
+	return result, nil
+}
+
+// verifyRevocation checks the certificate against CRLs in the crls folder of an MSP that are signed by the issuer of the certificate
+func verifyRevocation(cert, issuer *x509.Certificate, crlDir string, result *Verification) error {
+	files, err := ioutil.ReadDir(crlDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errors.Wrapf(err, "Failed to read CRL folder %s", crlDir)
+	}
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(crlDir, f.Name()))
+		if err != nil {
+			return errors.Wrapf(err, "Failed to read CRL file %s", f.Name())
+		}
+		crl, err := x509.ParseCRL(data)
+		if err != nil {
+			logger.Warnf("ignore invalid CRL file %s: %v", f.Name(), err)
+			continue
+		}
+		if crlIssuer, err := asn1.Marshal(crl.TBSCertList.Issuer); err != nil || !bytes.Equal(crlIssuer, cert.RawIssuer) {
+			continue
+		}
+		if issuer != nil && issuer.CheckCRLSignature(crl) != nil {
+			logger.Warnf("ignore CRL file %s that is not signed by the certificate issuer", f.Name())
+			continue
+		}
+		if revoked := findRevoked(crl.TBSCertList.RevokedCertificates, cert); revoked != nil {
+			result.fail("certificate is revoked at %s", revoked.RevocationTime.UTC().Format(time.RFC3339))
+			return nil
+		}
+	}
+	return nil
+}
+
+func findRevoked(revoked []pkix.RevokedCertificate, cert *x509.Certificate) *pkix.RevokedCertificate {
+	for i, r := range revoked {
+		if r.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+			return &revoked[i]
+		}
+	}
+	return nil
+}
+
+// readCertificates returns PEM encoded certificates in files of a folder, or nil if the folder does not exist
+func readCertificates(dir string) ([]*x509.Certificate, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "Failed to read certificate folder %s", dir)
+	}
+	var result []*x509.Certificate
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to read certificate file %s", f.Name())
+		}
+		for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+			if block.Type != "CERTIFICATE" {
+				continue
+			}
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, errors.Wrapf(err, "Failed to parse certificate file %s", f.Name())
+			}
+			result = append(result, cert)
+		}
+	}
+	return result, nil
+}
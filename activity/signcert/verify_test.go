@@ -0,0 +1,123 @@
+/*
+SPDX-License-Identifier: BSD-3-Clause-Open-MPI
+*/
+
+package signcert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testMSPConfig = `NodeOUs:
+  Enable: true
+  ClientOUIdentifier:
+    Certificate: cacerts/ca.pem
+    OrganizationalUnitIdentifier: client
+  PeerOUIdentifier:
+    Certificate: cacerts/ca.pem
+    OrganizationalUnitIdentifier: peer
+  AdminOUIdentifier:
+    Certificate: cacerts/ca.pem
+    OrganizationalUnitIdentifier: admin
+  OrdererOUIdentifier:
+    Certificate: cacerts/ca.pem
+    OrganizationalUnitIdentifier: orderer
+`
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err, "generate CA key should not throw error")
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err, "create CA certificate should not throw error")
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err, "parse CA certificate should not throw error")
+	return &testCA{cert: cert, key: key}
+}
+
+// issue returns a PEM encoded certificate signed by the CA
+func (ca *testCA) issue(t *testing.T, serial int64, ou string, notAfter time.Time) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err, "generate key should not throw error")
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "user", OrganizationalUnit: []string{ou}},
+		NotBefore:    time.Now().Add(-2 * time.Hour),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err, "create certificate should not throw error")
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func writeTestFile(t *testing.T, path string, data []byte) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755), "create folder should not throw error")
+	require.NoError(t, ioutil.WriteFile(path, data, 0644), "write file should not throw error")
+}
+
+func TestVerifyCertificate(t *testing.T) {
+	mspDir, err := ioutil.TempDir("", "msp")
+	require.NoError(t, err, "create temp folder should not throw error")
+	defer os.RemoveAll(mspDir)
+
+	ca := newTestCA(t, "ca.org1.example.com")
+	writeTestFile(t, filepath.Join(mspDir, "cacerts", "ca.pem"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw}))
+	writeTestFile(t, filepath.Join(mspDir, "config.yaml"), []byte(testMSPConfig))
+	crl, err := ca.cert.CreateCRL(rand.Reader, ca.key, []pkix.RevokedCertificate{
+		{SerialNumber: big.NewInt(3), RevocationTime: time.Now().Add(-time.Minute)},
+	}, time.Now(), time.Now().Add(time.Hour))
+	require.NoError(t, err, "create CRL should not throw error")
+	writeTestFile(t, filepath.Join(mspDir, "crls", "crl.pem"), pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: crl}))
+
+	result, err := VerifyCertificate(ca.issue(t, 2, "client", time.Now().AddDate(0, 1, 0)), mspDir)
+	require.NoError(t, err, "verify certificate should not throw error")
+	assert.True(t, result.Valid, "certificate issued by the MSP CA should be valid")
+	assert.Equal(t, "client", result.NodeOU, "certificate should be classified as client")
+	assert.Empty(t, result.Reasons, "valid certificate should not have failure reasons")
+
+	result, err = VerifyCertificate(ca.issue(t, 3, "client", time.Now().AddDate(0, 1, 0)), mspDir)
+	require.NoError(t, err, "verify revoked certificate should not throw error")
+	assert.False(t, result.Valid, "revoked certificate should not be valid")
+	assert.Contains(t, result.Reasons[0], "revoked", "reason should report revocation")
+
+	result, err = VerifyCertificate(ca.issue(t, 4, "member", time.Now().Add(-time.Hour)), mspDir)
+	require.NoError(t, err, "verify expired certificate should not throw error")
+	assert.False(t, result.Valid, "expired certificate should not be valid")
+	assert.Len(t, result.Reasons, 2, "expired certificate without node OU should report 2 reasons")
+	assert.Contains(t, result.Reasons[0], "expired", "reason should report expiry")
+
+	other := newTestCA(t, "ca.org2.example.com")
+	result, err = VerifyCertificate(other.issue(t, 5, "peer", time.Now().AddDate(0, 1, 0)), mspDir)
+	require.NoError(t, err, "verify certificate of another CA should not throw error")
+	assert.False(t, result.Valid, "certificate of another CA should not be valid")
+	assert.Equal(t, "peer", result.NodeOU, "certificate should be classified as peer")
+	assert.Contains(t, result.ToMap()["reasons"].([]interface{})[0], "not issued by a CA", "reason should report untrusted issuer")
+}
@@ -27,6 +27,8 @@ import (
 )
 
 var enterprise bool
+var async bool
+var callerIdentity bool
 var contractFile string
 var restRoot string
 var appFile string
@@ -36,6 +38,8 @@ func init() {
 	contract2rest.Flags().StringVarP(&restRoot, "name", "n", "", "specify the root path of REST APIs")
 	contract2rest.Flags().StringVarP(&appFile, "app", "o", "app.json", "specify the output file app.json")
 	contract2rest.Flags().BoolVarP(&enterprise, "fe", "e", false, "user Flogo Enterprise")
+	contract2rest.Flags().BoolVarP(&async, "async", "a", false, "submit transactions without waiting for commit, and return the transaction ID")
+	contract2rest.Flags().BoolVarP(&callerIdentity, "identity", "i", false, "sign requests by the caller's certificate and private key in HTTP headers, instead of the HTTP user")
 	common.RegisterPlugin(contract2rest)
 }
 
@@ -199,8 +203,14 @@ func createRESTHandler(tx *contract.Transaction, path string, fe bool) *trigger.
 	res := "res://flow:" + contract.ToSnakeCase(tx.Name)
 	// map all parameters as a single object
 
-	input := map[string]interface{}{
-		"user": "=dovetail.httpUser($.headers)",
+	input := map[string]interface{}{}
+	if callerIdentity {
+		// base64 encoded PEM certificate and private key of the caller
+		input["certificate"] = `=$.headers["X-Fabric-Certificate"]`
+		input["privateKey"] = `=$.headers["X-Fabric-Private-Key"]`
+		input["mspID"] = `=$.headers["X-Fabric-Msp-Id"]`
+	} else {
+		input["user"] = "=dovetail.httpUser($.headers)"
 	}
 	if len(tx.Parameters) > 0 {
 		input["parameters"] = "=$.content"
@@ -279,6 +289,9 @@ func createHandlerSchema(tx *contract.Transaction) *trigger.SchemaConfig {
 					"type": jschema.TYPE_STRING,
 				},
 				"result": tx.Returns,
+				"transactionID": map[string]interface{}{
+					"type": jschema.TYPE_STRING,
+				},
 			},
 		}
 		if _, err := contract.ExpandRef(rs); err == nil {
@@ -332,8 +345,13 @@ func createHandlerSchema(tx *contract.Transaction) *trigger.SchemaConfig {
 func createResource(tx *contract.Transaction, schm *trigger.SchemaConfig) (string, *definition.DefinitionRep, error) {
 	id := "flow:" + contract.ToSnakeCase(tx.Name)
 
-	input := map[string]data.TypedValue{
-		"user": data.NewAttribute("user", data.TypeString, nil),
+	input := map[string]data.TypedValue{}
+	if callerIdentity {
+		for _, name := range []string{"certificate", "privateKey", "mspID"} {
+			input[name] = data.NewAttribute(name, data.TypeString, nil)
+		}
+	} else {
+		input["user"] = data.NewAttribute("user", data.TypeString, nil)
 	}
 	if len(tx.Parameters) > 0 {
 		input["parameters"] = data.NewAttribute("parameters", data.TypeObject, nil)
@@ -385,6 +403,11 @@ func createResource(tx *contract.Transaction, schm *trigger.SchemaConfig) (strin
 	}
 	res.Links = append(res.Links, link)
 
+	// reply with the status code and error type of failed Fabric request
+	res.ErrorHandler = &definition.ErrorHandlerRep{
+		Tasks: []*definition.TaskRep{errorReturnTask()},
+	}
+
 	return id, res, nil
 }
 
@@ -397,6 +420,8 @@ func fabricRequestTask(tx *contract.Transaction, includeSchema bool) *definition
 	reqType := "invoke"
 	if isReadOnly(tx) {
 		reqType = "query"
+	} else if async {
+		reqType = "submit"
 	}
 	actCfg.Settings = map[string]interface{}{
 		"chaincodeID":     `=$property["CHAINCODE"]`,
@@ -407,8 +432,16 @@ func fabricRequestTask(tx *contract.Transaction, includeSchema bool) *definition
 		"transactionName": tx.Name,
 	}
 
-	actCfg.Input = map[string]interface{}{
-		"userName": "=$flow.user",
+	if callerIdentity {
+		actCfg.Input = map[string]interface{}{
+			"certificate": "=$flow.certificate",
+			"privateKey":  "=$flow.privateKey",
+			"mspID":       "=$flow.mspID",
+		}
+	} else {
+		actCfg.Input = map[string]interface{}{
+			"userName": "=$flow.user",
+		}
 	}
 	schemaInput := make(map[string]interface{})
 	if len(tx.Parameters) > 0 {
@@ -480,8 +513,9 @@ func returnTask() *definition.TaskRep {
 			"code": "=$activity[request_1].code",
 			"data": map[string]interface{}{
 				"mapping": map[string]interface{}{
-					"message": "=$activity[request_1].message",
-					"result":  "=$activity[request_1].result",
+					"message":       "=$activity[request_1].message",
+					"result":        "=$activity[request_1].result",
+					"transactionID": "=$activity[request_1].transactionID",
 				},
 			},
 		},
@@ -493,3 +527,28 @@ func returnTask() *definition.TaskRep {
 		ActivityCfgRep: actCfg,
 	}
 }
+
+// create return task resource of error handler, which maps the output of failed request from error data
+func errorReturnTask() *definition.TaskRep {
+	actCfg := &activity.Config{
+		Ref: "#actreturn",
+	}
+	actCfg.Settings = map[string]interface{}{
+		"mappings": map[string]interface{}{
+			"code": "=$error.data.code",
+			"data": map[string]interface{}{
+				"mapping": map[string]interface{}{
+					"message":       "=$error.data.message",
+					"errorType":     "=$error.data.errorType",
+					"transactionID": "=$error.data.transactionID",
+				},
+			},
+		},
+	}
+
+	return &definition.TaskRep{
+		ID:             "actreturn_error",
+		Name:           "ReturnError",
+		ActivityCfgRep: actCfg,
+	}
+}
@@ -13,16 +13,26 @@ import (
 	"text/template"
 
 	"github.com/open-dovetail/fabric-client/activity/request"
+	"github.com/pkg/errors"
 	"github.com/project-flogo/cli/common" // Flogo CLI support code
 	"github.com/spf13/cobra"
 )
 
 var configFile string
 var matcherFile string
+var networkFlags []string
+
+// namedNetwork is the network config and entity matchers embedded for a connection name
+type namedNetwork struct {
+	Name    string
+	Config  string
+	Matcher string
+}
 
 func init() {
 	configfabric.Flags().StringVarP(&configFile, "config", "c", "config.yaml", "specify the yaml file for Fabric network configuration")
 	configfabric.Flags().StringVarP(&matcherFile, "matchers", "m", "", "specify the yaml file for entity matchers override")
+	configfabric.Flags().StringArrayVarP(&networkFlags, "network", "n", nil, "specify a named Fabric network as name=config.yaml[,matchers.yaml]; repeat for multiple networks")
 	common.RegisterPlugin(configfabric)
 }
 
@@ -31,35 +41,79 @@ var configfabric = &cobra.Command{
 	Short: "embed fabric network config",
 	Long:  "This plugin generates embedded network config for Fabric client apps",
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("Embed network config file", configFile)
-		networkConfig, err := request.ReadFile(configFile)
+		networks, err := readNetworks(networkFlags)
 		if err != nil {
-			fmt.Printf("Failed to read network config %s: %+v\n", configFile, err)
+			fmt.Printf("Failed to read named networks: %+v\n", err)
 			os.Exit(1)
 		}
-		var matchersConfig []byte
-		if len(matcherFile) > 0 {
-			if matchersConfig, err = request.ReadFile(matcherFile); err != nil {
-				fmt.Printf("Failed to read matchers config %s: %+v\n", matcherFile, err)
+		var networkConfig, matchersConfig []byte
+		if len(networks) == 0 || cmd.Flags().Changed("config") {
+			// default network is required only if no named network is specified
+			fmt.Println("Embed network config file", configFile)
+			if networkConfig, err = request.ReadFile(configFile); err != nil {
+				fmt.Printf("Failed to read network config %s: %+v\n", configFile, err)
+				os.Exit(1)
+			}
+			if len(matcherFile) > 0 {
+				if matchersConfig, err = request.ReadFile(matcherFile); err != nil {
+					fmt.Printf("Failed to read matchers config %s: %+v\n", matcherFile, err)
+				}
 			}
 		}
-		if err = createFabricGoFile(networkConfig, matchersConfig); err != nil {
+		if err = createFabricGoFile(networkConfig, matchersConfig, networks); err != nil {
 			os.Exit(1)
 		}
 	},
 }
 
-func createFabricGoFile(networkConfig, matcherConfig []byte) error {
+// readNetworks reads network config files specified in the format of name=config.yaml[,matchers.yaml]
+func readNetworks(specs []string) ([]*namedNetwork, error) {
+	var networks []*namedNetwork
+	names := make(map[string]bool)
+	for _, spec := range specs {
+		tokens := strings.SplitN(spec, "=", 2)
+		name := strings.TrimSpace(tokens[0])
+		if len(tokens) < 2 || len(name) == 0 {
+			return nil, errors.Errorf("Network %s is not in the format of name=config.yaml[,matchers.yaml]", spec)
+		}
+		if names[name] {
+			return nil, errors.Errorf("Network %s is specified more than once", name)
+		}
+		names[name] = true
+		files := strings.Split(tokens[1], ",")
+		configPath := strings.TrimSpace(files[0])
+		fmt.Printf("Embed network config file %s for %s\n", configPath, name)
+		config, err := request.ReadFile(configPath)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to read network config %s", configPath)
+		}
+		network := &namedNetwork{Name: name, Config: string(config)}
+		if len(files) > 1 && len(strings.TrimSpace(files[1])) > 0 {
+			matcherPath := strings.TrimSpace(files[1])
+			matcher, err := request.ReadFile(matcherPath)
+			if err != nil {
+				return nil, errors.Wrapf(err, "Failed to read matchers config %s", matcherPath)
+			}
+			network.Matcher = string(matcher)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+func createFabricGoFile(networkConfig, matcherConfig []byte, networks []*namedNetwork) error {
 	matcher := ""
 	if len(matcherConfig) > 0 {
 		matcher = string(matcherConfig)
 	}
 	data := struct {
-		Config  string
-		Matcher string
+		Config   string
+		Matcher  string
+		Networks []*namedNetwork
 	}{
 		string(networkConfig),
 		matcher,
+		networks,
 	}
 
 	embedSrcPath := filepath.Join("src", "fabric_network.go")
@@ -91,9 +145,16 @@ import (
 // embedded flogo app descriptor file
 const fabricConfig string = ` + "`{{.Config}}`" + `
 const fabricMatcher string = ` + "`{{.Matcher}}`" + `
-
+{{range $i, $n := .Networks}}
+// embedded network config for connection {{$n.Name}}
+const fabricConfig{{$i}} string = ` + "`{{$n.Config}}`" + `
+const fabricMatcher{{$i}} string = ` + "`{{$n.Matcher}}`" + `
+{{end}}
 func init () {
 	request.InitializeNetwork([]byte(fabricConfig), []byte(fabricMatcher))
 	signcert.InitializeNetwork([]byte(fabricConfig))
+{{- range $i, $n := .Networks}}
+	request.RegisterNetwork({{printf "%q" $n.Name}}, []byte(fabricConfig{{$i}}), []byte(fabricMatcher{{$i}}))
+{{- end}}
 }
 `
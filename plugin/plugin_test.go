@@ -22,6 +22,25 @@ func TestFabricGoFile(t *testing.T) {
 	assert.NoError(t, err, "read entity matchers file should not throw error")
 
 	os.Mkdir("src", 0755)
-	err = createFabricGoFile(networkConfig, matchersConfig)
+	err = createFabricGoFile(networkConfig, matchersConfig, nil)
 	assert.NoError(t, err, "read sample contract should not throw error")
 }
+
+func TestNamedNetworks(t *testing.T) {
+	networks, err := readNetworks([]string{
+		"org1net=../test-network/config.yaml,../test-network/local_entity_matchers.yaml",
+		"org2net=../test-network/config.yaml",
+	})
+	assert.NoError(t, err, "read named networks should not throw error")
+	assert.Equal(t, 2, len(networks), "should read 2 named networks")
+	assert.Equal(t, "org1net", networks[0].Name, "name of first network should be org1net")
+	assert.NotEmpty(t, networks[0].Matcher, "first network should have entity matchers")
+	assert.Empty(t, networks[1].Matcher, "second network should not have entity matchers")
+
+	_, err = readNetworks([]string{"../test-network/config.yaml"})
+	assert.Error(t, err, "network without name should throw error")
+
+	os.Mkdir("src", 0755)
+	err = createFabricGoFile(nil, nil, networks)
+	assert.NoError(t, err, "create go file of named networks should not throw error")
+}
@@ -0,0 +1,62 @@
+/*
+SPDX-License-Identifier: BSD-3-Clause-Open-MPI
+*/
+
+package blockevent
+
+import (
+	"github.com/project-flogo/core/data/coerce"
+)
+
+// Settings of the trigger
+type Settings struct {
+	ConnectionName string `md:"connectionName,required"`
+	UserName       string `md:"userName,required"`
+}
+
+// HandlerSettings of the trigger
+type HandlerSettings struct {
+	ChannelID  string `md:"channelID,required"`
+	BlockType  string `md:"blockType"`
+	StartBlock string `md:"startBlock"`
+}
+
+// Output of the trigger
+type Output struct {
+	ChannelID   string                 `md:"channelID"`
+	BlockNumber uint64                 `md:"blockNumber"`
+	Block       map[string]interface{} `md:"block"`
+	SourceURL   string                 `md:"sourceURL"`
+}
+
+// ToMap converts trigger output to a map
+func (o *Output) ToMap() map[string]interface{} {
+	return map[string]interface{}{
+		"channelID":   o.ChannelID,
+		"blockNumber": o.BlockNumber,
+		"block":       o.Block,
+		"sourceURL":   o.SourceURL,
+	}
+}
+
+// FromMap sets trigger output values from a map
+func (o *Output) FromMap(values map[string]interface{}) error {
+
+	var err error
+	if o.ChannelID, err = coerce.ToString(values["channelID"]); err != nil {
+		return err
+	}
+	blockNumber, err := coerce.ToInt64(values["blockNumber"])
+	if err != nil {
+		return err
+	}
+	o.BlockNumber = uint64(blockNumber)
+	if o.Block, err = coerce.ToObject(values["block"]); err != nil {
+		return err
+	}
+	if o.SourceURL, err = coerce.ToString(values["sourceURL"]); err != nil {
+		return err
+	}
+
+	return nil
+}
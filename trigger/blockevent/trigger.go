@@ -0,0 +1,314 @@
+/*
+SPDX-License-Identifier: BSD-3-Clause-Open-MPI
+*/
+
+package blockevent
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/event"
+	pcontext "github.com/hyperledger/fabric-sdk-go/pkg/common/providers/context"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fab/events/deliverclient/seek"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fabsdk"
+	"github.com/open-dovetail/fabric-client/activity/request"
+	"github.com/pkg/errors"
+	"github.com/project-flogo/core/data/metadata"
+	"github.com/project-flogo/core/support/log"
+	"github.com/project-flogo/core/trigger"
+)
+
+const (
+	blockFull     = "full"
+	blockFiltered = "filtered"
+)
+
+// Create a new logger
+var logger = log.ChildLogger(log.RootLogger(), "trigger-fabclient-blockevent")
+
+var triggerMd = trigger.NewMetadata(&Settings{}, &HandlerSettings{}, &Output{})
+
+func init() {
+	_ = trigger.Register(&Trigger{}, &Factory{})
+}
+
+// Factory creates block event triggers
+type Factory struct {
+}
+
+// New implements trigger.Factory.New
+func (f *Factory) New(config *trigger.Config) (trigger.Trigger, error) {
+	s := &Settings{}
+	if err := metadata.MapToStruct(config.Settings, s, true); err != nil {
+		return nil, err
+	}
+	return &Trigger{id: config.Id, settings: s}, nil
+}
+
+// Metadata implements trigger.Factory.Metadata
+func (f *Factory) Metadata() *trigger.Metadata {
+	return triggerMd
+}
+
+// Trigger listens to blocks committed on channels of a Fabric network
+type Trigger struct {
+	id        string
+	settings  *Settings
+	sdk       *fabsdk.FabricSDK
+	listeners []*listener
+	// lock serializes start, stop and restart of listeners when the network config is reloaded
+	lock       sync.Mutex
+	running    bool
+	reloadOnce sync.Once
+}
+
+// eventService is the part of event.Client used by the trigger to receive block events
+type eventService interface {
+	RegisterBlockEvent(filter ...fab.BlockFilter) (fab.Registration, <-chan *fab.BlockEvent, error)
+	RegisterFilteredBlockEvent() (fab.Registration, <-chan *fab.FilteredBlockEvent, error)
+	Unregister(reg fab.Registration)
+}
+
+// listener dispatches blocks of a channel to a trigger handler
+type listener struct {
+	handler  trigger.Handler
+	settings *HandlerSettings
+	// start position of the next event client, which is moved past processed blocks, so a restarted listener resumes from the next block
+	seekType seek.Type
+	blockNum uint64
+	service  eventService
+	reg      fab.Registration
+	done     chan bool
+}
+
+// Metadata implements trigger.Trigger.Metadata
+func (t *Trigger) Metadata() *trigger.Metadata {
+	return triggerMd
+}
+
+// Initialize implements trigger.Trigger.Initialize
+func (t *Trigger) Initialize(ctx trigger.InitContext) error {
+	for _, handler := range ctx.GetHandlers() {
+		s := &HandlerSettings{}
+		if err := metadata.MapToStruct(handler.Settings(), s, true); err != nil {
+			return err
+		}
+		l, err := newListener(handler, s)
+		if err != nil {
+			return err
+		}
+		logger.Infof("handler %s listens to %s blocks on channel %s from %s", handler.Name(), s.BlockType, s.ChannelID, s.StartBlock)
+		t.listeners = append(t.listeners, l)
+	}
+	return nil
+}
+
+// newListener validates handler settings, and returns a listener that is not started yet
+func newListener(handler trigger.Handler, s *HandlerSettings) (*listener, error) {
+	s.BlockType = strings.ToLower(strings.TrimSpace(s.BlockType))
+	if len(s.BlockType) == 0 {
+		s.BlockType = blockFull
+	}
+	if s.BlockType != blockFull && s.BlockType != blockFiltered {
+		return nil, errors.Errorf("Invalid blockType %s of handler %s: must be full or filtered", s.BlockType, handler.Name())
+	}
+
+	l := &listener{handler: handler, settings: s}
+	s.StartBlock = strings.ToLower(strings.TrimSpace(s.StartBlock))
+	switch s.StartBlock {
+	case "", seek.Newest:
+		s.StartBlock = seek.Newest
+		l.seekType = seek.Newest
+	case seek.Oldest:
+		l.seekType = seek.Oldest
+	default:
+		blockNum, err := strconv.ParseUint(s.StartBlock, 10, 64)
+		if err != nil {
+			return nil, errors.Errorf("Invalid startBlock %s of handler %s: must be oldest, newest, or a block number", s.StartBlock, handler.Name())
+		}
+		l.seekType = seek.FromBlock
+		l.blockNum = blockNum
+	}
+	return l, nil
+}
+
+// Start implements trigger.Trigger.Start
+func (t *Trigger) Start() error {
+	// load network config files specified by environment variables, if any
+	if err := request.ConfigureNetwork(t.settings.ConnectionName, "", ""); err != nil {
+		return err
+	}
+	t.reloadOnce.Do(func() {
+		request.OnNetworkReload(t.reload)
+	})
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.running = true
+	return t.startListeners()
+}
+
+// Stop implements trigger.Trigger.Stop
+func (t *Trigger) Stop() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.running = false
+	t.stopListeners()
+	return nil
+}
+
+// reload restarts the listeners on a new SDK after the network config of the connection is reloaded,
+// so they use the new endpoints and certificates, and resume from the block after the last processed block
+func (t *Trigger) reload(connectionName string) {
+	if connectionName != t.settings.ConnectionName {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if !t.running {
+		return
+	}
+	logger.Infof("restart trigger %s with reloaded network config of connection %s", t.id, connectionName)
+	t.stopListeners()
+	if err := t.startListeners(); err != nil {
+		logger.Errorf("failed to restart trigger %s with reloaded network config: %+v", t.id, err)
+	}
+}
+
+// startListeners creates an SDK of the current network config, and starts the listeners; the caller must hold the lock
+func (t *Trigger) startListeners() error {
+	sdk, err := request.NewSDK(request.NetworkConnector(t.settings.ConnectionName))
+	if err != nil {
+		return err
+	}
+	t.sdk = sdk
+
+	user, org := request.SplitUser(t.settings.UserName)
+	opts := []fabsdk.ContextOption{fabsdk.WithUser(user)}
+	if len(org) > 0 {
+		opts = append(opts, fabsdk.WithOrg(org))
+	}
+	for _, l := range t.listeners {
+		client, err := openEvents(l, sdk.ChannelContext(l.settings.ChannelID, opts...))
+		if err != nil {
+			return errors.Wrapf(err, "Failed to create event client for channel %s", l.settings.ChannelID)
+		}
+		if err := l.start(client); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stopListeners stops the listeners, and closes the SDK; the caller must hold the lock
+func (t *Trigger) stopListeners() {
+	for _, l := range t.listeners {
+		l.stop()
+	}
+	if t.sdk != nil {
+		t.sdk.Close()
+		t.sdk = nil
+	}
+}
+
+// openEvents returns the event service of a channel for a listener, which is replaced by tests
+var openEvents = func(l *listener, channelProvider pcontext.ChannelProvider) (eventService, error) {
+	client, err := l.newEventClient(channelProvider)
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// newEventClient returns event client that delivers blocks of the configured type from the configured start position
+func (l *listener) newEventClient(channelProvider pcontext.ChannelProvider) (*event.Client, error) {
+	opts := []event.ClientOption{event.WithSeekType(l.seekType)}
+	if l.seekType == seek.FromBlock {
+		opts = append(opts, event.WithBlockNum(l.blockNum))
+	}
+	if l.settings.BlockType == blockFull {
+		opts = append(opts, event.WithBlockEvents())
+	}
+	return event.New(channelProvider, opts...)
+}
+
+// start registers for block events, and processes the blocks in a go routine
+func (l *listener) start(service eventService) error {
+	l.done = make(chan bool)
+	if l.settings.BlockType == blockFiltered {
+		reg, events, err := service.RegisterFilteredBlockEvent()
+		if err != nil {
+			return errors.Wrapf(err, "Failed to register filtered block event on channel %s", l.settings.ChannelID)
+		}
+		l.reg = reg
+		go func() {
+			defer close(l.done)
+			for ev := range events {
+				if ev.FilteredBlock == nil {
+					continue
+				}
+				l.process(request.DecodeFilteredBlock(ev.FilteredBlock), ev.SourceURL)
+			}
+			logger.Infof("handler %s stopped listening to filtered blocks", l.handler.Name())
+		}()
+	} else {
+		reg, events, err := service.RegisterBlockEvent()
+		if err != nil {
+			return errors.Wrapf(err, "Failed to register block event on channel %s", l.settings.ChannelID)
+		}
+		l.reg = reg
+		go func() {
+			defer close(l.done)
+			for ev := range events {
+				block, err := request.DecodeBlock(ev.Block)
+				if err != nil {
+					logger.Errorf("handler %s failed to decode block: %+v", l.handler.Name(), err)
+					continue
+				}
+				l.process(block, ev.SourceURL)
+			}
+			logger.Infof("handler %s stopped listening to blocks", l.handler.Name())
+		}()
+	}
+	l.service = service
+	return nil
+}
+
+// stop unregisters block events, which closes the event channel, and waits for the current block to complete
+func (l *listener) stop() {
+	if l.service == nil {
+		return
+	}
+	l.service.Unregister(l.reg)
+	<-l.done
+	l.service = nil
+}
+
+// process sends a decoded block to the handler
+func (l *listener) process(block *request.Block, sourceURL string) {
+	logger.Debugf("received block %d with %d transactions", block.Number, len(block.Transactions))
+	data, err := block.ToMap()
+	if err != nil {
+		logger.Errorf("handler %s failed to convert block %d: %+v", l.handler.Name(), block.Number, err)
+		return
+	}
+	channelID := block.ChannelID
+	if len(channelID) == 0 {
+		channelID = l.settings.ChannelID
+	}
+	output := &Output{
+		ChannelID:   channelID,
+		BlockNumber: block.Number,
+		Block:       data,
+		SourceURL:   sourceURL,
+	}
+	if _, err := l.handler.Handle(context.Background(), output.ToMap()); err != nil {
+		logger.Errorf("handler %s failed to process block %d: %+v", l.handler.Name(), block.Number, err)
+	}
+	l.seekType = seek.FromBlock
+	l.blockNum = block.Number + 1
+}
@@ -0,0 +1,162 @@
+/*
+SPDX-License-Identifier: BSD-3-Clause-Open-MPI
+*/
+
+package blockevent
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	cb "github.com/hyperledger/fabric-protos-go/common"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	pcontext "github.com/hyperledger/fabric-sdk-go/pkg/common/providers/context"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fab/events/deliverclient/seek"
+	"github.com/open-dovetail/fabric-client/activity/request"
+	"github.com/project-flogo/core/support/log"
+	"github.com/project-flogo/core/trigger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testHandler records trigger data received from the listener
+type testHandler struct {
+	sync.Mutex
+	events []map[string]interface{}
+}
+
+func (h *testHandler) Name() string                     { return "test-handler" }
+func (h *testHandler) Logger() log.Logger               { return logger }
+func (h *testHandler) Settings() map[string]interface{} { return nil }
+func (h *testHandler) Schemas() *trigger.SchemaConfig   { return nil }
+func (h *testHandler) Handle(ctx context.Context, triggerData interface{}) (map[string]interface{}, error) {
+	h.Lock()
+	defer h.Unlock()
+	h.events = append(h.events, triggerData.(map[string]interface{}))
+	return nil, nil
+}
+
+// testEventService sends block events through channels that are closed by Unregister
+type testEventService struct {
+	blocks         chan *fab.BlockEvent
+	filteredBlocks chan *fab.FilteredBlockEvent
+}
+
+func (s *testEventService) RegisterBlockEvent(filter ...fab.BlockFilter) (fab.Registration, <-chan *fab.BlockEvent, error) {
+	return "block", s.blocks, nil
+}
+
+func (s *testEventService) RegisterFilteredBlockEvent() (fab.Registration, <-chan *fab.FilteredBlockEvent, error) {
+	return "filtered", s.filteredBlocks, nil
+}
+
+func (s *testEventService) Unregister(reg fab.Registration) {
+	if reg == "block" {
+		close(s.blocks)
+	} else {
+		close(s.filteredBlocks)
+	}
+}
+
+func TestHandlerSettings(t *testing.T) {
+	handler := &testHandler{}
+	l, err := newListener(handler, &HandlerSettings{ChannelID: "mychannel"})
+	require.NoError(t, err, "default settings should be valid")
+	assert.Equal(t, blockFull, l.settings.BlockType, "default block type should be full")
+	assert.Equal(t, seek.Type(seek.Newest), l.seekType, "default start block should be newest")
+
+	l, err = newListener(handler, &HandlerSettings{ChannelID: "mychannel", BlockType: "Filtered", StartBlock: "oldest"})
+	require.NoError(t, err, "filtered blocks from oldest should be valid")
+	assert.Equal(t, blockFiltered, l.settings.BlockType, "block type should be filtered")
+	assert.Equal(t, seek.Type(seek.Oldest), l.seekType, "start block should be oldest")
+
+	l, err = newListener(handler, &HandlerSettings{ChannelID: "mychannel", StartBlock: "12"})
+	require.NoError(t, err, "start from block number should be valid")
+	assert.Equal(t, seek.Type(seek.FromBlock), l.seekType, "start block should be a block number")
+	assert.Equal(t, uint64(12), l.blockNum, "start block number should be 12")
+
+	_, err = newListener(handler, &HandlerSettings{ChannelID: "mychannel", StartBlock: "latest"})
+	assert.Error(t, err, "invalid start block should throw error")
+	_, err = newListener(handler, &HandlerSettings{ChannelID: "mychannel", BlockType: "header"})
+	assert.Error(t, err, "invalid block type should throw error")
+}
+
+func TestListener(t *testing.T) {
+	handler := &testHandler{}
+	l, err := newListener(handler, &HandlerSettings{ChannelID: "mychannel"})
+	require.NoError(t, err, "default settings should be valid")
+	service := &testEventService{blocks: make(chan *fab.BlockEvent, 2)}
+	require.NoError(t, l.start(service), "start listener should not throw error")
+
+	service.blocks <- &fab.BlockEvent{Block: &cb.Block{Header: &cb.BlockHeader{Number: 3}}, SourceURL: "peer0.org1.example.com"}
+	l.stop()
+
+	require.Equal(t, 1, len(handler.events), "handler should receive 1 block")
+	assert.Equal(t, uint64(3), handler.events[0]["blockNumber"], "block number should be 3")
+	assert.Equal(t, "mychannel", handler.events[0]["channelID"], "channel ID of empty block should be from handler settings")
+	block := handler.events[0]["block"].(map[string]interface{})
+	assert.Equal(t, false, block["filtered"], "block should not be filtered")
+
+	l, err = newListener(handler, &HandlerSettings{ChannelID: "mychannel", BlockType: blockFiltered})
+	require.NoError(t, err, "filtered settings should be valid")
+	service = &testEventService{filteredBlocks: make(chan *fab.FilteredBlockEvent, 2)}
+	require.NoError(t, l.start(service), "start listener should not throw error")
+
+	service.filteredBlocks <- &fab.FilteredBlockEvent{FilteredBlock: &pb.FilteredBlock{
+		ChannelId:            "mychannel",
+		Number:               4,
+		FilteredTransactions: []*pb.FilteredTransaction{{Txid: "tx1", TxValidationCode: pb.TxValidationCode_VALID}},
+	}}
+	l.stop()
+
+	require.Equal(t, 2, len(handler.events), "handler should receive 2 blocks")
+	block = handler.events[1]["block"].(map[string]interface{})
+	assert.Equal(t, true, block["filtered"], "block should be filtered")
+	txs := block["transactions"].([]interface{})
+	assert.Equal(t, "tx1", txs[0].(map[string]interface{})["txID"], "block should contain tx1")
+}
+
+func TestReloadNetwork(t *testing.T) {
+	// network config without orgs or peers, so the SDK does not read crypto files
+	request.RegisterNetwork("reload-blocks", []byte("name: reload-blocks\nversion: 1.0.0\n"), nil)
+	defer request.UnregisterNetwork("reload-blocks")
+
+	var services []*testEventService
+	var starts []uint64
+	defer func(open func(*listener, pcontext.ChannelProvider) (eventService, error)) { openEvents = open }(openEvents)
+	openEvents = func(l *listener, channelProvider pcontext.ChannelProvider) (eventService, error) {
+		service := &testEventService{blocks: make(chan *fab.BlockEvent, 5)}
+		services = append(services, service)
+		starts = append(starts, l.blockNum)
+		return service, nil
+	}
+
+	handler := &testHandler{}
+	l, err := newListener(handler, &HandlerSettings{ChannelID: "mychannel"})
+	require.NoError(t, err, "default settings should be valid")
+	trg := &Trigger{id: "block-trigger", settings: &Settings{ConnectionName: "reload-blocks", UserName: "User1@org1"}, listeners: []*listener{l}}
+	require.NoError(t, trg.Start(), "start trigger should not throw error")
+	require.Len(t, services, 1, "listener should register block events")
+	services[0].blocks <- &fab.BlockEvent{Block: &cb.Block{Header: &cb.BlockHeader{Number: 3}}}
+
+	trg.reload("other-network")
+	assert.Len(t, services, 1, "reload of another connection should not restart the trigger")
+	oldSDK := trg.sdk
+	trg.reload("reload-blocks")
+	require.Len(t, services, 2, "reload of the connection should register block events again")
+	assert.NotEqual(t, oldSDK, trg.sdk, "listener should use a new SDK after reload")
+	_, open := <-services[0].blocks
+	assert.False(t, open, "blocks of the previous SDK should be unregistered")
+	assert.Equal(t, seek.Type(seek.FromBlock), l.seekType, "restarted listener should seek from a block")
+	assert.Equal(t, uint64(4), starts[1], "restarted listener should resume from the block after the last processed block")
+
+	services[1].blocks <- &fab.BlockEvent{Block: &cb.Block{Header: &cb.BlockHeader{Number: 4}}}
+	require.NoError(t, trg.Stop(), "stop trigger should not throw error")
+	require.Equal(t, 2, len(handler.events), "handler should receive 2 blocks")
+	assert.Equal(t, uint64(4), handler.events[1]["blockNumber"], "new block should be processed after reload")
+
+	trg.reload("reload-blocks")
+	assert.Len(t, services, 2, "stopped trigger should not be restarted by reload")
+}
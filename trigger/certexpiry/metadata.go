@@ -0,0 +1,87 @@
+/*
+SPDX-License-Identifier: BSD-3-Clause-Open-MPI
+*/
+
+package certexpiry
+
+import (
+	"github.com/project-flogo/core/data/coerce"
+)
+
+// Settings of the trigger
+type Settings struct {
+	ConnectionName string `md:"connectionName,required"`
+	// interval between scans, e.g., 12h; default 24h
+	Interval string `md:"interval"`
+}
+
+// HandlerSettings of the trigger
+type HandlerSettings struct {
+	DaysBeforeExpiry int    `md:"daysBeforeExpiry"`
+	CertType         string `md:"certType"`
+	// report certificates on every scan, instead of once when they enter the window and once when they expire
+	RepeatAlerts bool `md:"repeatAlerts"`
+}
+
+// Output of the trigger
+type Output struct {
+	CertType      string `md:"certType"`
+	Name          string `md:"name"`
+	Org           string `md:"org"`
+	MspID         string `md:"mspID"`
+	Path          string `md:"path"`
+	Subject       string `md:"subject"`
+	NotAfter      string `md:"notAfter"`
+	DaysRemaining int    `md:"daysRemaining"`
+	Expired       bool   `md:"expired"`
+}
+
+// ToMap converts trigger output to a map
+func (o *Output) ToMap() map[string]interface{} {
+	return map[string]interface{}{
+		"certType":      o.CertType,
+		"name":          o.Name,
+		"org":           o.Org,
+		"mspID":         o.MspID,
+		"path":          o.Path,
+		"subject":       o.Subject,
+		"notAfter":      o.NotAfter,
+		"daysRemaining": o.DaysRemaining,
+		"expired":       o.Expired,
+	}
+}
+
+// FromMap sets trigger output values from a map
+func (o *Output) FromMap(values map[string]interface{}) error {
+
+	var err error
+	if o.CertType, err = coerce.ToString(values["certType"]); err != nil {
+		return err
+	}
+	if o.Name, err = coerce.ToString(values["name"]); err != nil {
+		return err
+	}
+	if o.Org, err = coerce.ToString(values["org"]); err != nil {
+		return err
+	}
+	if o.MspID, err = coerce.ToString(values["mspID"]); err != nil {
+		return err
+	}
+	if o.Path, err = coerce.ToString(values["path"]); err != nil {
+		return err
+	}
+	if o.Subject, err = coerce.ToString(values["subject"]); err != nil {
+		return err
+	}
+	if o.NotAfter, err = coerce.ToString(values["notAfter"]); err != nil {
+		return err
+	}
+	if o.DaysRemaining, err = coerce.ToInt(values["daysRemaining"]); err != nil {
+		return err
+	}
+	if o.Expired, err = coerce.ToBool(values["expired"]); err != nil {
+		return err
+	}
+
+	return nil
+}
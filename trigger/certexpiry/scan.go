@@ -0,0 +1,183 @@
+/*
+SPDX-License-Identifier: BSD-3-Clause-Open-MPI
+*/
+
+package certexpiry
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"time"
+
+	"github.com/open-dovetail/fabric-client/activity/signcert"
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	certUser = "user"
+	certTLS  = "tls"
+	certAll  = "all"
+)
+
+// network config sections of entities that specify tlsCACerts
+var tlsSections = []string{"orderers", "peers", "certificateAuthorities"}
+
+// certificate is an identity or TLS CA certificate referenced by a network config
+type certificate struct {
+	certType    string
+	name        string
+	org         string
+	mspID       string
+	path        string
+	subject     string
+	notAfter    time.Time
+	fingerprint string
+}
+
+// key identifies a reported certificate by its entity, SHA-256 fingerprint, expiry and whether it already expired,
+// so a renewed certificate, or a certificate that expired since it was reported, is reported again
+func (c *certificate) key(now time.Time) string {
+	return fmt.Sprintf("%s/%s/%s/%s/%t", c.certType, c.name, c.fingerprint, c.notAfter.UTC().Format(time.RFC3339), now.After(c.notAfter))
+}
+
+// output returns trigger output of the certificate, with days remaining before expiry at the specified time
+func (c *certificate) output(now time.Time) *Output {
+	return &Output{
+		CertType:      c.certType,
+		Name:          c.name,
+		Org:           c.org,
+		MspID:         c.mspID,
+		Path:          c.path,
+		Subject:       c.subject,
+		NotAfter:      c.notAfter.UTC().Format(time.RFC3339),
+		DaysRemaining: daysRemaining(c.notAfter, now),
+		Expired:       now.After(c.notAfter),
+	}
+}
+
+// daysRemaining returns whole days from now to notAfter, rounded down, so it is negative once the certificate expired
+func daysRemaining(notAfter, now time.Time) int {
+	d := notAfter.Sub(now)
+	days := int(d / (24 * time.Hour))
+	if d < 0 && d%(24*time.Hour) != 0 {
+		days--
+	}
+	return days
+}
+
+// scanCertificates returns user certificates in the cryptoPath of orgs, and TLS CA certificates of
+// orderers, peers and CAs in a network config. Files that cannot be read are logged and skipped.
+func scanCertificates(networkConfig []byte) ([]*certificate, error) {
+	users, err := signcert.NetworkUsers(networkConfig, "")
+	if err != nil {
+		return nil, err
+	}
+	var result []*certificate
+	for _, u := range users {
+		result = append(result, &certificate{
+			certType:    certUser,
+			name:        u.Name + "@" + u.Org,
+			org:         u.Org,
+			mspID:       u.MspID,
+			subject:     u.Subject,
+			notAfter:    u.NotAfter,
+			fingerprint: u.Fingerprint,
+		})
+	}
+
+	certs, err := tlsCACertificates(networkConfig)
+	if err != nil {
+		return nil, err
+	}
+	return append(result, certs...), nil
+}
+
+// tlsCACertificates returns certificates in tlsCACerts.path or tlsCACerts.pem of orderers, peers and CAs, sorted by entity name
+func tlsCACertificates(networkConfig []byte) ([]*certificate, error) {
+	var data map[interface{}]interface{}
+	if err := yaml.Unmarshal(networkConfig, &data); err != nil {
+		return nil, errors.Wrapf(err, "Failed to parse network config")
+	}
+
+	var result []*certificate
+	for _, section := range tlsSections {
+		entities, _ := data[section].(map[interface{}]interface{})
+		var names []string
+		for k := range entities {
+			if name, ok := k.(string); ok {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			entity, _ := entities[name].(map[interface{}]interface{})
+			tlsCACerts, _ := entity["tlsCACerts"].(map[interface{}]interface{})
+			if tlsCACerts == nil {
+				continue
+			}
+			var certPEM []byte
+			path, _ := tlsCACerts["path"].(string)
+			if len(path) > 0 {
+				path = signcert.Subst(path)
+				content, err := ioutil.ReadFile(path)
+				if err != nil {
+					logger.Warnf("skip TLS CA cert of %s: %v", name, err)
+					continue
+				}
+				certPEM = content
+			} else if pemText, ok := tlsCACerts["pem"].(string); ok {
+				certPEM = []byte(pemText)
+			}
+
+			certs := parseCertificates(certPEM)
+			if len(certs) == 0 {
+				logger.Warnf("no TLS CA cert is found for %s", name)
+			}
+			for _, c := range certs {
+				result = append(result, &certificate{
+					certType:    certTLS,
+					name:        name,
+					path:        path,
+					subject:     c.Subject.String(),
+					notAfter:    c.NotAfter,
+					fingerprint: certFingerprint(c),
+				})
+			}
+		}
+	}
+	return result, nil
+}
+
+// parseCertificates returns x509 certificates in PEM data, and skips blocks that are not certificates
+func parseCertificates(data []byte) []*x509.Certificate {
+	var result []*x509.Certificate
+	for len(data) > 0 {
+		var block *pem.Block
+		if block, data = pem.Decode(data); block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			logger.Debugf("skip invalid certificate: %v", err)
+			continue
+		}
+		result = append(result, cert)
+	}
+	return result
+}
+
+// certFingerprint returns the hex encoded SHA-256 hash of a DER encoded certificate
+func certFingerprint(cert *x509.Certificate) string {
+	hash := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(hash[:])
+}
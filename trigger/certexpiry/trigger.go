@@ -0,0 +1,214 @@
+/*
+SPDX-License-Identifier: BSD-3-Clause-Open-MPI
+*/
+
+package certexpiry
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/open-dovetail/fabric-client/activity/request"
+	"github.com/pkg/errors"
+	"github.com/project-flogo/core/data/metadata"
+	"github.com/project-flogo/core/support/log"
+	"github.com/project-flogo/core/trigger"
+)
+
+const (
+	defaultInterval = 24 * time.Hour
+	defaultDays     = 30
+)
+
+// Create a new logger
+var logger = log.ChildLogger(log.RootLogger(), "trigger-fabclient-certexpiry")
+
+var triggerMd = trigger.NewMetadata(&Settings{}, &HandlerSettings{}, &Output{})
+
+func init() {
+	_ = trigger.Register(&Trigger{}, &Factory{})
+}
+
+// Factory creates certificate expiry triggers
+type Factory struct {
+}
+
+// New implements trigger.Factory.New
+func (f *Factory) New(config *trigger.Config) (trigger.Trigger, error) {
+	s := &Settings{}
+	if err := metadata.MapToStruct(config.Settings, s, true); err != nil {
+		return nil, err
+	}
+	interval := defaultInterval
+	if len(strings.TrimSpace(s.Interval)) > 0 {
+		d, err := time.ParseDuration(strings.TrimSpace(s.Interval))
+		if err != nil || d <= 0 {
+			return nil, errors.Errorf("Invalid interval %s: must be a positive duration, e.g., 12h", s.Interval)
+		}
+		interval = d
+	}
+	return &Trigger{id: config.Id, settings: s, interval: interval}, nil
+}
+
+// Metadata implements trigger.Factory.Metadata
+func (f *Factory) Metadata() *trigger.Metadata {
+	return triggerMd
+}
+
+// Trigger periodically scans certificates referenced by a Fabric network config,
+// and sends certificates that expire soon or already expired to its handlers
+type Trigger struct {
+	id       string
+	settings *Settings
+	interval time.Duration
+	watchers []*watcher
+	stop     chan bool
+	wg       sync.WaitGroup
+}
+
+// watcher dispatches certificates of a type that expire within a number of days to a trigger handler.
+// Unless repeatAlerts is set, a certificate is reported once when it enters the window, and once more when it expires.
+type watcher struct {
+	handler  trigger.Handler
+	settings *HandlerSettings
+	reported map[string]bool
+}
+
+// Metadata implements trigger.Trigger.Metadata
+func (t *Trigger) Metadata() *trigger.Metadata {
+	return triggerMd
+}
+
+// Initialize implements trigger.Trigger.Initialize
+func (t *Trigger) Initialize(ctx trigger.InitContext) error {
+	for _, handler := range ctx.GetHandlers() {
+		s := &HandlerSettings{}
+		if err := metadata.MapToStruct(handler.Settings(), s, true); err != nil {
+			return err
+		}
+		if _, ok := handler.Settings()["daysBeforeExpiry"]; !ok {
+			s.DaysBeforeExpiry = defaultDays
+		}
+		w, err := newWatcher(handler, s)
+		if err != nil {
+			return err
+		}
+		logger.Infof("handler %s watches %s certs expiring within %d days, repeatAlerts %t", handler.Name(), s.CertType, s.DaysBeforeExpiry, s.RepeatAlerts)
+		t.watchers = append(t.watchers, w)
+	}
+	return nil
+}
+
+// newWatcher validates handler settings, and returns a watcher of the handler
+func newWatcher(handler trigger.Handler, s *HandlerSettings) (*watcher, error) {
+	s.CertType = strings.ToLower(strings.TrimSpace(s.CertType))
+	if len(s.CertType) == 0 {
+		s.CertType = certAll
+	}
+	if s.CertType != certAll && s.CertType != certUser && s.CertType != certTLS {
+		return nil, errors.Errorf("Invalid certType %s of handler %s: must be all, user or tls", s.CertType, handler.Name())
+	}
+	if s.DaysBeforeExpiry < 0 {
+		return nil, errors.Errorf("Invalid daysBeforeExpiry %d of handler %s: must not be negative", s.DaysBeforeExpiry, handler.Name())
+	}
+	return &watcher{handler: handler, settings: s, reported: make(map[string]bool)}, nil
+}
+
+// Start implements trigger.Trigger.Start
+func (t *Trigger) Start() error {
+	// load network config files specified by environment variables, if any
+	if err := request.ConfigureNetwork(t.settings.ConnectionName, "", ""); err != nil {
+		return err
+	}
+	t.stop = make(chan bool)
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		ticker := time.NewTicker(t.interval)
+		defer ticker.Stop()
+		for {
+			t.scan(time.Now())
+			select {
+			case <-ticker.C:
+			case <-t.stop:
+				logger.Infof("trigger %s stopped scanning certificates", t.id)
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop implements trigger.Trigger.Stop
+func (t *Trigger) Stop() error {
+	if t.stop != nil {
+		close(t.stop)
+		t.wg.Wait()
+		t.stop = nil
+	}
+	return nil
+}
+
+// scan reads the current network config of the connection, so reloaded configs are picked up,
+// and sends certificates that expire soon to the handlers
+func (t *Trigger) scan(now time.Time) {
+	config, _ := request.LookupNetwork(t.settings.ConnectionName)
+	if len(config) == 0 {
+		logger.Errorf("network config of connection %s is not found", t.settings.ConnectionName)
+		return
+	}
+	certs, err := scanCertificates(config)
+	if err != nil {
+		logger.Errorf("failed to scan certificates of connection %s: %+v", t.settings.ConnectionName, err)
+		return
+	}
+	logger.Debugf("scanned %d certificates of connection %s", len(certs), t.settings.ConnectionName)
+	for _, w := range t.watchers {
+		w.process(certs, now)
+	}
+}
+
+// process sends certificates of the watched type that expire within the configured days to the handler.
+// Certificates already reported by previous scans are skipped unless repeatAlerts is set, and a certificate is
+// marked as reported only if the handler succeeded, so a failed alert is sent again by the next scan.
+func (w *watcher) process(certs []*certificate, now time.Time) {
+	if w.reported == nil {
+		w.reported = make(map[string]bool)
+	}
+	deadline := now.AddDate(0, 0, w.settings.DaysBeforeExpiry)
+	current := make(map[string]bool)
+	for _, c := range certs {
+		if w.settings.CertType != certAll && w.settings.CertType != c.certType {
+			continue
+		}
+		if c.notAfter.After(deadline) {
+			continue
+		}
+		key := c.key(now)
+		current[key] = true
+		if w.reported[key] && !w.settings.RepeatAlerts {
+			logger.Debugf("skip %s cert of %s that is already reported", c.certType, c.name)
+			continue
+		}
+		output := c.output(now)
+		if output.Expired {
+			logger.Warnf("%s cert of %s expired at %s", c.certType, c.name, output.NotAfter)
+		} else {
+			logger.Infof("%s cert of %s expires at %s", c.certType, c.name, output.NotAfter)
+		}
+		if _, err := w.handler.Handle(context.Background(), output.ToMap()); err != nil {
+			logger.Errorf("handler %s failed to process cert of %s: %+v", w.handler.Name(), c.name, err)
+			continue
+		}
+		w.reported[key] = true
+	}
+
+	// forget certificates that are renewed or removed, so the reported keys do not grow
+	for key := range w.reported {
+		if !current[key] {
+			delete(w.reported, key)
+		}
+	}
+}
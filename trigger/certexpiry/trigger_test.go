@@ -0,0 +1,227 @@
+/*
+SPDX-License-Identifier: BSD-3-Clause-Open-MPI
+*/
+
+package certexpiry
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/open-dovetail/fabric-client/activity/request"
+	"github.com/project-flogo/core/support/log"
+	"github.com/project-flogo/core/trigger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testHandler records trigger data received from the watcher
+type testHandler struct {
+	sync.Mutex
+	events []map[string]interface{}
+}
+
+func (h *testHandler) Name() string                     { return "test-handler" }
+func (h *testHandler) Logger() log.Logger               { return logger }
+func (h *testHandler) Settings() map[string]interface{} { return nil }
+func (h *testHandler) Schemas() *trigger.SchemaConfig   { return nil }
+func (h *testHandler) Handle(ctx context.Context, triggerData interface{}) (map[string]interface{}, error) {
+	h.Lock()
+	defer h.Unlock()
+	h.events = append(h.events, triggerData.(map[string]interface{}))
+	return nil, nil
+}
+
+// writeTestCert writes a self-signed PEM certificate that expires at notAfter
+func writeTestCert(t *testing.T, path, cn string, notAfter time.Time) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err, "generate key should not throw error")
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             notAfter.AddDate(-1, 0, 0),
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err, "create certificate should not throw error")
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755), "create folder should not throw error")
+	require.NoError(t, ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644), "write certificate should not throw error")
+}
+
+// writeTestNetwork writes a user cert expiring in 5 days, an orderer TLS CA cert expired 2 days ago,
+// and a peer TLS CA cert valid for a year, and returns the network config that refers to them
+func writeTestNetwork(t *testing.T, dir string) []byte {
+	now := time.Now()
+	writeTestCert(t, filepath.Join(dir, "peerOrganizations/org1.example.com/users/User1@org1.example.com/msp/signcerts/User1@org1.example.com-cert.pem"), "User1@org1.example.com", now.AddDate(0, 0, 5).Add(time.Hour))
+	writeTestCert(t, filepath.Join(dir, "ordererOrganizations/example.com/tlsca/tlsca.example.com-cert.pem"), "tlsca.example.com", now.AddDate(0, 0, -2).Add(time.Hour))
+	writeTestCert(t, filepath.Join(dir, "peerOrganizations/org1.example.com/tlsca/tlsca.org1.example.com-cert.pem"), "tlsca.org1.example.com", now.AddDate(1, 0, 0))
+	return []byte(fmt.Sprintf(`client:
+  organization: org1
+  cryptoconfig:
+    path: %[1]s
+organizations:
+  org1:
+    mspid: Org1MSP
+    cryptoPath: peerOrganizations/org1.example.com/users/{username}@org1.example.com/msp
+orderers:
+  orderer.example.com:
+    tlsCACerts:
+      path: %[1]s/ordererOrganizations/example.com/tlsca/tlsca.example.com-cert.pem
+peers:
+  peer0.org1.example.com:
+    tlsCACerts:
+      path: %[1]s/peerOrganizations/org1.example.com/tlsca/tlsca.org1.example.com-cert.pem
+  peer0.org2.example.com:
+    tlsCACerts:
+      path: %[1]s/peerOrganizations/org2.example.com/tlsca/tlsca.org2.example.com-cert.pem
+`, dir))
+}
+
+func TestHandlerSettings(t *testing.T) {
+	handler := &testHandler{}
+	w, err := newWatcher(handler, &HandlerSettings{DaysBeforeExpiry: 30})
+	require.NoError(t, err, "default settings should be valid")
+	assert.Equal(t, certAll, w.settings.CertType, "default cert type should be all")
+
+	w, err = newWatcher(handler, &HandlerSettings{CertType: "TLS"})
+	require.NoError(t, err, "tls cert type should be valid")
+	assert.Equal(t, certTLS, w.settings.CertType, "cert type should be tls")
+
+	_, err = newWatcher(handler, &HandlerSettings{CertType: "ca"})
+	assert.Error(t, err, "invalid cert type should throw error")
+	_, err = newWatcher(handler, &HandlerSettings{DaysBeforeExpiry: -1})
+	assert.Error(t, err, "negative days should throw error")
+
+	_, err = (&Factory{}).New(&trigger.Config{Settings: map[string]interface{}{"connectionName": "test", "interval": "1 day"}})
+	assert.Error(t, err, "invalid interval should throw error")
+	trg, err := (&Factory{}).New(&trigger.Config{Settings: map[string]interface{}{"connectionName": "test"}})
+	require.NoError(t, err, "default interval should be valid")
+	assert.Equal(t, defaultInterval, trg.(*Trigger).interval, "default interval should be 24h")
+}
+
+func TestDaysRemaining(t *testing.T) {
+	now := time.Now()
+	assert.Equal(t, 5, daysRemaining(now.Add(5*24*time.Hour+time.Hour), now), "days should be rounded down")
+	assert.Equal(t, 0, daysRemaining(now.Add(time.Hour), now), "cert expiring today should have 0 days")
+	assert.Equal(t, -1, daysRemaining(now.Add(-time.Hour), now), "expired cert should have negative days")
+}
+
+func TestScanCertificates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "crypto")
+	require.NoError(t, err, "create temp folder should not throw error")
+	defer os.RemoveAll(dir)
+	config := writeTestNetwork(t, dir)
+
+	certs, err := scanCertificates(config)
+	require.NoError(t, err, "scan certificates should not throw error")
+	require.Len(t, certs, 3, "missing TLS CA cert of peer0.org2 should be skipped")
+	assert.Equal(t, certUser, certs[0].certType, "user certs should be listed first")
+	assert.Equal(t, "User1@org1", certs[0].name, "user cert should be named by user@org")
+	assert.Equal(t, "Org1MSP", certs[0].mspID, "user cert should contain MSP ID")
+	assert.Equal(t, "CN=User1@org1.example.com", certs[0].subject, "user cert should contain subject")
+	assert.Len(t, certs[0].fingerprint, 64, "user cert should contain SHA-256 fingerprint")
+	assert.Equal(t, "orderer.example.com", certs[1].name, "orderer TLS CA cert should be listed before peers")
+	assert.Equal(t, "CN=tlsca.example.com", certs[1].subject, "TLS CA cert should contain subject")
+	assert.Equal(t, "peer0.org1.example.com", certs[2].name, "peer TLS CA cert should be listed")
+}
+
+func TestProcessCertificates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "crypto")
+	require.NoError(t, err, "create temp folder should not throw error")
+	defer os.RemoveAll(dir)
+	request.RegisterNetwork("certexpiry-test", writeTestNetwork(t, dir), nil)
+	defer request.UnregisterNetwork("certexpiry-test")
+
+	all := &testHandler{}
+	tls := &testHandler{}
+	expired := &testHandler{}
+	trg := &Trigger{id: "test", settings: &Settings{ConnectionName: "certexpiry-test"}}
+	trg.watchers = []*watcher{
+		{handler: all, settings: &HandlerSettings{CertType: certAll, DaysBeforeExpiry: 30}},
+		{handler: tls, settings: &HandlerSettings{CertType: certTLS, DaysBeforeExpiry: 400}},
+		{handler: expired, settings: &HandlerSettings{CertType: certAll, DaysBeforeExpiry: 0}},
+	}
+	trg.scan(time.Now())
+
+	require.Len(t, all.events, 2, "user cert and expired TLS CA cert should expire within 30 days")
+	assert.Equal(t, "User1@org1", all.events[0]["name"], "user cert should expire within 30 days")
+	assert.Equal(t, false, all.events[0]["expired"], "user cert should not be expired")
+	assert.Equal(t, 5, all.events[0]["daysRemaining"], "user cert should have 5 full days remaining")
+	assert.Equal(t, true, all.events[1]["expired"], "orderer TLS CA cert should be expired")
+	assert.Equal(t, -2, all.events[1]["daysRemaining"], "orderer TLS CA cert should have expired 2 days ago")
+	assert.Contains(t, all.events[1]["path"], "tlsca.example.com-cert.pem", "TLS CA cert should contain file path")
+
+	require.Len(t, tls.events, 2, "both TLS CA certs should expire within 400 days")
+	assert.Equal(t, "tls", tls.events[1]["certType"], "tls handler should receive only TLS CA certs")
+
+	require.Len(t, expired.events, 1, "only the orderer TLS CA cert should be expired")
+	assert.Equal(t, "orderer.example.com", expired.events[0]["name"], "orderer TLS CA cert should be expired")
+}
+
+func TestStartStop(t *testing.T) {
+	dir, err := ioutil.TempDir("", "crypto")
+	require.NoError(t, err, "create temp folder should not throw error")
+	defer os.RemoveAll(dir)
+	request.RegisterNetwork("certexpiry-start", writeTestNetwork(t, dir), nil)
+	defer request.UnregisterNetwork("certexpiry-start")
+
+	handler := &testHandler{}
+	trg := &Trigger{id: "test", settings: &Settings{ConnectionName: "certexpiry-start"}, interval: time.Hour}
+	trg.watchers = []*watcher{{handler: handler, settings: &HandlerSettings{CertType: certUser, DaysBeforeExpiry: 30}}}
+	require.NoError(t, trg.Start(), "start trigger should not throw error")
+	assert.Eventually(t, func() bool {
+		handler.Lock()
+		defer handler.Unlock()
+		return len(handler.events) == 1
+	}, 5*time.Second, 10*time.Millisecond, "certs should be scanned when trigger starts")
+	assert.NoError(t, trg.Stop(), "stop trigger should not throw error")
+	assert.NoError(t, trg.Stop(), "stop stopped trigger should not throw error")
+}
+
+func TestReportOnce(t *testing.T) {
+	dir, err := ioutil.TempDir("", "crypto")
+	require.NoError(t, err, "create temp folder should not throw error")
+	defer os.RemoveAll(dir)
+	request.RegisterNetwork("certexpiry-once", writeTestNetwork(t, dir), nil)
+	defer request.UnregisterNetwork("certexpiry-once")
+
+	once := &testHandler{}
+	repeat := &testHandler{}
+	trg := &Trigger{id: "test", settings: &Settings{ConnectionName: "certexpiry-once"}}
+	w, err := newWatcher(once, &HandlerSettings{CertType: certUser, DaysBeforeExpiry: 30})
+	require.NoError(t, err, "watcher settings should be valid")
+	trg.watchers = []*watcher{w, {handler: repeat, settings: &HandlerSettings{CertType: certUser, DaysBeforeExpiry: 30, RepeatAlerts: true}}}
+
+	now := time.Now()
+	trg.scan(now)
+	trg.scan(now.Add(time.Hour))
+	assert.Len(t, once.events, 1, "user cert should be reported once")
+	assert.Len(t, repeat.events, 2, "user cert should be reported on every scan if repeatAlerts is set")
+
+	trg.scan(now.AddDate(0, 0, 6))
+	require.Len(t, once.events, 2, "user cert should be reported again when it expires")
+	assert.Equal(t, true, once.events[1]["expired"], "user cert should be expired")
+
+	// renew the user cert
+	writeTestCert(t, filepath.Join(dir, "peerOrganizations/org1.example.com/users/User1@org1.example.com/msp/signcerts/User1@org1.example.com-cert.pem"), "User1@org1.example.com", now.AddDate(0, 0, 20))
+	trg.scan(now.AddDate(0, 0, 6))
+	require.Len(t, once.events, 3, "renewed user cert should be reported")
+	assert.Equal(t, false, once.events[2]["expired"], "renewed user cert should not be expired")
+	assert.Len(t, w.reported, 1, "replaced cert should be removed from reported certs")
+}
@@ -0,0 +1,155 @@
+/*
+SPDX-License-Identifier: BSD-3-Clause-Open-MPI
+*/
+
+package chaincodeevent
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Checkpoint records the last block and its transactions processed by a trigger handler.
+// It is saved in a file, so a restarted app can resume event processing from the last processed block.
+// Events processed after an event that the handler failed to process are recorded in Handled,
+// so they are not processed again when the failed event is retried after restart.
+type Checkpoint struct {
+	BlockNumber uint64         `json:"blockNumber"`
+	TxIDs       []string       `json:"txIDs"`
+	Handled     []HandledEvent `json:"handled,omitempty"`
+	path        string
+	exists      bool
+	lock        sync.Mutex
+}
+
+// HandledEvent is an event processed after an event that the handler failed to process
+type HandledEvent struct {
+	BlockNumber uint64 `json:"blockNumber"`
+	TxID        string `json:"txID"`
+}
+
+// checkpoint file name is derived from trigger ID and handler name
+var invalidNameChars = regexp.MustCompile(`[^\w.-]`)
+
+func checkpointFile(dir, triggerID, handlerName string) string {
+	name := invalidNameChars.ReplaceAllString(triggerID+"_"+handlerName, "_")
+	return filepath.Join(dir, name+".json")
+}
+
+// LoadCheckpoint reads checkpoint from a file, or returns an empty checkpoint if the file does not exist
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	cp := &Checkpoint{path: path}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cp, nil
+		}
+		return nil, errors.Wrapf(err, "Failed to read checkpoint file %s", path)
+	}
+	if err := json.Unmarshal(data, cp); err != nil {
+		return nil, errors.Wrapf(err, "Failed to parse checkpoint file %s", path)
+	}
+	cp.exists = true
+	return cp, nil
+}
+
+// Exists returns true if any event has been processed
+func (c *Checkpoint) Exists() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.exists
+}
+
+// Processed returns true if the event of a transaction in a block has already been processed
+func (c *Checkpoint) Processed(blockNumber uint64, txID string) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.exists && blockNumber < c.BlockNumber {
+		return true
+	}
+	if c.exists && blockNumber == c.BlockNumber {
+		for _, id := range c.TxIDs {
+			if id == txID {
+				return true
+			}
+		}
+	}
+	for _, e := range c.Handled {
+		if e.BlockNumber == blockNumber && e.TxID == txID {
+			return true
+		}
+	}
+	return false
+}
+
+// Update records the event of a transaction in a block as processed, and saves the checkpoint file
+func (c *Checkpoint) Update(blockNumber uint64, txID string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if !c.exists || blockNumber != c.BlockNumber {
+		c.BlockNumber = blockNumber
+		c.TxIDs = nil
+	}
+	c.TxIDs = append(c.TxIDs, txID)
+	c.exists = true
+
+	// events handled after a failed event are covered by the checkpoint once it advances to their block
+	var handled []HandledEvent
+	for _, e := range c.Handled {
+		switch {
+		case e.BlockNumber == blockNumber && e.TxID != txID:
+			c.TxIDs = append(c.TxIDs, e.TxID)
+		case e.BlockNumber > blockNumber:
+			handled = append(handled, e)
+		}
+	}
+	c.Handled = handled
+	return c.save()
+}
+
+// Hold keeps the checkpoint before an event of a block that the handler failed to process.
+// If no event has been processed yet, the checkpoint starts at the block, so the failed event is delivered again after restart.
+func (c *Checkpoint) Hold(blockNumber uint64) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.exists {
+		return nil
+	}
+	c.BlockNumber = blockNumber
+	c.TxIDs = nil
+	c.exists = true
+	return c.save()
+}
+
+// MarkHandled records an event processed after a failed event without advancing the checkpoint, and saves the checkpoint file
+func (c *Checkpoint) MarkHandled(blockNumber uint64, txID string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.Handled = append(c.Handled, HandledEvent{BlockNumber: blockNumber, TxID: txID})
+	return c.save()
+}
+
+// save writes checkpoint to a temp file, and then renames it, so the checkpoint file is never partially written
+func (c *Checkpoint) save() error {
+	if len(c.path) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to serialize checkpoint")
+	}
+	tmpFile := c.path + ".tmp"
+	if err := ioutil.WriteFile(tmpFile, data, 0644); err != nil {
+		return errors.Wrapf(err, "Failed to write checkpoint file %s", tmpFile)
+	}
+	if err := os.Rename(tmpFile, c.path); err != nil {
+		return errors.Wrapf(err, "Failed to rename checkpoint file %s", tmpFile)
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+module github.com/open-dovetail/fabric-client/trigger/chaincodeevent
+
+go 1.14
+
+replace github.com/project-flogo/flow => github.com/yxuco/flow v1.1.1
+
+replace github.com/project-flogo/core => github.com/yxuco/core v1.2.2
+
+replace go.uber.org/multierr => go.uber.org/multierr v1.6.0
+
+replace github.com/open-dovetail/fabric-client/activity/request => ../../activity/request
+
+require (
+	github.com/hyperledger/fabric-sdk-go v1.0.0-rc1
+	github.com/open-dovetail/fabric-client/activity/request v0.0.1
+	github.com/pkg/errors v0.9.1
+	github.com/project-flogo/core v1.2.0
+	github.com/stretchr/testify v1.6.1
+	go.uber.org/multierr v1.6.0 // indirect
+)
@@ -0,0 +1,75 @@
+/*
+SPDX-License-Identifier: BSD-3-Clause-Open-MPI
+*/
+
+package chaincodeevent
+
+import (
+	"github.com/project-flogo/core/data/coerce"
+)
+
+// Settings of the trigger
+type Settings struct {
+	ConnectionName string `md:"connectionName,required"`
+	UserName       string `md:"userName,required"`
+	CheckpointDir  string `md:"checkpointDir"`
+}
+
+// HandlerSettings of the trigger
+type HandlerSettings struct {
+	ChannelID   string `md:"channelID,required"`
+	ChaincodeID string `md:"chaincodeID,required"`
+	EventFilter string `md:"eventFilter"`
+	// advance checkpoint past events that the handler failed to process
+	SkipFailedEvents bool `md:"skipFailedEvents"`
+}
+
+// Output of the trigger
+type Output struct {
+	ChaincodeID string      `md:"chaincodeID"`
+	EventName   string      `md:"eventName"`
+	Payload     interface{} `md:"payload"`
+	TxID        string      `md:"txID"`
+	BlockNumber uint64      `md:"blockNumber"`
+	SourceURL   string      `md:"sourceURL"`
+}
+
+// ToMap converts trigger output to a map
+func (o *Output) ToMap() map[string]interface{} {
+	return map[string]interface{}{
+		"chaincodeID": o.ChaincodeID,
+		"eventName":   o.EventName,
+		"payload":     o.Payload,
+		"txID":        o.TxID,
+		"blockNumber": o.BlockNumber,
+		"sourceURL":   o.SourceURL,
+	}
+}
+
+// FromMap sets trigger output values from a map
+func (o *Output) FromMap(values map[string]interface{}) error {
+
+	var err error
+	if o.ChaincodeID, err = coerce.ToString(values["chaincodeID"]); err != nil {
+		return err
+	}
+	if o.EventName, err = coerce.ToString(values["eventName"]); err != nil {
+		return err
+	}
+	if o.Payload, err = coerce.ToAny(values["payload"]); err != nil {
+		return err
+	}
+	if o.TxID, err = coerce.ToString(values["txID"]); err != nil {
+		return err
+	}
+	blockNumber, err := coerce.ToInt64(values["blockNumber"])
+	if err != nil {
+		return err
+	}
+	o.BlockNumber = uint64(blockNumber)
+	if o.SourceURL, err = coerce.ToString(values["sourceURL"]); err != nil {
+		return err
+	}
+
+	return nil
+}
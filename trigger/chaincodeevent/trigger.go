@@ -0,0 +1,299 @@
+/*
+SPDX-License-Identifier: BSD-3-Clause-Open-MPI
+*/
+
+package chaincodeevent
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/event"
+	pcontext "github.com/hyperledger/fabric-sdk-go/pkg/common/providers/context"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fab/events/deliverclient/seek"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fabsdk"
+	"github.com/open-dovetail/fabric-client/activity/request"
+	"github.com/pkg/errors"
+	"github.com/project-flogo/core/data/metadata"
+	"github.com/project-flogo/core/support/log"
+	"github.com/project-flogo/core/trigger"
+)
+
+const defaultCheckpointDir = "checkpoint"
+
+// Create a new logger
+var logger = log.ChildLogger(log.RootLogger(), "trigger-fabclient-chaincodeevent")
+
+var triggerMd = trigger.NewMetadata(&Settings{}, &HandlerSettings{}, &Output{})
+
+func init() {
+	_ = trigger.Register(&Trigger{}, &Factory{})
+}
+
+// Factory creates chaincode event triggers
+type Factory struct {
+}
+
+// New implements trigger.Factory.New
+func (f *Factory) New(config *trigger.Config) (trigger.Trigger, error) {
+	s := &Settings{}
+	if err := metadata.MapToStruct(config.Settings, s, true); err != nil {
+		return nil, err
+	}
+	if len(s.CheckpointDir) == 0 {
+		s.CheckpointDir = defaultCheckpointDir
+	}
+	return &Trigger{id: config.Id, settings: s}, nil
+}
+
+// Metadata implements trigger.Factory.Metadata
+func (f *Factory) Metadata() *trigger.Metadata {
+	return triggerMd
+}
+
+// Trigger listens to chaincode events of a Fabric network
+type Trigger struct {
+	id        string
+	settings  *Settings
+	sdk       *fabsdk.FabricSDK
+	listeners []*listener
+	// lock serializes start, stop and restart of listeners when the network config is reloaded
+	lock       sync.Mutex
+	running    bool
+	reloadOnce sync.Once
+}
+
+// eventService is the part of event.Client used by the trigger to receive chaincode events
+type eventService interface {
+	RegisterChaincodeEvent(ccID, eventFilter string) (fab.Registration, <-chan *fab.CCEvent, error)
+	Unregister(reg fab.Registration)
+}
+
+// listener dispatches chaincode events of a channel and chaincode to a trigger handler
+type listener struct {
+	handler    trigger.Handler
+	settings   *HandlerSettings
+	checkpoint *Checkpoint
+	service    eventService
+	reg        fab.Registration
+	done       chan bool
+	// set when the handler fails to process an event, so the checkpoint is not advanced past the failed event,
+	// and later events are recorded as handled instead
+	failed bool
+}
+
+// Metadata implements trigger.Trigger.Metadata
+func (t *Trigger) Metadata() *trigger.Metadata {
+	return triggerMd
+}
+
+// Initialize implements trigger.Trigger.Initialize
+func (t *Trigger) Initialize(ctx trigger.InitContext) error {
+	if err := os.MkdirAll(t.settings.CheckpointDir, 0755); err != nil {
+		return errors.Wrapf(err, "Failed to create checkpoint folder %s", t.settings.CheckpointDir)
+	}
+
+	for _, handler := range ctx.GetHandlers() {
+		s := &HandlerSettings{}
+		if err := metadata.MapToStruct(handler.Settings(), s, true); err != nil {
+			return err
+		}
+		if len(s.EventFilter) == 0 {
+			s.EventFilter = ".*"
+		}
+		cp, err := LoadCheckpoint(checkpointFile(t.settings.CheckpointDir, t.id, handler.Name()))
+		if err != nil {
+			return err
+		}
+		logger.Infof("handler %s listens to chaincode %s events %s on channel %s", handler.Name(), s.ChaincodeID, s.EventFilter, s.ChannelID)
+		t.listeners = append(t.listeners, &listener{
+			handler:    handler,
+			settings:   s,
+			checkpoint: cp,
+		})
+	}
+	return nil
+}
+
+// Start implements trigger.Trigger.Start
+func (t *Trigger) Start() error {
+	// load network config files specified by environment variables, if any
+	if err := request.ConfigureNetwork(t.settings.ConnectionName, "", ""); err != nil {
+		return err
+	}
+	t.reloadOnce.Do(func() {
+		request.OnNetworkReload(t.reload)
+	})
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.running = true
+	return t.startListeners()
+}
+
+// Stop implements trigger.Trigger.Stop
+func (t *Trigger) Stop() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.running = false
+	t.stopListeners()
+	return nil
+}
+
+// reload restarts the listeners on a new SDK after the network config of the connection is reloaded,
+// so they use the new endpoints and certificates, and resume from their checkpoints
+func (t *Trigger) reload(connectionName string) {
+	if connectionName != t.settings.ConnectionName {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if !t.running {
+		return
+	}
+	logger.Infof("restart trigger %s with reloaded network config of connection %s", t.id, connectionName)
+	t.stopListeners()
+	if err := t.startListeners(); err != nil {
+		logger.Errorf("failed to restart trigger %s with reloaded network config: %+v", t.id, err)
+	}
+}
+
+// startListeners creates an SDK of the current network config, and starts the listeners; the caller must hold the lock
+func (t *Trigger) startListeners() error {
+	sdk, err := request.NewSDK(request.NetworkConnector(t.settings.ConnectionName))
+	if err != nil {
+		return err
+	}
+	t.sdk = sdk
+
+	user, org := request.SplitUser(t.settings.UserName)
+	opts := []fabsdk.ContextOption{fabsdk.WithUser(user)}
+	if len(org) > 0 {
+		opts = append(opts, fabsdk.WithOrg(org))
+	}
+	for _, l := range t.listeners {
+		client, err := openEvents(sdk.ChannelContext(l.settings.ChannelID, opts...), l.checkpoint)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to create event client for channel %s", l.settings.ChannelID)
+		}
+		if err := l.start(client); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stopListeners stops the listeners, and closes the SDK; the caller must hold the lock
+func (t *Trigger) stopListeners() {
+	for _, l := range t.listeners {
+		l.stop()
+	}
+	if t.sdk != nil {
+		t.sdk.Close()
+		t.sdk = nil
+	}
+}
+
+// openEvents returns the event service of a channel, which is replaced by tests
+var openEvents = func(channelProvider pcontext.ChannelProvider, cp *Checkpoint) (eventService, error) {
+	client, err := newEventClient(channelProvider, cp)
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// newEventClient returns event client that delivers full blocks from the checkpoint, or from the newest block if no checkpoint exists
+func newEventClient(channelProvider pcontext.ChannelProvider, cp *Checkpoint) (*event.Client, error) {
+	opts := []event.ClientOption{event.WithBlockEvents()}
+	if cp.Exists() {
+		opts = append(opts, event.WithSeekType(seek.FromBlock), event.WithBlockNum(cp.BlockNumber))
+	} else {
+		opts = append(opts, event.WithSeekType(seek.Newest))
+	}
+	return event.New(channelProvider, opts...)
+}
+
+// start registers for chaincode events, and processes the events in a go routine
+func (l *listener) start(service eventService) error {
+	reg, events, err := service.RegisterChaincodeEvent(l.settings.ChaincodeID, l.settings.EventFilter)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to register chaincode event %s of %s", l.settings.EventFilter, l.settings.ChaincodeID)
+	}
+	l.service = service
+	l.reg = reg
+	l.done = make(chan bool)
+	l.failed = false
+
+	go func() {
+		defer close(l.done)
+		for ev := range events {
+			l.process(ev)
+		}
+		logger.Infof("handler %s stopped listening to chaincode events", l.handler.Name())
+	}()
+	return nil
+}
+
+// stop unregisters chaincode events, which closes the event channel, and waits for the current event to complete
+func (l *listener) stop() {
+	if l.service == nil {
+		return
+	}
+	l.service.Unregister(l.reg)
+	<-l.done
+	l.service = nil
+}
+
+// process sends a chaincode event to the handler unless it is already processed before a restart.
+// The checkpoint is advanced only if the handler succeeds, unless skipFailedEvents is set,
+// so a failed event is processed again when the app restarts. Events processed after a failed event
+// are recorded in the checkpoint, so they are not processed again after restart.
+func (l *listener) process(ev *fab.CCEvent) {
+	if l.checkpoint.Processed(ev.BlockNumber, ev.TxID) {
+		logger.Debugf("skip processed event %s of transaction %s in block %d", ev.EventName, ev.TxID, ev.BlockNumber)
+		return
+	}
+	logger.Debugf("received event %s of transaction %s in block %d", ev.EventName, ev.TxID, ev.BlockNumber)
+
+	var payload interface{}
+	if len(ev.Payload) > 0 {
+		if err := json.Unmarshal(ev.Payload, &payload); err != nil {
+			logger.Debugf("event payload is not JSON: %+v", err)
+			payload = string(ev.Payload)
+		}
+	}
+	output := &Output{
+		ChaincodeID: ev.ChaincodeID,
+		EventName:   ev.EventName,
+		Payload:     payload,
+		TxID:        ev.TxID,
+		BlockNumber: ev.BlockNumber,
+		SourceURL:   ev.SourceURL,
+	}
+	if _, err := l.handler.Handle(context.Background(), output.ToMap()); err != nil {
+		logger.Errorf("handler %s failed to process event %s of transaction %s: %+v", l.handler.Name(), ev.EventName, ev.TxID, err)
+		if !l.settings.SkipFailedEvents {
+			if !l.failed {
+				logger.Warnf("checkpoint of handler %s stays before block %d, so the failed event is processed again after restart", l.handler.Name(), ev.BlockNumber)
+				if err := l.checkpoint.Hold(ev.BlockNumber); err != nil {
+					logger.Errorf("failed to save checkpoint of handler %s: %+v", l.handler.Name(), err)
+				}
+			}
+			l.failed = true
+			return
+		}
+	}
+	if l.failed {
+		if err := l.checkpoint.MarkHandled(ev.BlockNumber, ev.TxID); err != nil {
+			logger.Errorf("failed to save checkpoint of handler %s: %+v", l.handler.Name(), err)
+		}
+		return
+	}
+	if err := l.checkpoint.Update(ev.BlockNumber, ev.TxID); err != nil {
+		logger.Errorf("failed to save checkpoint of handler %s: %+v", l.handler.Name(), err)
+	}
+}
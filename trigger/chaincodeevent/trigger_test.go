@@ -0,0 +1,268 @@
+/*
+SPDX-License-Identifier: BSD-3-Clause-Open-MPI
+*/
+
+package chaincodeevent
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	pcontext "github.com/hyperledger/fabric-sdk-go/pkg/common/providers/context"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	"github.com/open-dovetail/fabric-client/activity/request"
+	"github.com/pkg/errors"
+	"github.com/project-flogo/core/support/log"
+	"github.com/project-flogo/core/trigger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testHandler records trigger data received from the listener, and fails events of the failTx
+type testHandler struct {
+	sync.Mutex
+	events []map[string]interface{}
+	failTx string
+}
+
+func (h *testHandler) Name() string                     { return "test-handler" }
+func (h *testHandler) Logger() log.Logger               { return logger }
+func (h *testHandler) Settings() map[string]interface{} { return nil }
+func (h *testHandler) Schemas() *trigger.SchemaConfig   { return nil }
+func (h *testHandler) Handle(ctx context.Context, triggerData interface{}) (map[string]interface{}, error) {
+	h.Lock()
+	defer h.Unlock()
+	data := triggerData.(map[string]interface{})
+	h.events = append(h.events, data)
+	if len(h.failTx) > 0 && data["txID"] == h.failTx {
+		return nil, errors.New("failed to process event")
+	}
+	return nil, nil
+}
+
+// testEventService sends chaincode events through a channel that is closed by Unregister
+type testEventService struct {
+	events chan *fab.CCEvent
+}
+
+func (s *testEventService) RegisterChaincodeEvent(ccID, eventFilter string) (fab.Registration, <-chan *fab.CCEvent, error) {
+	return "registration", s.events, nil
+}
+
+func (s *testEventService) Unregister(reg fab.Registration) {
+	close(s.events)
+}
+
+func TestCheckpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	require.NoError(t, err, "create temp folder should not throw error")
+	defer os.RemoveAll(dir)
+
+	path := checkpointFile(dir, "event-trigger", "asset events")
+	assert.Equal(t, filepath.Join(dir, "event-trigger_asset_events.json"), path, "checkpoint file name should be sanitized")
+
+	cp, err := LoadCheckpoint(path)
+	require.NoError(t, err, "load missing checkpoint should not throw error")
+	assert.False(t, cp.Exists(), "missing checkpoint should not exist")
+	assert.False(t, cp.Processed(0, "tx1"), "no event should be processed by empty checkpoint")
+
+	require.NoError(t, cp.Update(5, "tx1"), "update checkpoint should not throw error")
+	require.NoError(t, cp.Update(5, "tx2"), "update checkpoint should not throw error")
+
+	cp, err = LoadCheckpoint(path)
+	require.NoError(t, err, "load saved checkpoint should not throw error")
+	assert.True(t, cp.Exists(), "saved checkpoint should exist")
+	assert.Equal(t, uint64(5), cp.BlockNumber, "checkpoint should be at block 5")
+	assert.True(t, cp.Processed(4, "tx0"), "events of earlier block should be processed")
+	assert.True(t, cp.Processed(5, "tx2"), "event of tx2 in block 5 should be processed")
+	assert.False(t, cp.Processed(5, "tx3"), "event of tx3 in block 5 should not be processed")
+	assert.False(t, cp.Processed(6, "tx4"), "events of later block should not be processed")
+
+	require.NoError(t, cp.Update(6, "tx4"), "update checkpoint should not throw error")
+	assert.Equal(t, []string{"tx4"}, cp.TxIDs, "checkpoint should track transactions of the new block only")
+}
+
+func TestListener(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	require.NoError(t, err, "create temp folder should not throw error")
+	defer os.RemoveAll(dir)
+
+	path := checkpointFile(dir, "event-trigger", "test-handler")
+	cp, err := LoadCheckpoint(path)
+	require.NoError(t, err, "load missing checkpoint should not throw error")
+
+	handler := &testHandler{}
+	l := &listener{
+		handler:    handler,
+		settings:   &HandlerSettings{ChannelID: "mychannel", ChaincodeID: "basic", EventFilter: ".*"},
+		checkpoint: cp,
+	}
+	service := &testEventService{events: make(chan *fab.CCEvent, 5)}
+	require.NoError(t, l.start(service), "start listener should not throw error")
+
+	service.events <- &fab.CCEvent{TxID: "tx1", ChaincodeID: "basic", EventName: "CreateAsset", Payload: []byte(`{"ID":"asset1"}`), BlockNumber: 3}
+	service.events <- &fab.CCEvent{TxID: "tx2", ChaincodeID: "basic", EventName: "DeleteAsset", Payload: []byte("asset2"), BlockNumber: 4}
+	l.stop()
+
+	require.Equal(t, 2, len(handler.events), "handler should receive 2 events")
+	assert.Equal(t, "CreateAsset", handler.events[0]["eventName"], "first event should be CreateAsset")
+	assert.Equal(t, map[string]interface{}{"ID": "asset1"}, handler.events[0]["payload"], "JSON payload should be decoded")
+	assert.Equal(t, uint64(3), handler.events[0]["blockNumber"], "first event should be in block 3")
+	assert.Equal(t, "asset2", handler.events[1]["payload"], "non-JSON payload should be a string")
+
+	// restart from the checkpoint, which skips processed events
+	cp, err = LoadCheckpoint(path)
+	require.NoError(t, err, "load saved checkpoint should not throw error")
+	assert.Equal(t, uint64(4), cp.BlockNumber, "checkpoint should be at block 4")
+	l.checkpoint = cp
+	service = &testEventService{events: make(chan *fab.CCEvent, 5)}
+	require.NoError(t, l.start(service), "restart listener should not throw error")
+
+	service.events <- &fab.CCEvent{TxID: "tx2", ChaincodeID: "basic", EventName: "DeleteAsset", Payload: []byte("asset2"), BlockNumber: 4}
+	service.events <- &fab.CCEvent{TxID: "tx3", ChaincodeID: "basic", EventName: "CreateAsset", Payload: []byte(`{"ID":"asset3"}`), BlockNumber: 4}
+	l.stop()
+
+	require.Equal(t, 3, len(handler.events), "handler should receive only 1 new event after restart")
+	assert.Equal(t, "tx3", handler.events[2]["txID"], "new event should be of tx3")
+}
+
+func TestFailedEvent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	require.NoError(t, err, "create temp folder should not throw error")
+	defer os.RemoveAll(dir)
+
+	path := checkpointFile(dir, "event-trigger", "test-handler")
+	cp, err := LoadCheckpoint(path)
+	require.NoError(t, err, "load missing checkpoint should not throw error")
+
+	handler := &testHandler{failTx: "tx2"}
+	l := &listener{
+		handler:    handler,
+		settings:   &HandlerSettings{ChannelID: "mychannel", ChaincodeID: "basic", EventFilter: ".*"},
+		checkpoint: cp,
+	}
+	service := &testEventService{events: make(chan *fab.CCEvent, 5)}
+	require.NoError(t, l.start(service), "start listener should not throw error")
+	service.events <- &fab.CCEvent{TxID: "tx1", ChaincodeID: "basic", EventName: "CreateAsset", BlockNumber: 3}
+	service.events <- &fab.CCEvent{TxID: "tx2", ChaincodeID: "basic", EventName: "CreateAsset", BlockNumber: 4}
+	service.events <- &fab.CCEvent{TxID: "tx3", ChaincodeID: "basic", EventName: "CreateAsset", BlockNumber: 5}
+	l.stop()
+	require.Equal(t, 3, len(handler.events), "handler should receive 3 events")
+
+	cp, err = LoadCheckpoint(path)
+	require.NoError(t, err, "load saved checkpoint should not throw error")
+	assert.Equal(t, uint64(3), cp.BlockNumber, "checkpoint should stay before the failed event")
+	assert.False(t, cp.Processed(4, "tx2"), "failed event should be processed again after restart")
+
+	// checkpoint advances past failed events if skipFailedEvents is set
+	l.checkpoint = cp
+	l.settings.SkipFailedEvents = true
+	service = &testEventService{events: make(chan *fab.CCEvent, 5)}
+	require.NoError(t, l.start(service), "restart listener should not throw error")
+	service.events <- &fab.CCEvent{TxID: "tx2", ChaincodeID: "basic", EventName: "CreateAsset", BlockNumber: 4}
+	l.stop()
+	assert.True(t, l.checkpoint.Processed(4, "tx2"), "skipped failed event should not be processed again")
+}
+
+func TestRestartAfterFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	require.NoError(t, err, "create temp folder should not throw error")
+	defer os.RemoveAll(dir)
+
+	path := checkpointFile(dir, "event-trigger", "test-handler")
+	cp, err := LoadCheckpoint(path)
+	require.NoError(t, err, "load missing checkpoint should not throw error")
+
+	// the first event fails, and the later events succeed
+	handler := &testHandler{failTx: "tx1"}
+	l := &listener{
+		handler:    handler,
+		settings:   &HandlerSettings{ChannelID: "mychannel", ChaincodeID: "basic", EventFilter: ".*"},
+		checkpoint: cp,
+	}
+	service := &testEventService{events: make(chan *fab.CCEvent, 5)}
+	require.NoError(t, l.start(service), "start listener should not throw error")
+	service.events <- &fab.CCEvent{TxID: "tx1", ChaincodeID: "basic", EventName: "CreateAsset", BlockNumber: 3}
+	service.events <- &fab.CCEvent{TxID: "tx2", ChaincodeID: "basic", EventName: "CreateAsset", BlockNumber: 3}
+	service.events <- &fab.CCEvent{TxID: "tx3", ChaincodeID: "basic", EventName: "CreateAsset", BlockNumber: 4}
+	l.stop()
+	require.Equal(t, 3, len(handler.events), "handler should receive 3 events")
+
+	cp, err = LoadCheckpoint(path)
+	require.NoError(t, err, "load saved checkpoint should not throw error")
+	assert.True(t, cp.Exists(), "checkpoint should be saved before the first failed event")
+	assert.Equal(t, uint64(3), cp.BlockNumber, "checkpoint should resume from the block of the failed event")
+	assert.False(t, cp.Processed(3, "tx1"), "failed event should be processed again after restart")
+	assert.True(t, cp.Processed(3, "tx2"), "event handled after the failed event should not be processed again")
+	assert.True(t, cp.Processed(4, "tx3"), "event handled after the failed event should not be processed again")
+
+	// restart, and the failed event succeeds
+	handler.failTx = ""
+	l.checkpoint = cp
+	service = &testEventService{events: make(chan *fab.CCEvent, 5)}
+	require.NoError(t, l.start(service), "restart listener should not throw error")
+	service.events <- &fab.CCEvent{TxID: "tx1", ChaincodeID: "basic", EventName: "CreateAsset", BlockNumber: 3}
+	service.events <- &fab.CCEvent{TxID: "tx2", ChaincodeID: "basic", EventName: "CreateAsset", BlockNumber: 3}
+	service.events <- &fab.CCEvent{TxID: "tx3", ChaincodeID: "basic", EventName: "CreateAsset", BlockNumber: 4}
+	service.events <- &fab.CCEvent{TxID: "tx4", ChaincodeID: "basic", EventName: "CreateAsset", BlockNumber: 5}
+	l.stop()
+
+	require.Equal(t, 5, len(handler.events), "only the failed event and the new event should be processed after restart")
+	assert.Equal(t, "tx1", handler.events[3]["txID"], "failed event should be processed again")
+	assert.Equal(t, "tx4", handler.events[4]["txID"], "new event should be processed")
+	assert.Equal(t, uint64(5), l.checkpoint.BlockNumber, "checkpoint should advance to the new event")
+	assert.Empty(t, l.checkpoint.Handled, "handled events should be covered by the checkpoint")
+}
+
+func TestReloadNetwork(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	require.NoError(t, err, "create temp folder should not throw error")
+	defer os.RemoveAll(dir)
+
+	// network config without orgs or peers, so the SDK does not read crypto files
+	request.RegisterNetwork("reload-events", []byte("name: reload-events\nversion: 1.0.0\n"), nil)
+	defer request.UnregisterNetwork("reload-events")
+
+	var services []*testEventService
+	defer func(open func(pcontext.ChannelProvider, *Checkpoint) (eventService, error)) { openEvents = open }(openEvents)
+	openEvents = func(channelProvider pcontext.ChannelProvider, cp *Checkpoint) (eventService, error) {
+		service := &testEventService{events: make(chan *fab.CCEvent, 5)}
+		services = append(services, service)
+		return service, nil
+	}
+
+	cp, err := LoadCheckpoint(checkpointFile(dir, "event-trigger", "test-handler"))
+	require.NoError(t, err, "load missing checkpoint should not throw error")
+	handler := &testHandler{}
+	trg := &Trigger{id: "event-trigger", settings: &Settings{ConnectionName: "reload-events", UserName: "User1@org1"}}
+	trg.listeners = []*listener{{
+		handler:    handler,
+		settings:   &HandlerSettings{ChannelID: "mychannel", ChaincodeID: "basic", EventFilter: ".*"},
+		checkpoint: cp,
+	}}
+	require.NoError(t, trg.Start(), "start trigger should not throw error")
+	require.Len(t, services, 1, "listener should register chaincode events")
+	services[0].events <- &fab.CCEvent{TxID: "tx1", ChaincodeID: "basic", EventName: "CreateAsset", BlockNumber: 3}
+
+	trg.reload("other-network")
+	assert.Len(t, services, 1, "reload of another connection should not restart the trigger")
+	oldSDK := trg.sdk
+	trg.reload("reload-events")
+	require.Len(t, services, 2, "reload of the connection should register chaincode events again")
+	assert.NotEqual(t, oldSDK, trg.sdk, "listener should use a new SDK after reload")
+	_, open := <-services[0].events
+	assert.False(t, open, "events of the previous SDK should be unregistered")
+
+	services[1].events <- &fab.CCEvent{TxID: "tx1", ChaincodeID: "basic", EventName: "CreateAsset", BlockNumber: 3}
+	services[1].events <- &fab.CCEvent{TxID: "tx2", ChaincodeID: "basic", EventName: "CreateAsset", BlockNumber: 4}
+	require.NoError(t, trg.Stop(), "stop trigger should not throw error")
+	require.Equal(t, 2, len(handler.events), "processed event should be skipped after reload")
+	assert.Equal(t, "tx2", handler.events[1]["txID"], "new event should be processed after reload")
+
+	trg.reload("reload-events")
+	assert.Len(t, services, 2, "stopped trigger should not be restarted by reload")
+}